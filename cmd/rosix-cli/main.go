@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -152,7 +153,10 @@ func writeCmd() *cobra.Command {
 
 // invokeCmd 调用资源行为
 func invokeCmd() *cobra.Command {
-	var paramsJSON string
+	var (
+		paramsJSON string
+		paramPairs []string
+	)
 
 	cmd := &cobra.Command{
 		Use:   "invoke PATH BEHAVIOR",
@@ -160,7 +164,8 @@ func invokeCmd() *cobra.Command {
 		Long:  "调用指定资源的行为函数，类似POSIX的ioctl系统调用",
 		Args:  cobra.ExactArgs(2),
 		Example: `  rosix invoke /actors/abc123 purify_air --params '{"mode":"auto","intensity":3}'
-  rosix invoke /actors/def456 read_environment --params '{"metrics":["temperature"]}'`,
+  rosix invoke /actors/def456 read_environment --params '{"metrics":["temperature"]}'
+  rosix invoke /actors/abc123 purify_air --param air_quality=120 --param target_quality=50`,
 		Run: func(cmd *cobra.Command, args []string) {
 			path := args[0]
 			behavior := args[1]
@@ -171,6 +176,18 @@ func invokeCmd() *cobra.Command {
 					log.Fatalf("参数解析失败: %v", err)
 				}
 			}
+			if params == nil {
+				params = make(map[string]interface{})
+			}
+
+			// key=value 形式的参数以字符串传递，由服务端按函数定义转换类型
+			for _, pair := range paramPairs {
+				key, value, ok := strings.Cut(pair, "=")
+				if !ok || key == "" {
+					log.Fatalf("参数格式错误: %s，应为 key=value", pair)
+				}
+				params[key] = value
+			}
 
 			request := map[string]interface{}{
 				"path":     path,
@@ -190,6 +207,7 @@ func invokeCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&paramsJSON, "params", "{}", "行为参数 (JSON格式)")
+	cmd.Flags().StringArrayVar(&paramPairs, "param", nil, "单个行为参数 (key=value)，可重复使用")
 
 	return cmd
 }
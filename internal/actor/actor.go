@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"sync"
-	"time"
 )
 
 // ActorState Actor状态
@@ -19,6 +18,8 @@ const (
 	ActorStateStopped ActorState = "stopped"
 	// ActorStateError 错误状态
 	ActorStateError ActorState = "error"
+	// ActorStateDormant 休眠状态，已登记但尚未激活或已被钝化
+	ActorStateDormant ActorState = "dormant"
 )
 
 // Actor 定义Actor接口
@@ -58,7 +59,7 @@ type MessageHandler interface {
 type BaseActor struct {
 	id             string
 	state          ActorState
-	messageChan    chan *Message
+	mailbox        *Mailbox
 	messageHandler MessageHandler
 	ctx            context.Context
 	cancel         context.CancelFunc
@@ -66,15 +67,20 @@ type BaseActor struct {
 	wg             sync.WaitGroup
 }
 
-// NewBaseActor 创建基础Actor
+// NewBaseActor 创建使用默认邮箱配置的基础Actor
 func NewBaseActor(id string) *BaseActor {
+	return NewBaseActorWithMailbox(id, DefaultMailboxConfig())
+}
+
+// NewBaseActorWithMailbox 创建指定邮箱容量和溢出策略的基础Actor
+func NewBaseActorWithMailbox(id string, config MailboxConfig) *BaseActor {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &BaseActor{
-		id:          id,
-		state:       ActorStateIdle,
-		messageChan: make(chan *Message, 100), // 缓冲100个消息
-		ctx:         ctx,
-		cancel:      cancel,
+		id:      id,
+		state:   ActorStateIdle,
+		mailbox: NewMailbox(id, config),
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 }
 
@@ -110,32 +116,25 @@ func (a *BaseActor) Stop() error {
 	}
 
 	a.state = ActorStateStopped
+	a.mailbox.Close()
 	a.cancel()
 
 	// 等待消息循环结束
 	a.wg.Wait()
 
-	// 关闭消息通道
-	close(a.messageChan)
-
 	return nil
 }
 
-// Send 发送消息到Actor
+// Send 发送消息到Actor的邮箱，邮箱已满时按溢出策略处理
 func (a *BaseActor) Send(msg *Message) error {
 	a.mu.RLock()
-	defer a.mu.RUnlock()
+	state := a.state
+	a.mu.RUnlock()
 
-	if a.state != ActorStateRunning {
+	if state != ActorStateRunning {
 		return fmt.Errorf("actor %s is not running", a.id)
 	}
-
-	select {
-	case a.messageChan <- msg:
-		return nil
-	case <-time.After(5 * time.Second):
-		return fmt.Errorf("timeout sending message to actor %s", a.id)
-	}
+	return a.mailbox.Put(msg)
 }
 
 // State 返回Actor状态
@@ -158,8 +157,9 @@ func (a *BaseActor) GetStatus() map[string]interface{} {
 	defer a.mu.RUnlock()
 
 	return map[string]interface{}{
-		"id":    a.id,
-		"state": a.state,
+		"id":      a.id,
+		"state":   a.state,
+		"mailbox": a.mailbox.Stats(),
 	}
 }
 
@@ -168,16 +168,11 @@ func (a *BaseActor) messageLoop() {
 	defer a.wg.Done()
 
 	for {
-		select {
-		case msg, ok := <-a.messageChan:
-			if !ok {
-				return // 通道已关闭
-			}
-			a.handleMessage(msg)
-
-		case <-a.ctx.Done():
-			return // 上下文取消
+		msg, ok := a.mailbox.Receive(a.ctx)
+		if !ok {
+			return // 邮箱已关闭或上下文取消
 		}
+		a.handleMessage(msg)
 	}
 }
 
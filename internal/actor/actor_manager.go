@@ -2,40 +2,165 @@ package actor
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
+	"uros-restron/internal/events"
 	"uros-restron/internal/models"
+
+	"github.com/google/uuid"
 )
 
 // ActorManager Actor管理器
+// 已登记的行为对应的 Actor 在第一次被使用时激活，空闲超时后钝化为休眠状态
 type ActorManager struct {
-	actors          map[string]Actor
+	actors          map[string]Actor            // 已激活的Actor
+	registry        map[string]*models.Behavior // 已登记的行为，包括休眠的Actor
 	mu              sync.RWMutex
 	ctx             context.Context
 	cancel          context.CancelFunc
+	background      context.Context // 关闭开始时取消，后台任务据此停止
+	stopBackground  context.CancelFunc
 	behaviorService *models.BehaviorService
+	stateProvider   ThingStateProvider
+	router          *Router
+
+	store        *actorStore // 事件日志和快照
+	idleTimeout  time.Duration
+	activations  int64 // 累计激活次数
+	passivations int64 // 累计钝化次数
+
+	mailboxDefaults MailboxConfig // 行为未设置邮箱时使用的配置
+	liveness        *liveness     // 心跳和卡住检测
+
+	invocationService *models.InvocationService
+	running           map[string]*runningInvocation
+	runningMu         sync.Mutex
 }
 
+// 函数调用事件
+const (
+	InvocationStartedEvent  = "invocation_started"
+	InvocationProgressEvent = "invocation_progress"
+	InvocationFinishedEvent = "invocation_finished"
+)
+
+// 函数调用的错误，调用方可以用 errors.Is 区分
+var (
+	// ErrInvocationNotRunning 调用不存在或已经结束
+	ErrInvocationNotRunning = errors.New("invocation is not running")
+	// ErrActorNotFound Actor 没有登记
+	ErrActorNotFound = errors.New("actor not found")
+	// ErrFunctionNotFound Actor 的行为没有该函数
+	ErrFunctionNotFound = errors.New("function not found")
+	// ErrInvocationsDraining 停止、重启或重新加载 Actor 时执行中的调用没有在 drainTimeout 内结束
+	ErrInvocationsDraining = errors.New("actor invocations did not finish")
+	// ErrInvalidParams 参数不符合函数定义
+	ErrInvalidParams = errors.New("parameter validation failed")
+)
+
+// runningInvocation 正在执行的函数调用
+type runningInvocation struct {
+	invocation *models.Invocation
+	cancel     context.CancelFunc
+	mu         sync.Mutex    // 串行化进度更新和完成记录
+	done       chan struct{} // 完成记录后关闭
+}
+
+// drainTimeout 停止、重启或重新加载 Actor 前等待被取消的调用结束的最长时间
+const drainTimeout = 10 * time.Second
+
 // NewActorManager 创建Actor管理器
 func NewActorManager(behaviorService *models.BehaviorService) *ActorManager {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &ActorManager{
+	background, stopBackground := context.WithCancel(ctx)
+	am := &ActorManager{
 		actors:          make(map[string]Actor),
+		registry:        make(map[string]*models.Behavior),
+		store:           &actorStore{snapshotEvery: defaultSnapshotEvery},
+		mailboxDefaults: DefaultMailboxConfig(),
+		liveness:        newLiveness(),
+		running:         make(map[string]*runningInvocation),
 		ctx:             ctx,
 		cancel:          cancel,
+		background:      background,
+		stopBackground:  stopBackground,
 		behaviorService: behaviorService,
 	}
+	am.router = newRouter(am)
+	return am
 }
 
-// CreateActorFromBehavior 从Behavior创建Actor
-func (am *ActorManager) CreateActorFromBehavior(behaviorID string) (Actor, error) {
+// SetEventBus 设置领域事件总线，Actor 激活、钝化、停止、状态变化、函数调用和存活状态变化时发布事件
+// 需要在 Start 之前调用
+func (am *ActorManager) SetEventBus(bus *events.Bus) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.store.bus = bus
+}
+
+// publishLifecycle 发布 Actor 生命周期事件
+func (am *ActorManager) publishLifecycle(eventType events.Type, actorID string) {
+	am.store.bus.Publish(events.Event{
+		Type:      eventType,
+		Aggregate: events.AggregateActor,
+		ID:        actorID,
+		Data:      map[string]interface{}{"actorId": actorID},
+	})
+}
+
+// SetMailboxDefaults 设置 Actor 邮箱的默认容量和溢出策略，行为中的邮箱设置优先
+// 只影响之后激活的 Actor
+func (am *ActorManager) SetMailboxDefaults(config MailboxConfig) {
+	if config.Overflow != "" && !validOverflowPolicy(config.Overflow) {
+		log.Printf("Unknown mailbox overflow policy %q, using %s", config.Overflow, defaultMailboxOverflow)
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.mailboxDefaults = config.withDefaults()
+}
+
+// Router 返回 Actor 之间的消息路由
+func (am *ActorManager) Router() *Router {
+	return am.router
+}
+
+// SetThingDirectory 设置按 Thing 和关系解析消息接收方使用的目录
+func (am *ActorManager) SetThingDirectory(directory ThingDirectory) {
+	am.router.setDirectory(directory)
+}
+
+// Context 返回后台任务的上下文，管理器开始关闭时取消，依附于 Actor 系统的后台任务应以此退出
+// 执行中的调用使用的上下文在关闭截止时间到达后才取消
+func (am *ActorManager) Context() context.Context {
+	return am.background
+}
+
+// SetThingStateProvider 设置函数执行时使用的 Thing 状态提供者，对已有和新建的Actor都生效
+func (am *ActorManager) SetThingStateProvider(provider ThingStateProvider) {
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
+	am.stateProvider = provider
+	for _, actor := range am.actors {
+		if behaviorActor, ok := actor.(*BehaviorActor); ok {
+			behaviorActor.SetThingStateProvider(provider)
+		}
+	}
+}
+
+// CreateActorFromBehavior 从Behavior创建Actor
+func (am *ActorManager) CreateActorFromBehavior(behaviorID string) (Actor, error) {
 	// 检查Actor是否已存在
-	if actor, exists := am.actors[behaviorID]; exists {
+	am.mu.RLock()
+	actor, exists := am.actors[behaviorID]
+	am.mu.RUnlock()
+	if exists {
 		return actor, nil
 	}
 
@@ -45,52 +170,62 @@ func (am *ActorManager) CreateActorFromBehavior(behaviorID string) (Actor, error
 		return nil, fmt.Errorf("failed to get behavior %s: %v", behaviorID, err)
 	}
 
-	// 创建BehaviorActor
-	actor := NewBehaviorActor(behavior)
-
-	// 启动Actor
-	if err := actor.Start(am.ctx); err != nil {
-		return nil, fmt.Errorf("failed to start actor for behavior %s: %v", behaviorID, err)
-	}
-
-	// 注册Actor
-	am.actors[behaviorID] = actor
-
-	return actor, nil
+	return am.CreateActorFromBehaviorData(behavior)
 }
 
 // CreateActorFromBehaviorData 从Behavior数据直接创建Actor
 func (am *ActorManager) CreateActorFromBehaviorData(behavior *models.Behavior) (Actor, error) {
 	am.mu.Lock()
-	defer am.mu.Unlock()
 
 	// 检查Actor是否已存在
 	if actor, exists := am.actors[behavior.ID]; exists {
+		am.mu.Unlock()
 		return actor, nil
 	}
 
-	// 创建BehaviorActor
-	actor := NewBehaviorActor(behavior)
+	// 登记并立即激活
+	am.registerLocked(behavior)
+	actor, err := am.activateLocked(behavior)
+	am.mu.Unlock()
+
+	return am.publishActivated(actor, err)
+}
+
+// ReloadActor 使用数据库中的最新定义重建行为对应的Actor
+// 行为已被删除时只停止原有的Actor；原来处于激活状态的Actor重建后立即激活，否则保持休眠
+func (am *ActorManager) ReloadActor(behaviorID string) error {
+	am.mu.RLock()
+	_, active := am.actors[behaviorID]
+	_, registered := am.registry[behaviorID]
+	am.mu.RUnlock()
 
-	// 启动Actor
-	if err := actor.Start(am.ctx); err != nil {
-		return nil, fmt.Errorf("failed to start actor for behavior %s: %v", behavior.ID, err)
+	if registered {
+		if err := am.StopActor(behaviorID); err != nil {
+			return err
+		}
 	}
 
-	// 注册Actor
-	am.actors[behavior.ID] = actor
+	behavior, err := am.behaviorService.GetBehavior(behaviorID)
+	if err != nil {
+		return nil
+	}
 
-	return actor, nil
+	if active {
+		_, err = am.CreateActorFromBehaviorData(behavior)
+		return err
+	}
+	am.RegisterBehavior(behavior)
+	return nil
 }
 
-// GetActor 获取Actor
+// GetActor 获取Actor，已登记但处于休眠状态的Actor会被激活
 func (am *ActorManager) GetActor(actorID string) (Actor, error) {
 	am.mu.RLock()
-	defer am.mu.RUnlock()
-
 	actor, exists := am.actors[actorID]
+	am.mu.RUnlock()
+
 	if !exists {
-		return nil, fmt.Errorf("actor %s not found", actorID)
+		return am.activate(actorID)
 	}
 
 	return actor, nil
@@ -109,23 +244,43 @@ func (am *ActorManager) ListActors() []Actor {
 	return actors
 }
 
-// StopActor 停止Actor
+// StopActor 停止Actor并取消登记，激活中的Actor会先保存快照，释放锁后发布 ActorStopped
+// 执行中的调用先被取消并等待结束，之后不会再写入该 Actor 的事件日志
 func (am *ActorManager) StopActor(actorID string) error {
-	am.mu.Lock()
-	defer am.mu.Unlock()
+	if err := am.lockDrained(actorID); err != nil {
+		return err
+	}
+	err := am.stopLocked(actorID)
+	am.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	am.publishLifecycle(events.ActorStopped, actorID)
+	return nil
+}
 
+// stopLocked 停止Actor并取消登记，调用方需持有写锁
+func (am *ActorManager) stopLocked(actorID string) error {
 	actor, exists := am.actors[actorID]
-	if !exists {
-		return fmt.Errorf("actor %s not found", actorID)
+	if _, registered := am.registry[actorID]; !exists && !registered {
+		return fmt.Errorf("%w: %s", ErrActorNotFound, actorID)
 	}
 
 	// 停止Actor
-	if err := actor.Stop(); err != nil {
-		return fmt.Errorf("failed to stop actor %s: %v", actorID, err)
+	if exists {
+		if err := actor.Stop(); err != nil {
+			return fmt.Errorf("failed to stop actor %s: %v", actorID, err)
+		}
+		if behaviorActor, ok := actor.(*BehaviorActor); ok {
+			am.saveSnapshotLocked(behaviorActor, nil)
+		}
 	}
 
 	// 从注册表中移除
 	delete(am.actors, actorID)
+	delete(am.registry, actorID)
+	am.router.UnsubscribeAll(actorID)
 
 	return nil
 }
@@ -139,11 +294,19 @@ func (am *ActorManager) StopAllActors() error {
 	for actorID, actor := range am.actors {
 		if err := actor.Stop(); err != nil {
 			errors = append(errors, fmt.Errorf("failed to stop actor %s: %v", actorID, err))
+			continue
+		}
+		if behaviorActor, ok := actor.(*BehaviorActor); ok {
+			am.saveSnapshotLocked(behaviorActor, nil)
 		}
 	}
 
 	// 清空注册表
+	for actorID := range am.registry {
+		am.router.UnsubscribeAll(actorID)
+	}
 	am.actors = make(map[string]Actor)
+	am.registry = make(map[string]*models.Behavior)
 
 	if len(errors) > 0 {
 		return fmt.Errorf("errors stopping actors: %v", errors)
@@ -152,38 +315,319 @@ func (am *ActorManager) StopAllActors() error {
 	return nil
 }
 
-// SendMessage 发送消息到Actor
+// SendMessage 发送消息到Actor，休眠的Actor会被激活
 func (am *ActorManager) SendMessage(actorID string, msg *Message) error {
 	actor, err := am.GetActor(actorID)
 	if err != nil {
 		return err
 	}
 
-	return actor.Send(msg)
+	err = actor.Send(msg)
+	if errors.Is(err, ErrActorStopped) {
+		// 获取后恰好被钝化，重新激活后再投递一次
+		if actor, err = am.GetActor(actorID); err != nil {
+			return err
+		}
+		err = actor.Send(msg)
+	}
+	return err
 }
 
 // CallFunction 调用Actor的函数
 func (am *ActorManager) CallFunction(actorID, functionName string, params map[string]interface{}) (map[string]interface{}, error) {
+	result, err := am.InvokeFunction(am.ctx, actorID, functionName, params)
+	if err != nil {
+		return nil, err
+	}
+	return result.Output, nil
+}
+
+// SetInvocationService 设置调用记录服务，设置后每次函数调用都会被记录
+func (am *ActorManager) SetInvocationService(service *models.InvocationService) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.invocationService = service
+}
+
+// InvokeFunction 调用Actor函数，返回包含实际参数（含默认值）的执行结果
+// 通过 WithThingID 指定 Thing 时，函数步骤可以引用其状态；
+// 函数不存在或参数无效时直接返回错误，其他调用都会生成调用记录。
+// 集群模式下与消息一样按 Thing ID（没有 Thing 时按 Actor ID）放置，由其他节点负责时转发到该节点执行
+func (am *ActorManager) InvokeFunction(ctx context.Context, actorID, functionName string, params map[string]interface{}) (*ExecutionResult, error) {
+	if remote, node := am.router.routeInvocation(ctx, actorID); remote != nil {
+		reply, err := remote.Invoke(ctx, node, newInvocationRequest(ctx, actorID, functionName, params, false))
+		if err != nil {
+			return nil, err
+		}
+		if reply.Result == nil {
+			return nil, fmt.Errorf("node %s returned no result for %s.%s", node, actorID, functionName)
+		}
+		return reply.Result, nil
+	}
+	return am.InvokeFunctionLocal(ctx, actorID, functionName, params)
+}
+
+// InvokeFunctionLocal 在本节点调用Actor函数，用于处理已按放置规则送达的调用
+func (am *ActorManager) InvokeFunctionLocal(ctx context.Context, actorID, functionName string, params map[string]interface{}) (*ExecutionResult, error) {
+	behaviorActor, err := am.getBehaviorActor(actorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := behaviorActor.ValidateCall(functionName, params); err != nil {
+		return nil, err
+	}
+
+	ctx, run := am.startInvocation(ctx, actorID, functionName, params, false)
+	return am.runInvocation(ctx, run, functionName, params)
+}
+
+// InvokeFunctionAsync 在后台调用Actor函数，立即返回运行中的调用记录
+// 后台执行只沿用 ctx 中的 Thing 和调用方，不受请求取消的影响，可以通过 CancelInvocation 取消；
+// 集群模式下由负责的节点执行，调用记录和取消都在该节点
+func (am *ActorManager) InvokeFunctionAsync(ctx context.Context, actorID, functionName string, params map[string]interface{}) (*models.Invocation, error) {
+	if remote, node := am.router.routeInvocation(ctx, actorID); remote != nil {
+		reply, err := remote.Invoke(ctx, node, newInvocationRequest(ctx, actorID, functionName, params, true))
+		if err != nil {
+			return nil, err
+		}
+		if reply.Invocation == nil {
+			return nil, fmt.Errorf("node %s returned no invocation for %s.%s", node, actorID, functionName)
+		}
+		return reply.Invocation, nil
+	}
+	return am.InvokeFunctionAsyncLocal(ctx, actorID, functionName, params)
+}
+
+// InvokeFunctionAsyncLocal 在本节点后台调用Actor函数，用于处理已按放置规则送达的调用
+func (am *ActorManager) InvokeFunctionAsyncLocal(ctx context.Context, actorID, functionName string, params map[string]interface{}) (*models.Invocation, error) {
+	behaviorActor, err := am.getBehaviorActor(actorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := behaviorActor.ValidateCall(functionName, params); err != nil {
+		return nil, err
+	}
+
+	runCtx := WithCaller(WithThingID(am.ctx, ThingIDFromContext(ctx)), CallerFromContext(ctx))
+	runCtx, run := am.startInvocation(runCtx, actorID, functionName, params, true)
+	snapshot := *run.invocation
+
+	go am.runInvocation(runCtx, run, functionName, params)
+
+	return &snapshot, nil
+}
+
+// newInvocationRequest 根据 ctx 中的 Thing 和调用方创建转发的调用
+func newInvocationRequest(ctx context.Context, actorID, functionName string, params map[string]interface{}, async bool) *InvocationRequest {
+	return &InvocationRequest{
+		ActorID:  actorID,
+		Function: functionName,
+		Params:   params,
+		ThingID:  ThingIDFromContext(ctx),
+		Caller:   CallerFromContext(ctx),
+		Async:    async,
+	}
+}
+
+// CancelInvocation 取消正在执行的调用，调用结束后状态为 cancelled
+func (am *ActorManager) CancelInvocation(invocationID string) error {
+	am.runningMu.Lock()
+	run, ok := am.running[invocationID]
+	am.runningMu.Unlock()
+	if !ok {
+		return ErrInvocationNotRunning
+	}
+
+	run.cancel()
+	return nil
+}
+
+// lockDrained 取消 Actor 执行中的调用并等待其结束，然后获取写锁，返回 nil 时调用方持有写锁
+// 调用在调用方的上下文中执行，Actor 实例停止后仍会继续并按实例内的序号写入事件日志，
+// 新实例恢复后再写入会与之冲突；持有写锁时之后开始的调用要等到释放锁后才能获取 Actor
+func (am *ActorManager) lockDrained(actorID string) error {
+	deadline := time.Now().Add(drainTimeout)
+	for {
+		if err := am.drainInvocations(actorID, deadline); err != nil {
+			return err
+		}
+		am.mu.Lock()
+		if !am.hasRunningInvocations(actorID) {
+			return nil
+		}
+		// 等待期间又开始了新的调用
+		am.mu.Unlock()
+	}
+}
+
+// drainInvocations 取消 Actor 执行中的调用并等待其结束，deadline 之前没有结束时返回错误
+func (am *ActorManager) drainInvocations(actorID string, deadline time.Time) error {
+	am.runningMu.Lock()
+	var runs []*runningInvocation
+	for _, run := range am.running {
+		if run.invocation.ActorID == actorID {
+			runs = append(runs, run)
+		}
+	}
+	am.runningMu.Unlock()
+
+	if len(runs) == 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	for _, run := range runs {
+		run.cancel()
+	}
+	for _, run := range runs {
+		select {
+		case <-run.done:
+		case <-timer.C:
+			return fmt.Errorf("%w: %s is still running %s", ErrInvocationsDraining, actorID, run.invocation.Function)
+		}
+	}
+	return nil
+}
+
+// getBehaviorActor 获取行为Actor
+func (am *ActorManager) getBehaviorActor(actorID string) (*BehaviorActor, error) {
 	actor, err := am.GetActor(actorID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 创建函数调用消息
-	msg := NewFunctionCallMessage("manager", actorID, functionName, params)
-	msg.SetCorrelationID(fmt.Sprintf("%d", time.Now().UnixNano()))
+	behaviorActor, ok := actor.(*BehaviorActor)
+	if !ok {
+		return nil, fmt.Errorf("actor %s is not a BehaviorActor", actorID)
+	}
+	behaviorActor.touch()
+	return behaviorActor, nil
+}
+
+// startInvocation 创建运行中的调用记录，返回可取消并会报告进度的执行上下文
+func (am *ActorManager) startInvocation(ctx context.Context, actorID, functionName string, params map[string]interface{}, async bool) (context.Context, *runningInvocation) {
+	ctx, cancel := context.WithCancel(ctx)
+	run := &runningInvocation{
+		invocation: &models.Invocation{
+			ID:        uuid.New().String(),
+			ActorID:   actorID,
+			ThingID:   ThingIDFromContext(ctx),
+			Function:  functionName,
+			Caller:    CallerFromContext(ctx),
+			Status:    models.InvocationRunning,
+			Async:     async,
+			Params:    params,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	am.runningMu.Lock()
+	am.running[run.invocation.ID] = run
+	am.runningMu.Unlock()
+
+	ctx = withInvocationID(ctx, run.invocation.ID)
+	ctx = WithProgressReporter(ctx, func(progress Progress) {
+		am.updateProgress(run, progress)
+	})
+
+	am.recordInvocation(InvocationStartedEvent, run.invocation)
+	return ctx, run
+}
+
+// runInvocation 执行函数并完成调用记录
+// 调用登记为运行中之后才获取执行的 Actor：钝化在写锁下跳过有运行中调用的 Actor，
+// 登记之前已被钝化的 Actor 在获取时重新激活；执行前恰好被重新加载时再获取一次
+func (am *ActorManager) runInvocation(ctx context.Context, run *runningInvocation, functionName string, params map[string]interface{}) (*ExecutionResult, error) {
+	var result *ExecutionResult
+	behaviorActor, err := am.getBehaviorActor(run.invocation.ActorID)
+	if err == nil {
+		result, err = behaviorActor.InvokeFunction(ctx, functionName, params)
+	}
+	if errors.Is(err, ErrActorStopped) {
+		if behaviorActor, err = am.getBehaviorActor(run.invocation.ActorID); err == nil {
+			result, err = behaviorActor.InvokeFunction(ctx, functionName, params)
+		}
+	}
+	am.finishInvocation(ctx, run, result, err)
+	return result, err
+}
+
+// updateProgress 记录步骤报告的执行进度
+func (am *ActorManager) updateProgress(run *runningInvocation, progress Progress) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
 
-	// 发送消息
-	if err := actor.Send(msg); err != nil {
-		return nil, fmt.Errorf("failed to send message to actor %s: %v", actorID, err)
+	if run.invocation.Status != models.InvocationRunning {
+		return
 	}
+	value := progress.Value
+	run.invocation.Progress = &value
+	run.invocation.ProgressMessage = progress.Message
 
-	// 这里应该等待响应，但为了简化，我们直接调用函数
-	if behaviorActor, ok := actor.(*BehaviorActor); ok {
-		return behaviorActor.CallFunction(functionName, params)
+	am.recordInvocation(InvocationProgressEvent, run.invocation)
+}
+
+// finishInvocation 根据执行结果完成调用记录，执行上下文被取消时记为 cancelled
+func (am *ActorManager) finishInvocation(ctx context.Context, run *runningInvocation, result *ExecutionResult, err error) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	am.runningMu.Lock()
+	delete(am.running, run.invocation.ID)
+	am.runningMu.Unlock()
+
+	invocation := run.invocation
+	finishedAt := time.Now()
+	invocation.FinishedAt = &finishedAt
+	invocation.DurationMs = finishedAt.Sub(invocation.StartedAt).Milliseconds()
+	invocation.Status = models.InvocationSucceeded
+
+	if result != nil {
+		invocation.Params = result.Params
+		invocation.Result = result.Output
+		if trace, marshalErr := json.Marshal(result.Trace); marshalErr == nil {
+			invocation.Trace = trace
+		}
 	}
+	switch {
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+		invocation.Status = models.InvocationCancelled
+		invocation.Error = err.Error()
+	case err != nil:
+		invocation.Status = models.InvocationFailed
+		invocation.Error = err.Error()
+	default:
+		complete := 1.0
+		invocation.Progress = &complete
+	}
+	run.cancel()
+
+	am.recordInvocation(InvocationFinishedEvent, invocation)
+	close(run.done)
+}
+
+// recordInvocation 保存调用记录并发布到事件总线，保存失败只记录日志
+func (am *ActorManager) recordInvocation(event string, invocation *models.Invocation) {
+	am.mu.RLock()
+	service := am.invocationService
+	am.mu.RUnlock()
 
-	return nil, fmt.Errorf("actor %s is not a BehaviorActor", actorID)
+	if service != nil {
+		if err := service.SaveInvocation(invocation); err != nil {
+			log.Printf("Failed to record invocation %s: %v", invocation.ID, err)
+		}
+	}
+
+	snapshot := *invocation
+	am.store.bus.Publish(events.Event{
+		Type:      events.Type(event),
+		Aggregate: events.AggregateInvocation,
+		ID:        invocation.ID,
+		ThingID:   invocation.ThingID,
+		Data:      &snapshot,
+	})
 }
 
 // GetActorStatus 获取Actor状态
@@ -221,55 +665,6 @@ func (am *ActorManager) GetActorInfo(actorID string) (map[string]interface{}, er
 	return info, nil
 }
 
-// StartHeartbeat 启动心跳监控
-func (am *ActorManager) StartHeartbeat(interval time.Duration) {
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				am.sendHeartbeatToAllActors()
-			case <-am.ctx.Done():
-				return
-			}
-		}
-	}()
-}
-
-// sendHeartbeatToAllActors 向所有Actor发送心跳
-func (am *ActorManager) sendHeartbeatToAllActors() {
-	am.mu.RLock()
-	actors := make([]Actor, 0, len(am.actors))
-	for _, actor := range am.actors {
-		actors = append(actors, actor)
-	}
-	am.mu.RUnlock()
-
-	for _, actor := range actors {
-		heartbeatMsg := NewMessage(Heartbeat, "manager", actor.ID())
-		heartbeatMsg.SetCorrelationID(fmt.Sprintf("heartbeat_%d", time.Now().UnixNano()))
-
-		if err := actor.Send(heartbeatMsg); err != nil {
-			fmt.Printf("Failed to send heartbeat to actor %s: %v\n", actor.ID(), err)
-		}
-	}
-}
-
-// Shutdown 关闭Actor管理器
-func (am *ActorManager) Shutdown() error {
-	// 停止所有Actor
-	if err := am.StopAllActors(); err != nil {
-		return err
-	}
-
-	// 取消上下文
-	am.cancel()
-
-	return nil
-}
-
 // GetActorCount 获取Actor数量
 func (am *ActorManager) GetActorCount() int {
 	am.mu.RLock()
@@ -341,25 +736,54 @@ func (am *ActorManager) BroadcastMessage(msg *Message) {
 }
 
 // HealthCheck 健康检查
+// total 为已登记的Actor数，active 和 dormant 分别为已激活和休眠的数量；
+// healthy 和 unhealthy 只统计已激活的Actor，按状态、心跳回应和消息处理时长判断
 func (am *ActorManager) HealthCheck() map[string]interface{} {
+	now := time.Now()
+	reports, config, lastCheck := am.actorHealthReports(now)
+
 	am.mu.RLock()
-	defer am.mu.RUnlock()
+	total := len(am.registry)
+	active := len(am.actors)
+	activations := am.activations
+	passivations := am.passivations
+	idleTimeout := am.idleTimeout
+	am.mu.RUnlock()
 
 	healthy := 0
-	total := len(am.actors)
-
-	for _, actor := range am.actors {
-		if actor.State() == ActorStateRunning {
+	for _, report := range reports {
+		if report.Healthy && report.Status == ActorStateRunning {
 			healthy++
 		}
 	}
-
-	return map[string]interface{}{
-		"status":    "healthy",
-		"total":     total,
-		"healthy":   healthy,
-		"unhealthy": total - healthy,
-	}
+	status := "healthy"
+	if healthy < len(reports) {
+		status = "degraded"
+	}
+
+	result := map[string]interface{}{
+		"status":       status,
+		"total":        total,
+		"active":       active,
+		"dormant":      total - active,
+		"healthy":      healthy,
+		"unhealthy":    len(reports) - healthy,
+		"activations":  activations,
+		"passivations": passivations,
+		"idle_timeout": idleTimeout.String(),
+		"liveness": map[string]interface{}{
+			"heartbeat_interval": config.HeartbeatInterval.String(),
+			"heartbeat_timeout":  config.HeartbeatTimeout.String(),
+			"stuck_threshold":    config.StuckThreshold.String(),
+			"max_restarts":       config.MaxRestarts,
+		},
+		"actors":     reports,
+		"checked_at": now,
+	}
+	if !lastCheck.IsZero() {
+		result["last_liveness_check"] = lastCheck
+	}
+	return result
 }
 
 // RegisterBehaviorsFromService 从服务注册所有行为为Actor
@@ -370,12 +794,9 @@ func (am *ActorManager) RegisterBehaviorsFromService(behaviorService *models.Beh
 		return fmt.Errorf("failed to get behaviors: %v", err)
 	}
 
-	// 为每个行为创建Actor
-	for _, behavior := range behaviors {
-		_, err := am.CreateActorFromBehaviorData(&behavior)
-		if err != nil {
-			fmt.Printf("Failed to create actor for behavior %s: %v\n", behavior.ID, err)
-		}
+	// 登记每个行为，Actor 在第一次被使用时激活
+	for i := range behaviors {
+		am.RegisterBehavior(&behaviors[i])
 	}
 
 	return nil
@@ -383,7 +804,8 @@ func (am *ActorManager) RegisterBehaviorsFromService(behaviorService *models.Beh
 
 // Start 启动Actor管理器
 func (am *ActorManager) Start() error {
-	// 启动心跳监控
-	am.StartHeartbeat(30 * time.Second)
+	// 启动存活检查和空闲 Actor 钝化
+	am.startLiveness()
+	am.startPassivation()
 	return nil
 }
@@ -2,9 +2,14 @@ package actor
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"uros-restron/internal/models"
@@ -20,39 +25,67 @@ type FunctionDefinition struct {
 
 // BehaviorActor Behavior Actor 实现
 type BehaviorActor struct {
-	id          string
-	name        string
-	behavior    *models.Behavior
-	Functions   map[string]FunctionHandler `json:"-"`
-	MessageChan chan *Message              `json:"-"`
-	Context     context.Context            `json:"-"`
-	Cancel      context.CancelFunc         `json:"-"`
-	Status      string                     `json:"status"`
-	LastActive  time.Time                  `json:"last_active"`
-	mu          sync.RWMutex               `json:"-"`
+	id         string
+	name       string
+	behavior   *models.Behavior
+	executor   *FunctionExecutor
+	router     *Router
+	Functions  map[string]FunctionHandler `json:"-"`
+	Context    context.Context            `json:"-"`
+	Cancel     context.CancelFunc         `json:"-"`
+	Status     string                     `json:"status"`
+	LastActive time.Time                  `json:"last_active"`
+	mu         sync.RWMutex               `json:"-"`
+	mailbox    *Mailbox
+
+	activations       int64 // 累计激活次数，从快照恢复
+	messagesProcessed int64 // 累计处理的消息数，不含心跳
+	processing        int32 // 正在处理消息时为 1
+
+	// 存活检查
+	heartbeatID           string    // 最近一次心跳的关联ID
+	heartbeatSentAt       time.Time // 最近一次心跳的发送时间
+	heartbeatPendingSince time.Time // 尚未回应的心跳的发送时间，已回应时为零值
+	heartbeatAckAt        time.Time
+	heartbeatRTT          time.Duration
+	processingSince       time.Time // 开始处理当前消息的时间，空闲时为零值
+	processingMessage     string
+
+	// 由事件日志构建的状态
+	store            *actorStore
+	state            map[string]interface{}
+	sequence         int64      // 最后应用的事件序号
+	snapshotSequence int64      // 最近一次快照对应的事件序号
+	emitMu           sync.Mutex // 串行化事件的追加和应用
 }
 
+// ErrActorStopped Actor 已停止或已钝化，需要重新获取后再投递
+var ErrActorStopped = errors.New("actor is stopped")
+
 // FunctionHandler 函数处理器接口
 type FunctionHandler interface {
 	Execute(params map[string]interface{}) (map[string]interface{}, error)
 	GetDefinition() FunctionDefinition
 }
 
-// NewBehaviorActor 创建新的 Behavior Actor
+// NewBehaviorActor 创建新的 Behavior Actor，邮箱使用默认配置和行为中的邮箱设置
 func NewBehaviorActor(behavior *models.Behavior) *BehaviorActor {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	actor := &BehaviorActor{
-		id:          behavior.ID,
-		name:        behavior.Name,
-		behavior:    behavior,
-		Functions:   make(map[string]FunctionHandler),
-		MessageChan: make(chan *Message, 100),
-		Context:     ctx,
-		Cancel:      cancel,
-		Status:      "initializing",
-		LastActive:  time.Now(),
+		id:         behavior.ID,
+		name:       behavior.Name,
+		behavior:   behavior,
+		executor:   NewFunctionExecutor(behavior),
+		Functions:  make(map[string]FunctionHandler),
+		mailbox:    NewMailbox(behavior.ID, DefaultMailboxConfig().withBehavior(behavior.Mailbox)),
+		Context:    ctx,
+		Cancel:     cancel,
+		Status:     "initializing",
+		LastActive: time.Now(),
+		state:      make(map[string]interface{}),
 	}
+	actor.executor.setJournal(actor)
 
 	// 注册函数处理器
 	actor.registerFunctionHandlers()
@@ -82,16 +115,14 @@ func (ba *BehaviorActor) State() ActorState {
 	}
 }
 
-// Send 发送消息到Actor
+// Send 发送消息到Actor的邮箱，邮箱已满时按溢出策略处理
 func (ba *BehaviorActor) Send(msg *Message) error {
-	select {
-	case ba.MessageChan <- msg:
-		return nil
-	case <-ba.Context.Done():
-		return fmt.Errorf("actor %s is stopped", ba.id)
-	default:
-		return fmt.Errorf("actor %s message channel is full", ba.id)
-	}
+	return ba.mailbox.Put(msg)
+}
+
+// setMailboxDefaults 按全局默认配置和行为中的邮箱设置重建邮箱，需要在 Start 之前调用
+func (ba *BehaviorActor) setMailboxDefaults(defaults MailboxConfig) {
+	ba.mailbox = NewMailbox(ba.id, defaults.withBehavior(ba.behavior.Mailbox))
 }
 
 // SetMessageHandler 设置消息处理器（BehaviorActor不需要外部处理器）
@@ -116,6 +147,8 @@ func (ba *BehaviorActor) registerFunctionHandlers() {
 			InputParams:    convertParametersToMap(funcData.InputParams),
 			OutputParams:   convertParametersToMap(funcData.OutputParams),
 			Implementation: convertImplementationToMap(funcData.Implementation),
+			key:            funcName,
+			executor:       ba.executor,
 		}
 
 		ba.Functions[funcName] = handler
@@ -123,18 +156,16 @@ func (ba *BehaviorActor) registerFunctionHandlers() {
 }
 
 // convertParametersToMap 将 Parameter 映射转换为 map[string]interface{}
+// 通过 JSON 往返转换，保留数组元素、对象属性等嵌套定义
 func convertParametersToMap(params map[string]models.Parameter) map[string]interface{} {
 	result := make(map[string]interface{})
-	for name, param := range params {
-		result[name] = map[string]interface{}{
-			"type":        param.Type,
-			"description": param.Description,
-			"required":    param.Required,
-			"default":     param.Default,
-			"min":         param.Min,
-			"max":         param.Max,
-			"enum":        param.Enum,
-		}
+	data, err := json.Marshal(params)
+	if err != nil {
+		log.Printf("Failed to convert parameters: %v", err)
+		return result
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		log.Printf("Failed to convert parameters: %v", err)
 	}
 	return result
 }
@@ -170,11 +201,15 @@ func (ba *BehaviorActor) Start(ctx context.Context) error {
 // Stop 停止 Actor
 func (ba *BehaviorActor) Stop() error {
 	ba.mu.Lock()
+	if ba.Status == "stopped" {
+		ba.mu.Unlock()
+		return nil
+	}
 	ba.Status = "stopped"
+	ba.mailbox.Close()
 	ba.mu.Unlock()
 
 	ba.Cancel()
-	close(ba.MessageChan)
 
 	log.Printf("Behavior Actor %s (%s) stopped", ba.name, ba.id)
 	return nil
@@ -182,33 +217,33 @@ func (ba *BehaviorActor) Stop() error {
 
 // SendMessage 发送消息到 Actor
 func (ba *BehaviorActor) SendMessage(msg *Message) error {
-	select {
-	case ba.MessageChan <- msg:
-		return nil
-	case <-ba.Context.Done():
-		return fmt.Errorf("actor %s is stopped", ba.id)
-	default:
-		return fmt.Errorf("actor %s message channel is full", ba.id)
-	}
+	return ba.Send(msg)
 }
 
 // messageLoop 消息处理循环
 func (ba *BehaviorActor) messageLoop() {
 	for {
-		select {
-		case msg := <-ba.MessageChan:
-			ba.handleMessage(msg)
-		case <-ba.Context.Done():
-			return
+		msg, ok := ba.mailbox.Receive(ba.Context)
+		if !ok {
+			return // 邮箱已关闭
 		}
+		atomic.StoreInt32(&ba.processing, 1)
+		ba.beginProcessing(msg)
+		ba.handleMessage(msg)
+		ba.endProcessing()
+		atomic.StoreInt32(&ba.processing, 0)
 	}
 }
 
 // handleMessage 处理消息
 func (ba *BehaviorActor) handleMessage(msg *Message) {
-	ba.mu.Lock()
-	ba.LastActive = time.Now()
-	ba.mu.Unlock()
+	// 心跳不计入活跃时间，否则空闲的 Actor 永远不会被钝化
+	if msg.Type != Heartbeat {
+		ba.mu.Lock()
+		ba.LastActive = time.Now()
+		ba.messagesProcessed++
+		ba.mu.Unlock()
+	}
 
 	log.Printf("Actor %s received message: %s", ba.id, msg.Type)
 
@@ -219,12 +254,17 @@ func (ba *BehaviorActor) handleMessage(msg *Message) {
 		ba.handleStatusQuery(msg)
 	case Heartbeat:
 		ba.handleHeartbeat(msg)
+	case Event:
+		ba.handleEvent(msg)
+	case FunctionResponse, StatusResponse, Error:
+		// 没有等待方的响应只记录日志
+		log.Printf("Actor %s received %s from %s without pending request", ba.id, msg.Type, msg.From)
 	default:
 		log.Printf("Unknown message type: %s", msg.Type)
 	}
 }
 
-// handleFunctionCall 处理函数调用
+// handleFunctionCall 处理函数调用，消息带有关联ID时回复执行结果
 func (ba *BehaviorActor) handleFunctionCall(msg *Message) {
 	funcName := msg.Function
 	if funcName == "" {
@@ -247,13 +287,25 @@ func (ba *BehaviorActor) handleFunctionCall(msg *Message) {
 	if !exists {
 		response := NewFunctionResponseMessage(ba.id, msg.From, false, nil, fmt.Sprintf("function %s not found", funcName))
 		response.CorrelationID = msg.CorrelationID
-		// 这里应该发送响应，但为了简化，我们只记录日志
 		log.Printf("Function %s not found in actor %s", funcName, ba.id)
+		ba.reply(response)
 		return
 	}
 
-	// 执行函数
-	result, err := handler.Execute(params)
+	// 执行函数，接入消息路由时通过管理器执行以生成调用记录
+	var result map[string]interface{}
+	var err error
+	if ba.router != nil {
+		ctx := WithThingID(ba.Context, msg.ThingID)
+		ctx = WithCaller(ctx, messageCaller(msg))
+		var execution *ExecutionResult
+		execution, err = ba.router.manager.InvokeFunctionLocal(ctx, ba.id, funcName, params)
+		if execution != nil {
+			result = execution.Output
+		}
+	} else {
+		result, err = handler.Execute(params)
+	}
 
 	// 创建响应消息
 	success := err == nil
@@ -264,8 +316,86 @@ func (ba *BehaviorActor) handleFunctionCall(msg *Message) {
 
 	response := NewFunctionResponseMessage(ba.id, msg.From, success, result, errorMsg)
 	response.CorrelationID = msg.CorrelationID
+	response.ThingID = msg.ThingID
 
 	log.Printf("Actor %s executed function %s: success=%v", ba.id, funcName, success)
+	ba.reply(response)
+}
+
+// handleEvent 处理主题消息，依次执行订阅该主题的函数
+func (ba *BehaviorActor) handleEvent(msg *Message) {
+	if ba.router == nil {
+		return
+	}
+
+	for _, funcName := range ba.subscribedFunctions(msg.Topic) {
+		ctx := WithThingID(ba.Context, msg.ThingID)
+		ctx = WithCaller(ctx, "topic:"+msg.Topic)
+		if _, err := ba.router.manager.InvokeFunctionLocal(ctx, ba.id, funcName, copyLocals(msg.Payload)); err != nil {
+			log.Printf("Actor %s failed to handle topic %s with %s: %v", ba.id, msg.Topic, funcName, err)
+		}
+	}
+}
+
+// subscribedFunctions 返回订阅了主题的函数，按名称排序
+func (ba *BehaviorActor) subscribedFunctions(topic string) []string {
+	var functions []string
+	for funcName, function := range ba.behavior.Functions {
+		for _, subscribed := range function.Subscribe {
+			if subscribed == topic {
+				functions = append(functions, funcName)
+				break
+			}
+		}
+	}
+	sort.Strings(functions)
+	return functions
+}
+
+// topics 返回行为中函数订阅的所有主题
+func (ba *BehaviorActor) topics() []string {
+	return behaviorTopics(ba.behavior)
+}
+
+// behaviorTopics 返回行为中函数订阅的所有主题，按名称排序
+func behaviorTopics(behavior *models.Behavior) []string {
+	seen := make(map[string]bool)
+	var topics []string
+	for _, function := range behavior.Functions {
+		for _, topic := range function.Subscribe {
+			if !seen[topic] {
+				seen[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// reply 回复带有关联ID的请求，单向消息不回复
+func (ba *BehaviorActor) reply(response *Message) {
+	if ba.router == nil || response.CorrelationID == "" {
+		return
+	}
+	ba.router.Reply(response)
+}
+
+// setRouter 接入消息路由，函数步骤可以向其他 Actor 发送消息
+func (ba *BehaviorActor) setRouter(router *Router) {
+	ba.router = router
+	ba.executor.setRouter(router)
+}
+
+// messageCaller 返回消息触发的调用的调用方，带前缀的发送方（如 http:）原样返回
+func messageCaller(msg *Message) string {
+	if msg.From == "" {
+		return "message"
+	}
+	if strings.Contains(msg.From, ":") {
+		return msg.From
+	}
+	return "actor:" + msg.From
 }
 
 // handleStatusQuery 处理状态查询
@@ -287,16 +417,12 @@ func (ba *BehaviorActor) handleStatusQuery(msg *Message) {
 	response.CorrelationID = msg.CorrelationID
 
 	log.Printf("Actor %s status: %s", ba.id, status)
+	ba.reply(response)
 }
 
-// handleHeartbeat 处理心跳消息
+// handleHeartbeat 处理心跳消息，记录回应时间供存活检查使用
 func (ba *BehaviorActor) handleHeartbeat(msg *Message) {
-	ba.mu.Lock()
-	ba.LastActive = time.Now()
-	ba.mu.Unlock()
-
-	// 可以在这里添加心跳响应逻辑
-	// 目前只是更新最后活跃时间
+	ba.ackHeartbeat(msg)
 }
 
 // getAvailableFunctions 获取可用函数列表
@@ -314,11 +440,14 @@ func (ba *BehaviorActor) GetStatus() map[string]interface{} {
 	defer ba.mu.RUnlock()
 
 	return map[string]interface{}{
-		"id":          ba.id,
-		"name":        ba.name,
-		"status":      ba.Status,
-		"last_active": ba.LastActive,
-		"functions":   ba.getAvailableFunctions(),
+		"id":                 ba.id,
+		"name":               ba.name,
+		"status":             ba.Status,
+		"last_active":        ba.LastActive,
+		"functions":          ba.getAvailableFunctions(),
+		"activations":        ba.activations,
+		"messages_processed": ba.messagesProcessed,
+		"mailbox":            ba.mailbox.Stats(),
 	}
 }
 
@@ -336,17 +465,51 @@ func (ba *BehaviorActor) GetAvailableFunctions() []string {
 func (ba *BehaviorActor) CallFunction(functionName string, params map[string]interface{}) (map[string]interface{}, error) {
 	handler, exists := ba.Functions[functionName]
 	if !exists {
-		return nil, fmt.Errorf("function %s not found", functionName)
+		return nil, fmt.Errorf("%w: %s", ErrFunctionNotFound, functionName)
 	}
 
 	return handler.Execute(params)
 }
 
+// InvokeFunction 调用函数并返回包含实际参数的执行结果
+// 调用不经过邮箱，在调用方的 goroutine 中执行，函数中的 wait 和 call_actor 步骤不会阻塞消息循环，
+// 因此邮箱的容量和消息循环的卡住检测不适用于调用；同时执行的调用数由邮箱的调用名额限制，
+// 执行时长由 ctx 和步骤超时限制
+func (ba *BehaviorActor) InvokeFunction(ctx context.Context, functionName string, params map[string]interface{}) (*ExecutionResult, error) {
+	if _, exists := ba.Functions[functionName]; !exists {
+		return nil, fmt.Errorf("%w: %s", ErrFunctionNotFound, functionName)
+	}
+	if ba.Context.Err() != nil {
+		return nil, fmt.Errorf("%w: %s", ErrActorStopped, ba.id)
+	}
+
+	release, err := ba.mailbox.acquireInvocation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ba.touch()
+
+	return ba.executor.Execute(ctx, functionName, params)
+}
+
+// ValidateCall 检查函数存在且参数符合定义，不执行函数
+func (ba *BehaviorActor) ValidateCall(functionName string, params map[string]interface{}) error {
+	_, _, err := ba.executor.prepare(functionName, params)
+	return err
+}
+
+// SetThingStateProvider 设置函数执行时使用的 Thing 状态提供者
+func (ba *BehaviorActor) SetThingStateProvider(provider ThingStateProvider) {
+	ba.executor.SetThingStateProvider(provider)
+}
+
 // GetFunctionInfo 获取函数信息
 func (ba *BehaviorActor) GetFunctionInfo(functionName string) (FunctionDefinition, error) {
 	handler, exists := ba.Functions[functionName]
 	if !exists {
-		return FunctionDefinition{}, fmt.Errorf("function %s not found", functionName)
+		return FunctionDefinition{}, fmt.Errorf("%w: %s", ErrFunctionNotFound, functionName)
 	}
 
 	return handler.GetDefinition(), nil
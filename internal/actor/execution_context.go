@@ -0,0 +1,77 @@
+package actor
+
+import "context"
+
+// ThingStateProvider 提供函数执行时引用的 Thing 状态
+type ThingStateProvider interface {
+	GetThingState(thingID string) (map[string]interface{}, error)
+}
+
+type (
+	thingIDKey      struct{}
+	callerKey       struct{}
+	invocationIDKey struct{}
+)
+
+// WithThingID 指定函数执行针对的 Thing，步骤中可以通过 thing.xxx 引用其状态
+func WithThingID(ctx context.Context, thingID string) context.Context {
+	if thingID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, thingIDKey{}, thingID)
+}
+
+// ThingIDFromContext 返回函数执行针对的 Thing ID
+func ThingIDFromContext(ctx context.Context) string {
+	thingID, _ := ctx.Value(thingIDKey{}).(string)
+	return thingID
+}
+
+// WithCaller 记录函数调用方，例如 HTTP 客户端地址或发起调用的 Actor
+func WithCaller(ctx context.Context, caller string) context.Context {
+	if caller == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext 返回函数调用方
+func CallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// withInvocationID 记录当前调用的ID
+func withInvocationID(ctx context.Context, invocationID string) context.Context {
+	return context.WithValue(ctx, invocationIDKey{}, invocationID)
+}
+
+// InvocationIDFromContext 返回当前调用的ID，未记录调用时为空
+func InvocationIDFromContext(ctx context.Context) string {
+	invocationID, _ := ctx.Value(invocationIDKey{}).(string)
+	return invocationID
+}
+
+// Progress 函数执行进度
+type Progress struct {
+	Value   float64 `json:"value"` // 0 到 1
+	Step    string  `json:"step"`  // 报告进度的步骤路径
+	Message string  `json:"message,omitempty"`
+}
+
+// ProgressReporter 接收函数执行进度
+type ProgressReporter func(progress Progress)
+
+type progressReporterKey struct{}
+
+// WithProgressReporter 设置接收执行进度的回调
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, reporter)
+}
+
+// ReportProgress 报告执行进度，未设置回调时忽略
+func ReportProgress(ctx context.Context, progress Progress) {
+	if reporter, ok := ctx.Value(progressReporterKey{}).(ProgressReporter); ok && reporter != nil {
+		reporter(progress)
+	}
+}
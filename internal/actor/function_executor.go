@@ -2,38 +2,50 @@ package actor
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"uros-restron/internal/expr"
 	"uros-restron/internal/models"
 )
 
 // FunctionExecutor 函数执行器
 type FunctionExecutor struct {
-	behavior  *models.Behavior
-	functions map[string]interface{}
+	behavior      *models.Behavior
+	functions     map[string]models.Function
+	conditions    sync.Map // 已解析的表达式
+	stateProvider ThingStateProvider
+	router        *Router      // 消息动作使用的路由
+	journal       eventJournal // emit_event 写入的事件日志
 }
 
 // NewFunctionExecutor 创建函数执行器
 func NewFunctionExecutor(behavior *models.Behavior) *FunctionExecutor {
-	// 将 models.Function 转换为 map[string]interface{} 以保持兼容性
-	functions := make(map[string]interface{})
+	functions := make(map[string]models.Function, len(behavior.Functions))
 	for name, function := range behavior.Functions {
-		functions[name] = map[string]interface{}{
-			"name":           function.Name,
-			"description":    function.Description,
-			"input_params":   function.InputParams,
-			"output_params":  function.OutputParams,
-			"implementation": function.Implementation,
-		}
+		functions[name] = function
 	}
-	
+
 	return &FunctionExecutor{
 		behavior:  behavior,
 		functions: functions,
 	}
 }
 
+// SetThingStateProvider 设置 Thing 状态提供者
+func (fe *FunctionExecutor) SetThingStateProvider(provider ThingStateProvider) {
+	fe.stateProvider = provider
+}
+
 // HasFunction 检查是否有指定函数
 func (fe *FunctionExecutor) HasFunction(functionName string) bool {
 	_, exists := fe.functions[functionName]
@@ -50,186 +62,334 @@ func (fe *FunctionExecutor) GetAvailableFunctions() []string {
 }
 
 // GetFunctionInfo 获取函数信息
-func (fe *FunctionExecutor) GetFunctionInfo(functionName string) (map[string]interface{}, error) {
+func (fe *FunctionExecutor) GetFunctionInfo(functionName string) (models.Function, error) {
 	function, exists := fe.functions[functionName]
 	if !exists {
-		return nil, fmt.Errorf("function %s not found", functionName)
-	}
-
-	functionMap, ok := function.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid function format for %s", functionName)
+		return models.Function{}, fmt.Errorf("%w: %s", ErrFunctionNotFound, functionName)
 	}
+	return function, nil
+}
 
-	return functionMap, nil
+// ExecutionResult 函数执行结果，Params 为应用默认值和类型转换后的实际参数
+type ExecutionResult struct {
+	InvocationID string                 `json:"invocation_id,omitempty"`
+	Function     string                 `json:"function"`
+	ThingID      string                 `json:"thing_id,omitempty"`
+	Params       map[string]interface{} `json:"params"`
+	Variables    map[string]interface{} `json:"variables,omitempty"`
+	Output       map[string]interface{} `json:"output"`
+	Trace        []StepTrace            `json:"trace"`
+	StartedAt    time.Time              `json:"started_at"`
+	DurationMs   int64                  `json:"duration_ms"`
 }
 
 // ExecuteFunction 执行函数
 func (fe *FunctionExecutor) ExecuteFunction(ctx context.Context, functionName string, params map[string]interface{}) (map[string]interface{}, error) {
-	// 获取函数定义
-	functionInfo, err := fe.GetFunctionInfo(functionName)
+	result, err := fe.Execute(ctx, functionName, params)
+	if err != nil {
+		return nil, err
+	}
+	return result.Output, nil
+}
+
+// prepare 获取函数定义，应用默认值、转换类型并验证输入参数
+func (fe *FunctionExecutor) prepare(functionName string, params map[string]interface{}) (models.Function, map[string]interface{}, error) {
+	function, err := fe.GetFunctionInfo(functionName)
+	if err != nil {
+		return models.Function{}, nil, err
+	}
+
+	resolved := fe.ResolveParams(function, params)
+	if err := fe.validateInputParams(function, resolved); err != nil {
+		return models.Function{}, nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	return function, resolved, nil
+}
+
+// Execute 解析参数并执行函数，返回包含实际参数和步骤记录的执行结果
+// 函数已开始执行但失败时，同时返回执行结果和错误
+func (fe *FunctionExecutor) Execute(ctx context.Context, functionName string, params map[string]interface{}) (*ExecutionResult, error) {
+	function, resolved, err := fe.prepare(functionName, params)
 	if err != nil {
 		return nil, err
 	}
 
-	// 验证输入参数
-	if err := fe.validateInputParams(functionInfo, params); err != nil {
-		return nil, fmt.Errorf("parameter validation failed: %v", err)
+	// 加载 Thing 状态
+	thingID := ThingIDFromContext(ctx)
+	thing, err := fe.loadThingState(thingID)
+	if err != nil {
+		return nil, err
 	}
 
 	// 执行函数实现
-	result, err := fe.executeFunctionImplementation(ctx, functionInfo, params)
+	result := &ExecutionResult{
+		InvocationID: InvocationIDFromContext(ctx),
+		Function:     functionName,
+		ThingID:      thingID,
+		Params:       resolved,
+		StartedAt:    time.Now(),
+	}
+	err = fe.executeFunctionImplementation(ctx, function, thing, result)
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
 	if err != nil {
-		return nil, fmt.Errorf("function execution failed: %v", err)
+		return result, fmt.Errorf("function execution failed: %v", err)
 	}
 
 	// 验证输出参数
-	if err := fe.validateOutputParams(functionInfo, result); err != nil {
-		return nil, fmt.Errorf("output validation failed: %v", err)
+	if err := fe.validateOutputParams(function, result.Output); err != nil {
+		return result, fmt.Errorf("output validation failed: %v", err)
 	}
 
 	return result, nil
 }
 
-// validateInputParams 验证输入参数
-func (fe *FunctionExecutor) validateInputParams(functionInfo map[string]interface{}, params map[string]interface{}) error {
-	inputParams, exists := functionInfo["input_params"]
-	if !exists {
-		return nil // 没有输入参数要求
+// loadThingState 加载函数执行针对的 Thing 状态，未指定 Thing 时返回 nil
+func (fe *FunctionExecutor) loadThingState(thingID string) (map[string]interface{}, error) {
+	if thingID == "" {
+		return nil, nil
+	}
+	if fe.stateProvider == nil {
+		return nil, fmt.Errorf("thing state is not available")
 	}
 
-	inputParamsMap, ok := inputParams.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid input_params format")
+	state, err := fe.stateProvider.GetThingState(thingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load thing %s: %v", thingID, err)
 	}
+	return state, nil
+}
 
-	for paramName, paramDef := range inputParamsMap {
-		paramDefMap, ok := paramDef.(map[string]interface{})
-		if !ok {
+// ResolveParams 为缺失的参数填充默认值，并把字符串形式的值转换为参数声明的类型
+// 无法转换的值保持原样，由后续验证报告错误
+func (fe *FunctionExecutor) ResolveParams(function models.Function, params map[string]interface{}) map[string]interface{} {
+	return resolveProperties(function.InputParams, params)
+}
+
+// resolveProperties 按参数定义解析一组命名参数，未声明的参数原样保留
+func resolveProperties(defs map[string]models.Parameter, values map[string]interface{}) map[string]interface{} {
+	resolved := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		resolved[name] = value
+	}
+
+	for name, paramDef := range defs {
+		value, exists := resolved[name]
+		if !exists {
+			if paramDef.Default != nil {
+				resolved[name] = copyValue(paramDef.Default)
+			}
 			continue
 		}
+		resolved[name] = coerceParamValue(value, paramDef)
+	}
 
-		// 检查必需参数
-		if required, exists := paramDefMap["required"]; exists {
-			if requiredBool, ok := required.(bool); ok && requiredBool {
-				if _, exists := params[paramName]; !exists {
-					return fmt.Errorf("required parameter %s is missing", paramName)
-				}
+	return resolved
+}
+
+// coerceParamValue 把值转换为参数声明的类型，数组元素和对象属性递归处理
+func coerceParamValue(value interface{}, paramDef models.Parameter) interface{} {
+	str, isString := value.(string)
+
+	switch paramDef.Type {
+	case "number", "integer":
+		if isString {
+			if num, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil {
+				return num
 			}
 		}
 
-		// 验证参数值
-		if paramValue, exists := params[paramName]; exists {
-			if err := fe.validateParamValue(paramName, paramValue, paramDefMap); err != nil {
-				return err
+	case "boolean":
+		if isString {
+			if b, err := strconv.ParseBool(strings.TrimSpace(str)); err == nil {
+				return b
+			}
+		}
+
+	case "array":
+		if isString {
+			// 支持 JSON 数组，否则视为只有一个元素的数组（如单个查询参数）
+			var items []interface{}
+			if err := json.Unmarshal([]byte(str), &items); err != nil {
+				items = []interface{}{str}
+			}
+			value = items
+		}
+		items, ok := value.([]interface{})
+		if !ok || paramDef.Items == nil {
+			return value
+		}
+		coerced := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced[i] = coerceParamValue(item, *paramDef.Items)
+		}
+		return coerced
+
+	case "object":
+		if isString {
+			var obj map[string]interface{}
+			if err := json.Unmarshal([]byte(str), &obj); err != nil {
+				return value
 			}
+			value = obj
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		return resolveProperties(paramDef.Properties, obj)
+	}
+
+	return value
+}
+
+// copyValue 深拷贝默认值，避免多次调用共享同一个 map 或切片
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	}
+	return value
+}
+
+// validateInputParams 验证输入参数
+func (fe *FunctionExecutor) validateInputParams(function models.Function, params map[string]interface{}) error {
+	for _, paramName := range sortedParamNames(function.InputParams) {
+		paramDef := function.InputParams[paramName]
+
+		paramValue, exists := params[paramName]
+		if !exists {
+			// 检查必需参数
+			if paramDef.Required {
+				return fmt.Errorf("required parameter %s is missing", paramName)
+			}
+			continue
+		}
+
+		// 验证参数值
+		if err := fe.validateParamValue(paramName, paramValue, paramDef); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// validateParamValue 验证参数值
-func (fe *FunctionExecutor) validateParamValue(paramName string, value interface{}, paramDef map[string]interface{}) error {
-	paramType, exists := paramDef["type"]
-	if !exists {
-		return nil // 没有类型要求
+// validateOutputParams 验证输出参数
+func (fe *FunctionExecutor) validateOutputParams(function models.Function, result map[string]interface{}) error {
+	for _, paramName := range sortedParamNames(function.OutputParams) {
+		paramDef := function.OutputParams[paramName]
+
+		value, exists := result[paramName]
+		if !exists {
+			// 如果参数是必需的，返回错误
+			if paramDef.Required {
+				return fmt.Errorf("required output parameter %s is missing", paramName)
+			}
+			continue
+		}
+
+		if err := fe.validateParamValue(paramName, value, paramDef); err != nil {
+			return err
+		}
 	}
 
-	typeStr, ok := paramType.(string)
-	if !ok {
-		return nil
+	return nil
+}
+
+// uuidPattern UUID 格式
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validateParamValue 验证参数值，path 为参数路径，数组元素和对象属性递归验证
+func (fe *FunctionExecutor) validateParamValue(path string, value interface{}, paramDef models.Parameter) error {
+	if value == nil {
+		if paramDef.Nullable {
+			return nil
+		}
+		return fmt.Errorf("parameter %s must not be null", path)
 	}
 
-	switch typeStr {
+	switch paramDef.Type {
 	case "string":
-		if _, ok := value.(string); !ok {
-			return fmt.Errorf("parameter %s must be a string", paramName)
-		}
-		// 检查字符串长度
-		if maxLength, exists := paramDef["max_length"]; exists {
-			if maxLen, ok := maxLength.(float64); ok {
-				if len(value.(string)) > int(maxLen) {
-					return fmt.Errorf("parameter %s exceeds max length %d", paramName, int(maxLen))
-				}
-			}
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("parameter %s must be a string", path)
+		}
+		if err := validateString(path, str, paramDef); err != nil {
+			return err
 		}
 
-	case "number":
-		if _, ok := value.(float64); !ok {
-			return fmt.Errorf("parameter %s must be a number", paramName)
+	case "number", "integer":
+		num, ok := expr.ToNumber(value)
+		if !ok {
+			return fmt.Errorf("parameter %s must be a %s", path, paramDef.Type)
+		}
+		if paramDef.Type == "integer" && num != math.Trunc(num) {
+			return fmt.Errorf("parameter %s must be an integer", path)
 		}
 		// 检查数值范围
-		if min, exists := paramDef["min"]; exists {
-			if minVal, ok := min.(float64); ok {
-				if value.(float64) < minVal {
-					return fmt.Errorf("parameter %s must be >= %f", paramName, minVal)
-				}
-			}
+		if paramDef.Min != nil && num < *paramDef.Min {
+			return fmt.Errorf("parameter %s must be >= %v", path, *paramDef.Min)
 		}
-		if max, exists := paramDef["max"]; exists {
-			if maxVal, ok := max.(float64); ok {
-				if value.(float64) > maxVal {
-					return fmt.Errorf("parameter %s must be <= %f", paramName, maxVal)
-				}
-			}
+		if paramDef.Max != nil && num > *paramDef.Max {
+			return fmt.Errorf("parameter %s must be <= %v", path, *paramDef.Max)
 		}
 
 	case "boolean":
 		if _, ok := value.(bool); !ok {
-			return fmt.Errorf("parameter %s must be a boolean", paramName)
+			return fmt.Errorf("parameter %s must be a boolean", path)
 		}
 
 	case "object":
-		if _, ok := value.(map[string]interface{}); !ok {
-			return fmt.Errorf("parameter %s must be an object", paramName)
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("parameter %s must be an object", path)
 		}
-	}
-
-	// 检查枚举值
-	if enum, exists := paramDef["enum"]; exists {
-		if enumList, ok := enum.([]interface{}); ok {
-			valid := false
-			for _, enumValue := range enumList {
-				if enumValue == value {
-					valid = true
-					break
+		for _, name := range sortedParamNames(paramDef.Properties) {
+			propDef := paramDef.Properties[name]
+			propValue, exists := obj[name]
+			if !exists {
+				if propDef.Required {
+					return fmt.Errorf("required property %s.%s is missing", path, name)
 				}
+				continue
 			}
-			if !valid {
-				return fmt.Errorf("parameter %s must be one of %v", paramName, enumList)
+			if err := fe.validateParamValue(path+"."+name, propValue, propDef); err != nil {
+				return err
+			}
+		}
+		if paramDef.AdditionalProperties != nil && !*paramDef.AdditionalProperties {
+			for name := range obj {
+				if _, declared := paramDef.Properties[name]; !declared {
+					return fmt.Errorf("parameter %s has unexpected property %s", path, name)
+				}
 			}
 		}
-	}
-
-	return nil
-}
-
-// validateOutputParams 验证输出参数
-func (fe *FunctionExecutor) validateOutputParams(functionInfo map[string]interface{}, result map[string]interface{}) error {
-	outputParams, exists := functionInfo["output_params"]
-	if !exists {
-		return nil // 没有输出参数要求
-	}
-
-	outputParamsMap, ok := outputParams.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid output_params format")
-	}
 
-	// 检查必需的输出参数
-	for paramName, paramDef := range outputParamsMap {
-		paramDefMap, ok := paramDef.(map[string]interface{})
+	case "array":
+		items, ok := value.([]interface{})
 		if !ok {
-			continue
+			return fmt.Errorf("parameter %s must be an array", path)
 		}
-
-		// 检查参数是否存在
-		if _, exists := result[paramName]; !exists {
-			// 如果参数是必需的，返回错误
-			if required, exists := paramDefMap["required"]; exists {
-				if requiredBool, ok := required.(bool); ok && requiredBool {
-					return fmt.Errorf("required output parameter %s is missing", paramName)
+		if paramDef.MinItems != nil && len(items) < *paramDef.MinItems {
+			return fmt.Errorf("parameter %s must have at least %d items", path, *paramDef.MinItems)
+		}
+		if paramDef.MaxItems != nil && len(items) > *paramDef.MaxItems {
+			return fmt.Errorf("parameter %s must have at most %d items", path, *paramDef.MaxItems)
+		}
+		if paramDef.Items != nil {
+			for i, item := range items {
+				if err := fe.validateParamValue(fmt.Sprintf("%s[%d]", path, i), item, *paramDef.Items); err != nil {
+					return err
 				}
 			}
 		}
@@ -238,74 +398,85 @@ func (fe *FunctionExecutor) validateOutputParams(functionInfo map[string]interfa
 	return nil
 }
 
-// executeFunctionImplementation 执行函数实现
-func (fe *FunctionExecutor) executeFunctionImplementation(ctx context.Context, functionInfo map[string]interface{}, params map[string]interface{}) (map[string]interface{}, error) {
-	implementation, exists := functionInfo["implementation"]
-	if !exists {
-		return nil, fmt.Errorf("function implementation not found")
-	}
-
-	implMap, ok := implementation.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid implementation format")
+// validateString 验证字符串的长度、枚举、正则和格式
+func validateString(path, value string, paramDef models.Parameter) error {
+	if paramDef.MinLength != nil && len(value) < *paramDef.MinLength {
+		return fmt.Errorf("parameter %s is shorter than min length %d", path, *paramDef.MinLength)
 	}
-
-	// 执行实现步骤
-	steps, exists := implMap["steps"]
-	if !exists {
-		return nil, fmt.Errorf("implementation steps not found")
+	if paramDef.MaxLength != nil && len(value) > *paramDef.MaxLength {
+		return fmt.Errorf("parameter %s exceeds max length %d", path, *paramDef.MaxLength)
 	}
 
-	stepsList, ok := steps.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid steps format")
-	}
-
-	// 执行每个步骤
-	result := make(map[string]interface{})
-	for _, stepInterface := range stepsList {
-		step, ok := stepInterface.(map[string]interface{})
-		if !ok {
-			continue
+	// 检查枚举值
+	if len(paramDef.Enum) > 0 {
+		valid := false
+		for _, enumValue := range paramDef.Enum {
+			if enumValue == value {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("parameter %s must be one of %v", path, paramDef.Enum)
 		}
+	}
 
-		stepResult, err := fe.executeStep(ctx, step, params, result)
+	if paramDef.Pattern != "" {
+		pattern, err := regexp.Compile(paramDef.Pattern)
 		if err != nil {
-			return nil, fmt.Errorf("step execution failed: %v", err)
+			return fmt.Errorf("parameter %s has invalid pattern: %v", path, err)
 		}
-
-		// 合并步骤结果
-		for k, v := range stepResult {
-			result[k] = v
+		if !pattern.MatchString(value) {
+			return fmt.Errorf("parameter %s does not match pattern %s", path, paramDef.Pattern)
 		}
 	}
 
-	return result, nil
+	if paramDef.Format != "" && !matchesFormat(paramDef.Format, value) {
+		return fmt.Errorf("parameter %s must be a valid %s", path, paramDef.Format)
+	}
+
+	return nil
 }
 
-// executeStep 执行单个步骤
-func (fe *FunctionExecutor) executeStep(ctx context.Context, step map[string]interface{}, params map[string]interface{}, currentResult map[string]interface{}) (map[string]interface{}, error) {
-	action, exists := step["action"]
-	if !exists {
-		return nil, fmt.Errorf("step action not found")
+// matchesFormat 检查字符串是否符合指定格式，未知格式不做限制
+func matchesFormat(format, value string) bool {
+	switch format {
+	case "date-time", "ISO8601":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	case "email":
+		addr, err := mail.ParseAddress(value)
+		return err == nil && addr.Address == value
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != ""
 	}
+	return true
+}
 
-	actionStr, ok := action.(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid action format")
+// sortedParamNames 按名称排序参数，保证验证顺序和错误信息稳定
+func sortedParamNames(params map[string]models.Parameter) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	// 检查条件
-	if condition, exists := step["condition"]; exists {
-		if conditionStr, ok := condition.(string); ok {
-			if !fe.evaluateCondition(conditionStr, params, currentResult) {
-				return nil, nil // 条件不满足，跳过此步骤
-			}
-		}
+// executeAction 执行单个动作
+func (fe *FunctionExecutor) executeAction(action string, params map[string]interface{}) (map[string]interface{}, error) {
+	if action == "" {
+		return nil, fmt.Errorf("step action not found")
 	}
 
 	// 执行动作
-	switch actionStr {
+	switch action {
 	case "check_air_quality":
 		return fe.executeCheckAirQuality(params)
 	case "start_fan":
@@ -348,15 +519,49 @@ func (fe *FunctionExecutor) executeStep(ctx context.Context, step map[string]int
 		return fe.executeFormatPreferences(params)
 	default:
 		// 对于未知动作，返回模拟结果
-		return fe.executeGenericAction(actionStr, params)
+		return fe.executeGenericAction(action, params)
 	}
 }
 
-// evaluateCondition 评估条件
-func (fe *FunctionExecutor) evaluateCondition(condition string, params map[string]interface{}, currentResult map[string]interface{}) bool {
-	// 简单的条件评估实现
-	// 这里可以实现更复杂的条件评估逻辑
-	return true // 默认返回true，实际实现中需要解析条件表达式
+// actionOutputs 内置动作及其产生的输出字段
+var actionOutputs = map[string][]string{
+	"check_air_quality":    {"current_quality", "timestamp"},
+	"start_fan":            {"fan_started", "timestamp"},
+	"activate_filter":      {"filter_activated", "timestamp"},
+	"monitor_progress":     {"progress", "timestamp"},
+	"read_filter_sensor":   {"filter_id", "sensor_value", "timestamp"},
+	"calculate_usage":      {"usage_hours", "timestamp"},
+	"determine_status":     {"status", "remaining_life", "timestamp"},
+	"validate_speed":       {"valid", "timestamp"},
+	"set_motor_speed":      {"motor_speed", "timestamp"},
+	"confirm_speed":        {"confirmed", "timestamp"},
+	"parse_input":          {"parsed_input", "timestamp"},
+	"understand_intent":    {"intent", "confidence", "timestamp"},
+	"generate_response":    {"response", "timestamp"},
+	"format_output":        {"formatted", "timestamp"},
+	"validate_credentials": {"valid", "timestamp"},
+	"check_permissions":    {"permissions", "timestamp"},
+	"generate_session":     {"session_token", "timestamp"},
+	"load_user_profile":    {"user_id", "profile", "timestamp"},
+	"extract_preferences":  {"preferences", "timestamp"},
+	"format_preferences":   {"formatted", "timestamp"},
+
+	// 消息动作，由 executeMessaging 执行
+	"send_message": {"sent", "targets"},
+	"call_actor":   {"result", "results", "count"},
+	"publish":      {"topic", "delivered"},
+
+	// 事件动作，由 executeEmit 执行
+	"emit_event": {"event", "sequence"},
+}
+
+// ActionCatalog 返回内置动作及其输出字段，供行为校验使用
+func ActionCatalog() map[string][]string {
+	catalog := make(map[string][]string, len(actionOutputs))
+	for action, outputs := range actionOutputs {
+		catalog[action] = append([]string(nil), outputs...)
+	}
+	return catalog
 }
 
 // 以下是各种动作的具体实现
@@ -471,10 +676,11 @@ func (fe *FunctionExecutor) executeGenerateResponse(params map[string]interface{
 	}, nil
 }
 
+// executeFormatOutput 格式化输出，时间戳为 RFC3339 字符串，满足 date-time（ISO8601）格式
 func (fe *FunctionExecutor) executeFormatOutput(params map[string]interface{}) (map[string]interface{}, error) {
 	return map[string]interface{}{
 		"formatted": true,
-		"timestamp": time.Now().Unix(),
+		"timestamp": time.Now().Format(time.RFC3339),
 	}, nil
 }
 
@@ -1,6 +1,7 @@
 package actor
 
 import (
+	"context"
 	"fmt"
 	"log"
 )
@@ -12,6 +13,9 @@ type DefaultFunctionHandler struct {
 	InputParams    map[string]interface{} `json:"input_params"`
 	OutputParams   map[string]interface{} `json:"output_params"`
 	Implementation map[string]interface{} `json:"implementation"`
+
+	key      string            // 函数在行为中的名称
+	executor *FunctionExecutor // 按行为定义执行函数，为空时返回模拟结果
 }
 
 // Execute 执行函数
@@ -19,6 +23,10 @@ func (h *DefaultFunctionHandler) Execute(params map[string]interface{}) (map[str
 	log.Printf("Executing function: %s", h.Name)
 	log.Printf("Input parameters: %+v", params)
 
+	if h.executor != nil {
+		return h.executor.ExecuteFunction(context.Background(), h.key, params)
+	}
+
 	// 这里应该根据Implementation中的逻辑来执行函数
 	// 为了简化，我们返回一个模拟的结果
 	result := map[string]interface{}{
@@ -61,7 +69,7 @@ func (e *DefaultFunctionExecutor) RegisterFunction(name string, handler Function
 func (e *DefaultFunctionExecutor) ExecuteFunction(functionName string, params map[string]interface{}) (map[string]interface{}, error) {
 	handler, exists := e.functions[functionName]
 	if !exists {
-		return nil, fmt.Errorf("function %s not found", functionName)
+		return nil, fmt.Errorf("%w: %s", ErrFunctionNotFound, functionName)
 	}
 
 	return handler.Execute(params)
@@ -80,7 +88,7 @@ func (e *DefaultFunctionExecutor) GetAvailableFunctions() []string {
 func (e *DefaultFunctionExecutor) GetFunctionInfo(functionName string) (FunctionDefinition, error) {
 	handler, exists := e.functions[functionName]
 	if !exists {
-		return FunctionDefinition{}, fmt.Errorf("function %s not found", functionName)
+		return FunctionDefinition{}, fmt.Errorf("%w: %s", ErrFunctionNotFound, functionName)
 	}
 
 	return handler.GetDefinition(), nil
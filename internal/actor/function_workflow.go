@@ -0,0 +1,541 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"uros-restron/internal/expr"
+	"uros-restron/internal/models"
+)
+
+// 步骤执行状态
+const (
+	StepCompleted = "completed"
+	StepSkipped   = "skipped"
+	StepFailed    = "failed"
+	StepHandled   = "handled" // 步骤失败，但 on_error 已成功处理
+)
+
+const (
+	defaultMaxIterations = 10
+	defaultWaitInterval  = 100 * time.Millisecond
+)
+
+// StepTrace 单个步骤的执行记录，按步骤开始的顺序排列
+type StepTrace struct {
+	Path       string                 `json:"path"` // 步骤在实现中的位置，例如 steps[1].then[0]
+	Step       int                    `json:"step,omitempty"`
+	Type       string                 `json:"type"`
+	Action     string                 `json:"action,omitempty"`
+	Status     string                 `json:"status"`
+	Iteration  *int                   `json:"iteration,omitempty"` // 所在循环的迭代序号，从 0 开始
+	Output     map[string]interface{} `json:"output,omitempty"`    // 动作步骤的原始输出
+	Error      string                 `json:"error,omitempty"`
+	StartedAt  time.Time              `json:"started_at"`
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+// stepError 带步骤路径的错误，只在最内层失败的步骤处包装一次
+type stepError struct {
+	path string
+	err  error
+}
+
+func (e *stepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.path, e.err)
+}
+
+// compensation 已完成步骤登记的补偿步骤
+type compensation struct {
+	path  string
+	steps []models.ImplementationStep
+}
+
+// workflowRun 一次函数执行的状态
+// 并行分支使用各自的 workflowRun，完成后按分支顺序合并，保证记录顺序确定
+type workflowRun struct {
+	fe            *FunctionExecutor
+	inputs        map[string]models.Parameter // 声明的输入参数，未传入时在表达式中为 null
+	params        map[string]interface{}
+	thing         map[string]interface{}
+	trace         []StepTrace
+	compensations []compensation
+}
+
+// runState 执行过程中的数据：未映射步骤合并的结果和步骤输出映射的变量
+type runState struct {
+	result map[string]interface{}
+	vars   map[string]interface{}
+}
+
+func newRunState() *runState {
+	return &runState{result: make(map[string]interface{}), vars: make(map[string]interface{})}
+}
+
+// copy 复制状态，供并行分支独立修改
+func (s *runState) copy() *runState {
+	return &runState{result: copyLocals(s.result), vars: copyLocals(s.vars)}
+}
+
+// merge 合并分支的状态
+func (s *runState) merge(other *runState) {
+	for k, v := range other.result {
+		s.result[k] = v
+	}
+	for k, v := range other.vars {
+		s.vars[k] = v
+	}
+}
+
+// executeFunctionImplementation 执行函数实现，把输出、变量和步骤记录写入 result
+// 执行失败时按逆序运行已完成步骤的补偿步骤
+func (fe *FunctionExecutor) executeFunctionImplementation(ctx context.Context, function models.Function, thing map[string]interface{}, result *ExecutionResult) error {
+	run := &workflowRun{fe: fe, inputs: function.InputParams, params: result.Params, thing: thing}
+	state := newRunState()
+	defer func() {
+		result.Trace = run.trace
+		result.Variables = state.vars
+	}()
+
+	if err := run.runSteps(ctx, "steps", function.Implementation.Steps, state, nil); err != nil {
+		run.compensate(context.WithoutCancel(ctx), state)
+		return err
+	}
+
+	output, err := run.assembleOutputs(function.Implementation, state)
+	if err != nil {
+		return err
+	}
+	result.Output = output
+	return nil
+}
+
+// assembleOutputs 按实现声明的输出映射组装函数输出，未声明映射时返回合并的步骤结果
+// 映射结果为 null 的输出被省略，由输出验证检查必需参数
+func (r *workflowRun) assembleOutputs(impl models.FunctionImplementation, state *runState) (map[string]interface{}, error) {
+	if len(impl.Outputs) == 0 {
+		return state.result, nil
+	}
+
+	output := make(map[string]interface{}, len(impl.Outputs))
+	env := r.env(state, nil)
+	for _, name := range sortedBindingNames(impl.Outputs) {
+		value, err := r.evalValue(impl.Outputs[name], env)
+		if err != nil {
+			return nil, fmt.Errorf("outputs.%s: %v", name, err)
+		}
+		if value != nil {
+			output[name] = value
+		}
+	}
+	return output, nil
+}
+
+// runSteps 按顺序执行一组步骤，遇到未处理的错误立即返回
+func (r *workflowRun) runSteps(ctx context.Context, path string, steps []models.ImplementationStep, state *runState, locals map[string]interface{}) error {
+	for i, step := range steps {
+		if err := r.runStep(ctx, fmt.Sprintf("%s[%d]", path, i), step, state, locals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runStep 执行单个步骤，处理条件、超时、错误处理和补偿登记
+func (r *workflowRun) runStep(ctx context.Context, path string, step models.ImplementationStep, state *runState, locals map[string]interface{}) error {
+	index := len(r.trace)
+	r.trace = append(r.trace, StepTrace{
+		Path:      path,
+		Step:      step.Step,
+		Type:      step.StepType(),
+		Action:    step.Action,
+		Iteration: iterationOf(locals),
+		StartedAt: time.Now(),
+	})
+	finish := func(status string, err error) {
+		entry := &r.trace[index]
+		entry.Status = status
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		entry.DurationMs = time.Since(entry.StartedAt).Milliseconds()
+	}
+
+	// if 步骤的条件用于选择分支，其他步骤的条件为假时跳过
+	if step.Condition != "" && step.StepType() != models.StepTypeIf {
+		ok, err := r.evaluate(step.Condition, state, locals)
+		if err != nil {
+			finish(StepFailed, err)
+			return &stepError{path: path, err: err}
+		}
+		if !ok {
+			finish(StepSkipped, nil)
+			return nil
+		}
+	}
+
+	stepCtx := ctx
+	if step.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		stepCtx, cancel = context.WithTimeout(ctx, time.Duration(step.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
+	output, err := r.runStepBody(stepCtx, path, step, state, locals)
+	r.trace[index].Output = output
+	if err == nil {
+		reportStepProgress(ctx, path, step, output)
+	}
+	if (err == nil || errors.Is(err, context.DeadlineExceeded)) && stepCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		err = fmt.Errorf("step timed out after %dms", step.TimeoutMs)
+	}
+
+	if err != nil && len(step.OnError) > 0 {
+		handlerLocals := copyLocals(locals)
+		handlerLocals["error"] = err.Error()
+		handlerErr := r.runSteps(ctx, path+".on_error", step.OnError, state, handlerLocals)
+		if handlerErr == nil {
+			finish(StepHandled, err)
+			return nil
+		}
+		err = fmt.Errorf("%v (on_error failed: %v)", err, handlerErr)
+	}
+
+	if err != nil {
+		finish(StepFailed, err)
+		if _, wrapped := err.(*stepError); wrapped {
+			return err
+		}
+		return &stepError{path: path, err: err}
+	}
+
+	if len(step.Compensate) > 0 {
+		r.compensations = append(r.compensations, compensation{path: path + ".compensate", steps: step.Compensate})
+	}
+	finish(StepCompleted, nil)
+	return nil
+}
+
+// runStepBody 按步骤类型执行
+// 动作步骤返回动作的原始输出
+func (r *workflowRun) runStepBody(ctx context.Context, path string, step models.ImplementationStep, state *runState, locals map[string]interface{}) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	switch step.StepType() {
+	case models.StepTypeAction:
+		return r.runAction(ctx, step, state, locals)
+
+	case models.StepTypeIf:
+		ok, err := r.evaluate(step.Condition, state, locals)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return nil, r.runSteps(ctx, path+".then", step.Then, state, locals)
+		}
+		return nil, r.runSteps(ctx, path+".else", step.Else, state, locals)
+
+	case models.StepTypeLoop:
+		return nil, r.runLoop(ctx, path, step, state, locals)
+
+	case models.StepTypeParallel:
+		return nil, r.runParallel(ctx, path, step, state, locals)
+
+	case models.StepTypeWait:
+		return nil, r.runWait(ctx, step, state, locals)
+	}
+
+	return nil, fmt.Errorf("unknown step type %q", step.Type)
+}
+
+// runLoop 重复执行循环体，直到 Until 为真或达到最大迭代次数
+func (r *workflowRun) runLoop(ctx context.Context, path string, step models.ImplementationStep, state *runState, locals map[string]interface{}) error {
+	maxIterations := step.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultMaxIterations
+	}
+
+	loopLocals := copyLocals(locals)
+	for i := 0; i < maxIterations; i++ {
+		loopLocals["iteration"] = i
+		if err := r.runSteps(ctx, path+".steps", step.Steps, state, loopLocals); err != nil {
+			return err
+		}
+
+		if step.Until != "" {
+			done, err := r.evaluate(step.Until, state, loopLocals)
+			if err != nil {
+				return err
+			}
+			if done {
+				return nil
+			}
+		}
+
+		if step.IntervalMs > 0 && i < maxIterations-1 {
+			if err := sleepContext(ctx, time.Duration(step.IntervalMs)*time.Millisecond); err != nil {
+				return err
+			}
+		}
+	}
+
+	if step.Until != "" {
+		return fmt.Errorf("loop condition %q not met after %d iterations", step.Until, maxIterations)
+	}
+	return nil
+}
+
+// runParallel 并发执行各分支，每个分支使用状态的副本，全部完成后按分支顺序合并
+func (r *workflowRun) runParallel(ctx context.Context, path string, step models.ImplementationStep, state *runState, locals map[string]interface{}) error {
+	branches := make([]*workflowRun, len(step.Branches))
+	states := make([]*runState, len(step.Branches))
+	errs := make([]error, len(step.Branches))
+
+	var wg sync.WaitGroup
+	for i, branch := range step.Branches {
+		branches[i] = &workflowRun{fe: r.fe, inputs: r.inputs, params: r.params, thing: r.thing}
+		states[i] = state.copy()
+
+		wg.Add(1)
+		go func(i int, branch []models.ImplementationStep) {
+			defer wg.Done()
+			errs[i] = branches[i].runSteps(ctx, fmt.Sprintf("%s.branches[%d]", path, i), branch, states[i], copyLocals(locals))
+		}(i, branch)
+	}
+	wg.Wait()
+
+	for i, branch := range branches {
+		r.trace = append(r.trace, branch.trace...)
+		r.compensations = append(r.compensations, branch.compensations...)
+		state.merge(states[i])
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runWait 等待固定时长；设置 Until 时轮询直到条件为真，最多等待 DurationMs
+func (r *workflowRun) runWait(ctx context.Context, step models.ImplementationStep, state *runState, locals map[string]interface{}) error {
+	duration := time.Duration(step.DurationMs) * time.Millisecond
+	if step.Until == "" {
+		return sleepContext(ctx, duration)
+	}
+
+	interval := defaultWaitInterval
+	if step.IntervalMs > 0 {
+		interval = time.Duration(step.IntervalMs) * time.Millisecond
+	}
+
+	deadline := time.Now().Add(duration)
+	for {
+		done, err := r.evaluate(step.Until, state, locals)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("wait condition %q not met within %dms", step.Until, step.DurationMs)
+		}
+		if err := sleepContext(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// compensate 按逆序执行已登记的补偿步骤，补偿失败只记录日志
+func (r *workflowRun) compensate(ctx context.Context, state *runState) {
+	for i := len(r.compensations) - 1; i >= 0; i-- {
+		c := r.compensations[i]
+		if err := r.runSteps(ctx, c.path, c.steps, state, nil); err != nil {
+			log.Printf("Compensation %s failed: %v", c.path, err)
+		}
+	}
+	r.compensations = nil
+}
+
+// runAction 执行动作步骤，按 Inputs 绑定动作参数，按 Outputs 把动作输出映射到变量
+func (r *workflowRun) runAction(ctx context.Context, step models.ImplementationStep, state *runState, locals map[string]interface{}) (map[string]interface{}, error) {
+	actionParams := r.params
+	if len(step.Inputs) > 0 {
+		env := r.env(state, locals)
+		actionParams = make(map[string]interface{}, len(step.Inputs))
+		for _, name := range sortedBindingNames(step.Inputs) {
+			value, err := r.evalValue(step.Inputs[name], env)
+			if err != nil {
+				return nil, fmt.Errorf("inputs.%s: %v", name, err)
+			}
+			actionParams[name] = value
+		}
+	}
+
+	var output map[string]interface{}
+	var err error
+	switch {
+	case isMessagingAction(step.Action):
+		output, err = r.fe.executeMessaging(ctx, step, actionParams)
+	case step.Action == models.ActionEmitEvent:
+		output, err = r.fe.executeEmit(ctx, step, actionParams)
+	default:
+		output, err = r.fe.executeAction(step.Action, actionParams)
+	}
+	if err != nil {
+		return output, err
+	}
+
+	if len(step.Outputs) == 0 {
+		for k, v := range output {
+			state.result[k] = v
+		}
+		return output, nil
+	}
+
+	// 输出映射的表达式中可以直接引用输出字段，也可以通过 output 访问
+	env := make(expr.Env, len(output)+1)
+	for k, v := range output {
+		env[k] = v
+	}
+	env["output"] = output
+	for _, name := range sortedBindingNames(step.Outputs) {
+		value, err := r.evalValue(step.Outputs[name], env)
+		if err != nil {
+			return output, fmt.Errorf("outputs.%s: %v", name, err)
+		}
+		state.vars[name] = value
+	}
+	return output, nil
+}
+
+// env 构建表达式求值环境
+// 输入参数、步骤结果、变量和局部变量可以直接引用，后者覆盖前者；
+// 也可以通过 params、result、vars 和 thing 显式访问，state 为 Actor 由事件构建的状态
+func (r *workflowRun) env(state *runState, locals map[string]interface{}) expr.Env {
+	env := make(expr.Env, len(r.inputs)+len(state.result)+len(state.vars)+len(locals)+5)
+	for name := range r.inputs {
+		env[name] = nil
+	}
+	for k, v := range r.params {
+		env[k] = v
+	}
+	for k, v := range state.result {
+		env[k] = v
+	}
+	for k, v := range state.vars {
+		env[k] = v
+	}
+	for k, v := range locals {
+		env[k] = v
+	}
+	env["params"] = r.params
+	env["result"] = state.result
+	env["vars"] = state.vars
+	env["thing"] = r.thing
+	env["state"] = r.fe.actorState()
+	return env
+}
+
+// evaluate 求值条件表达式
+// 条件引用未定义的变量时返回错误，避免拼写错误被当作假值而静默跳过分支；
+// 可能不存在的变量通过命名空间访问，例如 exists(vars.total)
+func (r *workflowRun) evaluate(condition string, state *runState, locals map[string]interface{}) (bool, error) {
+	parsed, err := r.fe.parseCondition(condition)
+	if err != nil {
+		return false, err
+	}
+	env := r.env(state, locals)
+	if err := parsed.CheckDefined(env); err != nil {
+		return false, fmt.Errorf("condition %q: %v", condition, err)
+	}
+	return parsed.EvalBool(env)
+}
+
+// evalValue 求值绑定表达式
+func (r *workflowRun) evalValue(expression string, env expr.Env) (interface{}, error) {
+	parsed, err := r.fe.parseCondition(expression)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.Eval(env)
+}
+
+// parseCondition 解析表达式并缓存
+func (fe *FunctionExecutor) parseCondition(source string) (*expr.Expression, error) {
+	if cached, ok := fe.conditions.Load(source); ok {
+		return cached.(*expr.Expression), nil
+	}
+
+	parsed, err := expr.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", source, err)
+	}
+	fe.conditions.Store(source, parsed)
+	return parsed, nil
+}
+
+// reportStepProgress 动作输出 0 到 1 之间的 progress 字段时报告执行进度
+func reportStepProgress(ctx context.Context, path string, step models.ImplementationStep, output map[string]interface{}) {
+	value, ok := expr.ToNumber(output["progress"])
+	if !ok || value < 0 || value > 1 {
+		return
+	}
+
+	message := step.Description
+	if message == "" {
+		message = step.Action
+	}
+	ReportProgress(ctx, Progress{Value: value, Step: path, Message: message})
+}
+
+// sleepContext 等待指定时长，上下文取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// copyLocals 浅拷贝变量表
+func copyLocals(values map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(values)+1)
+	for k, v := range values {
+		copied[k] = v
+	}
+	return copied
+}
+
+// sortedBindingNames 按名称排序绑定，保证按确定的顺序求值
+func sortedBindingNames(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// iterationOf 返回当前循环迭代序号
+func iterationOf(locals map[string]interface{}) *int {
+	if i, ok := locals["iteration"].(int); ok {
+		return &i
+	}
+	return nil
+}
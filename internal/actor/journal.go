@@ -0,0 +1,305 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"uros-restron/internal/events"
+	"uros-restron/internal/models"
+)
+
+// defaultSnapshotEvery 默认每应用多少个事件保存一次快照
+const defaultSnapshotEvery = 100
+
+// maxEmitAttempts 序号被占用时追上事件日志后重试写入的次数
+const maxEmitAttempts = 3
+
+// actorStore Actor 事件日志和快照的存储，未设置的服务不持久化
+type actorStore struct {
+	events        *models.ActorEventService
+	snapshots     *models.ActorSnapshotService
+	snapshotEvery int64
+	bus           *events.Bus // 状态变化时发布 actor_state_changed
+}
+
+// recovered 从快照和之后的事件恢复的状态
+type recovered struct {
+	snapshot *models.ActorSnapshot // 没有快照时为 nil
+	state    map[string]interface{}
+	sequence int64
+}
+
+// recover 加载 Actor 最近的快照并重放之后的事件
+func (s *actorStore) recover(actorID string) (*recovered, error) {
+	result := &recovered{state: make(map[string]interface{})}
+
+	if s.snapshots != nil {
+		snapshot, err := s.snapshots.GetSnapshot(actorID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load snapshot: %v", err)
+		}
+		if snapshot != nil {
+			result.snapshot = snapshot
+			result.sequence = snapshot.Sequence
+			for key, value := range snapshot.State {
+				result.state[key] = value
+			}
+		}
+	}
+
+	if s.events != nil {
+		events, err := s.events.EventsAfter(actorID, result.sequence)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load events: %v", err)
+		}
+		for i := range events {
+			applyEvent(result.state, &events[i])
+			result.sequence = events[i].Sequence
+		}
+	}
+	return result, nil
+}
+
+// saveSnapshot 保存 Actor 当前状态的快照，并记录快照对应的事件序号
+func (s *actorStore) saveSnapshot(actor *BehaviorActor, passivatedAt *time.Time) error {
+	if s.snapshots == nil {
+		return nil
+	}
+
+	snapshot := actor.snapshot()
+	snapshot.PassivatedAt = passivatedAt
+	if err := s.snapshots.SaveSnapshot(snapshot); err != nil {
+		return err
+	}
+
+	actor.mu.Lock()
+	actor.snapshotSequence = snapshot.Sequence
+	actor.mu.Unlock()
+	return nil
+}
+
+// applyEvent 把事件数据合并到状态，值为 null 的字段从状态中删除
+func applyEvent(state map[string]interface{}, event *models.ActorEvent) {
+	for key, value := range event.Data {
+		if value == nil {
+			delete(state, key)
+			continue
+		}
+		state[key] = value
+	}
+}
+
+// emit 追加事件到日志并应用到状态，达到快照间隔时保存快照
+// 事件持久化失败时状态保持不变；集群中同一 Actor 可能同时在多个节点激活，
+// 序号已被其他节点写入时先应用这些事件再重试
+func (ba *BehaviorActor) emit(ctx context.Context, eventType string, data map[string]interface{}) (*models.ActorEvent, error) {
+	ba.emitMu.Lock()
+	defer ba.emitMu.Unlock()
+
+	event := &models.ActorEvent{
+		ActorID:      ba.id,
+		Type:         eventType,
+		ThingID:      ThingIDFromContext(ctx),
+		InvocationID: InvocationIDFromContext(ctx),
+		Data:         copyLocals(data),
+	}
+	for attempt := 1; ; attempt++ {
+		ba.mu.RLock()
+		event.Sequence = ba.sequence + 1
+		ba.mu.RUnlock()
+		event.CreatedAt = time.Now()
+
+		if ba.store == nil || ba.store.events == nil {
+			break
+		}
+		err := ba.store.events.AppendEvent(event)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, models.ErrEventSequenceTaken) || attempt == maxEmitAttempts {
+			return nil, fmt.Errorf("failed to persist event %s: %v", eventType, err)
+		}
+		if err := ba.catchUp(); err != nil {
+			return nil, fmt.Errorf("failed to persist event %s: %v", eventType, err)
+		}
+	}
+	sequence := event.Sequence
+
+	ba.mu.Lock()
+	applyEvent(ba.state, event)
+	ba.sequence = sequence
+	due := ba.store != nil && ba.store.snapshotEvery > 0 && sequence-ba.snapshotSequence >= ba.store.snapshotEvery
+	ba.mu.Unlock()
+
+	if due {
+		if err := ba.store.saveSnapshot(ba, nil); err != nil {
+			// 快照只用于加快恢复，保存失败不影响事件
+			log.Printf("Failed to save snapshot of actor %s: %v", ba.id, err)
+		}
+	}
+	if ba.store != nil {
+		ba.store.bus.Publish(events.Event{
+			Type:      events.ActorStateChanged,
+			Aggregate: events.AggregateActor,
+			ID:        ba.id,
+			ThingID:   event.ThingID,
+			Data:      event,
+		})
+	}
+	return event, nil
+}
+
+// catchUp 应用事件日志中当前序号之后的事件，调用方需持有 emitMu
+func (ba *BehaviorActor) catchUp() error {
+	ba.mu.RLock()
+	after := ba.sequence
+	ba.mu.RUnlock()
+
+	missed, err := ba.store.events.EventsAfter(ba.id, after)
+	if err != nil {
+		return fmt.Errorf("failed to load events: %v", err)
+	}
+
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+	for i := range missed {
+		applyEvent(ba.state, &missed[i])
+		ba.sequence = missed[i].Sequence
+	}
+	return nil
+}
+
+// restoreState 用恢复的状态替换 Actor 的状态
+func (ba *BehaviorActor) restoreState(state *recovered) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+
+	ba.state = state.state
+	ba.sequence = state.sequence
+	if state.snapshot != nil {
+		ba.snapshotSequence = state.snapshot.Sequence
+		ba.activations = state.snapshot.Activations
+		ba.messagesProcessed = state.snapshot.MessagesProcessed
+	}
+}
+
+// CurrentState 返回 Actor 状态的副本和最后应用的事件序号
+func (ba *BehaviorActor) CurrentState() (map[string]interface{}, int64) {
+	ba.mu.RLock()
+	defer ba.mu.RUnlock()
+	return copyLocals(ba.state), ba.sequence
+}
+
+// SetEventJournal 设置事件日志服务和快照间隔，snapshotEvery 不大于 0 时使用默认值
+func (am *ActorManager) SetEventJournal(service *models.ActorEventService, snapshotEvery int) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if snapshotEvery <= 0 {
+		snapshotEvery = defaultSnapshotEvery
+	}
+	am.store.events = service
+	am.store.snapshotEvery = int64(snapshotEvery)
+}
+
+// ActorState 返回 Actor 的状态、最后应用的事件序号和最近快照的序号
+// 休眠的 Actor 从快照和事件日志恢复状态，不会被激活
+func (am *ActorManager) ActorState(actorID string) (map[string]interface{}, error) {
+	am.mu.RLock()
+	actor, active := am.actors[actorID]
+	_, registered := am.registry[actorID]
+	am.mu.RUnlock()
+
+	if active {
+		if behaviorActor, ok := actor.(*BehaviorActor); ok {
+			state, sequence := behaviorActor.CurrentState()
+			behaviorActor.mu.RLock()
+			snapshotSequence := behaviorActor.snapshotSequence
+			behaviorActor.mu.RUnlock()
+			return map[string]interface{}{
+				"actorId":          actorID,
+				"status":           behaviorActor.State(),
+				"state":            state,
+				"sequence":         sequence,
+				"snapshotSequence": snapshotSequence,
+			}, nil
+		}
+	}
+	if !registered {
+		return nil, fmt.Errorf("%w: %s", ErrActorNotFound, actorID)
+	}
+
+	state, err := am.store.recover(actorID)
+	if err != nil {
+		return nil, err
+	}
+	var snapshotSequence int64
+	if state.snapshot != nil {
+		snapshotSequence = state.snapshot.Sequence
+	}
+	return map[string]interface{}{
+		"actorId":          actorID,
+		"status":           ActorStateDormant,
+		"state":            state.state,
+		"sequence":         state.sequence,
+		"snapshotSequence": snapshotSequence,
+	}, nil
+}
+
+// ListActorEvents 按序号倒序查询已登记 Actor 的事件，返回事件和总数
+func (am *ActorManager) ListActorEvents(actorID, eventType string, since int64, limit, offset int) ([]models.ActorEvent, int64, error) {
+	am.mu.RLock()
+	_, registered := am.registry[actorID]
+	events := am.store.events
+	am.mu.RUnlock()
+
+	if !registered {
+		return nil, 0, fmt.Errorf("%w: %s", ErrActorNotFound, actorID)
+	}
+	if events == nil {
+		return []models.ActorEvent{}, 0, nil
+	}
+	return events.ListEvents(actorID, eventType, since, limit, offset)
+}
+
+// eventJournal 函数步骤发出事件和读取 Actor 状态的接口，由 BehaviorActor 实现
+type eventJournal interface {
+	emit(ctx context.Context, eventType string, data map[string]interface{}) (*models.ActorEvent, error)
+	CurrentState() (map[string]interface{}, int64)
+}
+
+// setJournal 设置 emit_event 动作写入的事件日志
+func (fe *FunctionExecutor) setJournal(journal eventJournal) {
+	fe.journal = journal
+}
+
+// executeEmit 执行 emit_event 动作，params 作为事件数据
+func (fe *FunctionExecutor) executeEmit(ctx context.Context, step models.ImplementationStep, params map[string]interface{}) (map[string]interface{}, error) {
+	if fe.journal == nil {
+		return nil, fmt.Errorf("action %s requires a BehaviorActor", step.Action)
+	}
+	if step.Event == "" {
+		return nil, fmt.Errorf("emit_event requires an event type")
+	}
+
+	event, err := fe.journal.emit(ctx, step.Event, params)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"event":    event.Type,
+		"sequence": event.Sequence,
+	}, nil
+}
+
+// actorState 返回表达式中 state 引用的 Actor 状态，没有事件日志时为空
+func (fe *FunctionExecutor) actorState() map[string]interface{} {
+	if fe.journal == nil {
+		return map[string]interface{}{}
+	}
+	state, _ := fe.journal.CurrentState()
+	return state
+}
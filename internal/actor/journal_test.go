@@ -0,0 +1,194 @@
+package actor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uros-restron/internal/database"
+	"uros-restron/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// openJournal 打开临时数据库并创建事件日志和快照表，测试结束时关闭
+func openJournal(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "actors.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ActorEvent{}, &models.ActorSnapshot{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		database.Close(db)
+	})
+	return db
+}
+
+// newManagerOn 创建在 db 中保存事件日志和快照的 Actor 管理器，测试结束时关闭
+func newManagerOn(t *testing.T, db *gorm.DB) *ActorManager {
+	t.Helper()
+	am := NewActorManager(nil)
+	am.SetEventJournal(models.NewActorEventService(db), 0)
+	am.SetPassivation(models.NewActorSnapshotService(db), 0)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		am.Shutdown(ctx)
+	})
+	return am
+}
+
+// newJournaledManager 创建使用临时数据库保存事件日志和快照的 Actor 管理器
+func newJournaledManager(t *testing.T) (*ActorManager, *models.ActorEventService) {
+	t.Helper()
+	db := openJournal(t)
+	return newManagerOn(t, db), models.NewActorEventService(db)
+}
+
+// counterBehavior slow 等待后发出事件，bump 立即发出事件
+func counterBehavior() *models.Behavior {
+	return &models.Behavior{
+		ID:   "counter",
+		Name: "counter",
+		Functions: map[string]models.Function{
+			"slow": {
+				Name: "slow",
+				Implementation: models.FunctionImplementation{Steps: []models.ImplementationStep{
+					{Step: 1, Type: models.StepTypeWait, DurationMs: 200},
+					{Step: 2, Action: models.ActionEmitEvent, Event: "slow_done", Inputs: map[string]string{"slow": "true"}},
+				}},
+			},
+			"bump": {
+				Name: "bump",
+				Implementation: models.FunctionImplementation{Steps: []models.ImplementationStep{
+					{Step: 1, Action: models.ActionEmitEvent, Event: "bumped", Inputs: map[string]string{"bumped": "true"}},
+				}},
+			},
+		},
+	}
+}
+
+// assertJournal 检查新实例发出的事件依次写入日志，旧实例没有在停止后写入事件
+func assertJournal(t *testing.T, am *ActorManager, events *models.ActorEventService, bumps int) {
+	t.Helper()
+	// 超过 slow 的等待时间，没有被取消的调用会在此期间写入事件
+	time.Sleep(300 * time.Millisecond)
+
+	for i := 0; i < bumps; i++ {
+		if _, err := am.InvokeFunction(context.Background(), "counter", "bump", nil); err != nil {
+			t.Fatalf("bump %d failed: %v", i+1, err)
+		}
+	}
+
+	recorded, total, err := events.ListEvents("counter", "", 0, 100, 0)
+	if err != nil {
+		t.Fatalf("failed to list events: %v", err)
+	}
+	if total != int64(bumps) {
+		t.Fatalf("expected %d events, got %d: %+v", bumps, total, recorded)
+	}
+	for _, event := range recorded {
+		if event.Type != "bumped" {
+			t.Fatalf("unexpected event %s at sequence %d", event.Type, event.Sequence)
+		}
+	}
+	state, err := am.ActorState("counter")
+	if err != nil {
+		t.Fatalf("failed to read state: %v", err)
+	}
+	if state["sequence"] != int64(bumps) {
+		t.Fatalf("expected sequence %d, got %v", bumps, state["sequence"])
+	}
+}
+
+func TestRestartDrainsRunningInvocations(t *testing.T) {
+	am, events := newJournaledManager(t)
+	if _, err := am.CreateActorFromBehaviorData(counterBehavior()); err != nil {
+		t.Fatalf("failed to create actor: %v", err)
+	}
+
+	invocation, err := am.InvokeFunctionAsync(context.Background(), "counter", "slow", nil)
+	if err != nil {
+		t.Fatalf("failed to start slow invocation: %v", err)
+	}
+	if err := am.restartActor("counter"); err != nil {
+		t.Fatalf("restart failed: %v", err)
+	}
+	if am.hasRunningInvocations("counter") {
+		t.Fatalf("invocation %s still running after restart", invocation.ID)
+	}
+
+	assertJournal(t, am, events, 3)
+}
+
+func TestStopDrainsRunningInvocations(t *testing.T) {
+	am, events := newJournaledManager(t)
+	behavior := counterBehavior()
+	if _, err := am.CreateActorFromBehaviorData(behavior); err != nil {
+		t.Fatalf("failed to create actor: %v", err)
+	}
+
+	if _, err := am.InvokeFunctionAsync(context.Background(), "counter", "slow", nil); err != nil {
+		t.Fatalf("failed to start slow invocation: %v", err)
+	}
+	if err := am.StopActor("counter"); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+	if am.hasRunningInvocations("counter") {
+		t.Fatal("invocation still running after stop")
+	}
+
+	if _, err := am.CreateActorFromBehaviorData(behavior); err != nil {
+		t.Fatalf("failed to recreate actor: %v", err)
+	}
+	assertJournal(t, am, events, 2)
+}
+
+func TestEmitCatchesUpWithSharedJournal(t *testing.T) {
+	// 两个管理器共享数据库，模拟同一 Actor 因不同 Thing 在两个节点上激活
+	db := openJournal(t)
+	n1, n2 := newManagerOn(t, db), newManagerOn(t, db)
+	for _, am := range []*ActorManager{n1, n2} {
+		if _, err := am.CreateActorFromBehaviorData(counterBehavior()); err != nil {
+			t.Fatalf("failed to create actor: %v", err)
+		}
+	}
+
+	for i, am := range []*ActorManager{n1, n2, n1, n2, n2, n1} {
+		if _, err := am.InvokeFunction(context.Background(), "counter", "bump", nil); err != nil {
+			t.Fatalf("bump %d failed: %v", i+1, err)
+		}
+	}
+
+	_, total, err := models.NewActorEventService(db).ListEvents("counter", "", 0, 100, 0)
+	if err != nil {
+		t.Fatalf("failed to list events: %v", err)
+	}
+	if total != 6 {
+		t.Fatalf("expected 6 events, got %d", total)
+	}
+
+	// 没有写入的实例在下次写入时才追上其他节点的事件
+	assertSequence(t, n1, 6)
+	assertSequence(t, n2, 5)
+	if _, err := n2.InvokeFunction(context.Background(), "counter", "bump", nil); err != nil {
+		t.Fatalf("bump after catching up failed: %v", err)
+	}
+	assertSequence(t, n2, 7)
+}
+
+// assertSequence 检查 Actor 最后应用的事件序号
+func assertSequence(t *testing.T, am *ActorManager, expected int64) {
+	t.Helper()
+	state, err := am.ActorState("counter")
+	if err != nil {
+		t.Fatalf("failed to read state: %v", err)
+	}
+	if state["sequence"] != expected {
+		t.Fatalf("expected sequence %d, got %v", expected, state["sequence"])
+	}
+}
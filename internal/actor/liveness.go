@@ -0,0 +1,434 @@
+package actor
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"uros-restron/internal/events"
+)
+
+// 存活检查的默认阈值
+const (
+	defaultHeartbeatInterval = 30 * time.Second
+	defaultHeartbeatTimeout  = 10 * time.Second
+	defaultStuckThreshold    = 2 * time.Minute
+	defaultMaxRestarts       = 3
+
+	// minLivenessCheckInterval 存活检查的最短间隔
+	minLivenessCheckInterval = time.Second
+)
+
+// 存活状态变化事件
+const (
+	ActorUnhealthyEvent = "actor_unhealthy"
+	ActorRestartedEvent = "actor_restarted"
+	ActorRecoveredEvent = "actor_recovered"
+)
+
+// 不健康的原因
+const (
+	// UnhealthyUnresponsive 心跳超时没有回应
+	UnhealthyUnresponsive = "unresponsive"
+	// UnhealthyStuck 单条消息处理时间超过阈值
+	UnhealthyStuck = "stuck"
+)
+
+// LivenessConfig 心跳和卡住检测的阈值
+type LivenessConfig struct {
+	HeartbeatInterval time.Duration // 发送心跳的间隔
+	HeartbeatTimeout  time.Duration // 心跳超过该时长没有回应视为无响应
+	StuckThreshold    time.Duration // 单条消息处理超过该时长视为卡住
+	MaxRestarts       int           // 每个 Actor 自动重启的次数上限，0 表示不自动重启
+}
+
+// DefaultLivenessConfig 返回默认的存活检查配置
+func DefaultLivenessConfig() LivenessConfig {
+	return LivenessConfig{
+		HeartbeatInterval: defaultHeartbeatInterval,
+		HeartbeatTimeout:  defaultHeartbeatTimeout,
+		StuckThreshold:    defaultStuckThreshold,
+		MaxRestarts:       defaultMaxRestarts,
+	}
+}
+
+// withDefaults 用默认值补全未设置的阈值
+func (c LivenessConfig) withDefaults() LivenessConfig {
+	defaults := DefaultLivenessConfig()
+	if c.HeartbeatInterval <= 0 {
+		c.HeartbeatInterval = defaults.HeartbeatInterval
+	}
+	if c.HeartbeatTimeout <= 0 {
+		c.HeartbeatTimeout = defaults.HeartbeatTimeout
+	}
+	if c.StuckThreshold <= 0 {
+		c.StuckThreshold = defaults.StuckThreshold
+	}
+	if c.MaxRestarts < 0 {
+		c.MaxRestarts = 0
+	}
+	return c
+}
+
+// checkInterval 存活检查的间隔，取各阈值中最小值的一半
+func (c LivenessConfig) checkInterval() time.Duration {
+	interval := c.HeartbeatInterval
+	if c.HeartbeatTimeout < interval {
+		interval = c.HeartbeatTimeout
+	}
+	if c.StuckThreshold < interval {
+		interval = c.StuckThreshold
+	}
+	interval /= 2
+	if interval < minLivenessCheckInterval {
+		interval = minLivenessCheckInterval
+	}
+	return interval
+}
+
+// ActorHealth 已激活 Actor 的存活状态
+type ActorHealth struct {
+	ActorID         string     `json:"actorId"`
+	Status          ActorState `json:"status"`
+	Healthy         bool       `json:"healthy"`
+	Reason          string     `json:"reason,omitempty"`          // unresponsive 或 stuck
+	LastHeartbeatAt *time.Time `json:"lastHeartbeatAt,omitempty"` // 最近一次回应心跳的时间
+	RTTMs           *int64     `json:"rttMs,omitempty"`           // 最近一次心跳的往返时间
+	PendingSince    *time.Time `json:"pendingSince,omitempty"`    // 尚未回应的心跳的发送时间
+	Processing      string     `json:"processing,omitempty"`      // 正在处理的消息
+	ProcessingMs    int64      `json:"processingMs,omitempty"`    // 已处理的时长
+	MailboxDepth    int        `json:"mailboxDepth"`
+	Invocations     int        `json:"invocations"` // 执行中的函数调用，不经过邮箱，不参与卡住检测
+	Failures        int        `json:"failures"`    // 连续未通过检查的次数
+	Restarts        int        `json:"restarts"`    // 累计自动重启次数
+}
+
+// livenessRecord 管理器记录的单个 Actor 的检查结果，重启后保留
+type livenessRecord struct {
+	failures int
+	restarts int
+}
+
+// liveness 存活检查的配置和记录
+type liveness struct {
+	config    LivenessConfig
+	records   map[string]*livenessRecord
+	lastCheck time.Time
+	mu        sync.Mutex
+}
+
+// newLiveness 创建使用默认配置的存活检查
+func newLiveness() *liveness {
+	return &liveness{
+		config:  DefaultLivenessConfig(),
+		records: make(map[string]*livenessRecord),
+	}
+}
+
+// record 返回 Actor 的检查记录，调用方需持有锁
+func (l *liveness) record(actorID string) *livenessRecord {
+	record, ok := l.records[actorID]
+	if !ok {
+		record = &livenessRecord{}
+		l.records[actorID] = record
+	}
+	return record
+}
+
+// SetLiveness 设置心跳间隔、超时、卡住阈值和自动重启上限，需要在 Start 之前调用
+func (am *ActorManager) SetLiveness(config LivenessConfig) {
+	am.liveness.mu.Lock()
+	defer am.liveness.mu.Unlock()
+	am.liveness.config = config.withDefaults()
+}
+
+// startLiveness 定期向已激活的 Actor 发送心跳并检查其存活状态
+func (am *ActorManager) startLiveness() {
+	am.liveness.mu.Lock()
+	interval := am.liveness.config.checkInterval()
+	am.liveness.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				am.checkLiveness(now)
+			case <-am.background.Done():
+				return
+			}
+		}
+	}()
+}
+
+// checkLiveness 检查所有已激活的 Actor，按需发送心跳，并对不健康的 Actor 逐级处理
+func (am *ActorManager) checkLiveness(now time.Time) {
+	am.liveness.mu.Lock()
+	config := am.liveness.config
+	am.liveness.lastCheck = now
+	am.liveness.mu.Unlock()
+
+	for _, behaviorActor := range am.activeBehaviorActors() {
+		if heartbeat := behaviorActor.nextHeartbeat(now, config.HeartbeatInterval); heartbeat != nil {
+			if err := behaviorActor.Send(heartbeat); err != nil {
+				log.Printf("Failed to send heartbeat to actor %s: %v", behaviorActor.ID(), err)
+			}
+		}
+		am.evaluateHealth(behaviorActor, behaviorActor.health(now, config), config)
+	}
+}
+
+// evaluateHealth 根据检查结果逐级处理：第一次未通过时标记为 error 并通知，
+// 之后仍未通过时在上限内自动重启；重新通过检查时恢复为 running 并通知
+func (am *ActorManager) evaluateHealth(behaviorActor *BehaviorActor, health ActorHealth, config LivenessConfig) {
+	actorID := behaviorActor.ID()
+
+	am.liveness.mu.Lock()
+	record := am.liveness.record(actorID)
+	recovered := health.Healthy && record.failures > 0
+	if health.Healthy {
+		record.failures = 0
+	} else {
+		record.failures++
+	}
+	health.Failures = record.failures
+	health.Restarts = record.restarts
+	restart := !health.Healthy && record.failures > 1 && record.restarts < config.MaxRestarts
+	if restart {
+		record.restarts++
+		record.failures = 0
+	}
+	am.liveness.mu.Unlock()
+
+	switch {
+	case recovered:
+		behaviorActor.setStatus("error", "running")
+		log.Printf("Actor %s recovered", actorID)
+		health.Status = behaviorActor.State()
+		am.notifyHealth(ActorRecoveredEvent, health)
+
+	case health.Healthy:
+
+	case health.Failures == 1:
+		behaviorActor.setStatus("running", "error")
+		log.Printf("Actor %s is unhealthy: %s", actorID, describeUnhealthy(health))
+		health.Status = behaviorActor.State()
+		am.notifyHealth(ActorUnhealthyEvent, health)
+
+	case restart:
+		if err := am.restartActor(actorID); err != nil {
+			log.Printf("Failed to restart actor %s: %v", actorID, err)
+			return
+		}
+		log.Printf("Actor %s restarted (%d/%d): %s", actorID, health.Restarts+1, config.MaxRestarts, describeUnhealthy(health))
+		health.Restarts++
+		health.Status = ActorStateRunning
+		am.notifyHealth(ActorRestartedEvent, health)
+
+	case health.Failures == 2:
+		log.Printf("Actor %s is still unhealthy and reached the restart limit %d", actorID, config.MaxRestarts)
+	}
+}
+
+// describeUnhealthy 描述不健康的原因
+func describeUnhealthy(health ActorHealth) string {
+	if health.Reason == UnhealthyStuck {
+		return fmt.Sprintf("stuck on %s for %dms", health.Processing, health.ProcessingMs)
+	}
+	return fmt.Sprintf("no heartbeat reply since %s", health.PendingSince.Format(time.RFC3339))
+}
+
+// restartActor 停止 Actor 并重新激活，从快照和事件日志恢复状态，释放锁后发布 ActorActivated
+// 停止会取消卡住的消息处理，但处理协程要等到步骤响应取消后才退出；
+// 执行中的调用先被取消并等待结束，新实例恢复的序号之后不会再有旧实例写入的事件
+func (am *ActorManager) restartActor(actorID string) error {
+	if err := am.lockDrained(actorID); err != nil {
+		return err
+	}
+	restarted, err := am.restartLocked(actorID)
+	am.mu.Unlock()
+
+	_, err = am.publishActivated(restarted, err)
+	return err
+}
+
+// restartLocked 停止并重新激活 Actor，调用方需持有写锁
+func (am *ActorManager) restartLocked(actorID string) (*BehaviorActor, error) {
+	actor, active := am.actors[actorID]
+	behavior, registered := am.registry[actorID]
+	if !active || !registered {
+		return nil, fmt.Errorf("actor %s is not active", actorID)
+	}
+
+	if err := actor.Stop(); err != nil {
+		return nil, fmt.Errorf("failed to stop actor %s: %v", actorID, err)
+	}
+	delete(am.actors, actorID)
+	if behaviorActor, ok := actor.(*BehaviorActor); ok {
+		am.saveSnapshotLocked(behaviorActor, nil)
+	}
+
+	return am.activateLocked(behavior)
+}
+
+// notifyHealth 把存活状态变化发布到事件总线
+func (am *ActorManager) notifyHealth(event string, health ActorHealth) {
+	am.store.bus.Publish(events.Event{
+		Type:      events.Type(event),
+		Aggregate: events.AggregateActor,
+		ID:        health.ActorID,
+		Data:      health,
+	})
+}
+
+// activeBehaviorActors 返回已激活的 BehaviorActor，按ID排序
+func (am *ActorManager) activeBehaviorActors() []*BehaviorActor {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	actors := make([]*BehaviorActor, 0, len(am.actors))
+	for _, actor := range am.actors {
+		if behaviorActor, ok := actor.(*BehaviorActor); ok {
+			actors = append(actors, behaviorActor)
+		}
+	}
+	sort.Slice(actors, func(i, j int) bool {
+		return actors[i].ID() < actors[j].ID()
+	})
+	return actors
+}
+
+// actorHealthReports 返回已激活 Actor 的当前存活状态
+func (am *ActorManager) actorHealthReports(now time.Time) ([]ActorHealth, LivenessConfig, time.Time) {
+	am.liveness.mu.Lock()
+	config := am.liveness.config
+	lastCheck := am.liveness.lastCheck
+	am.liveness.mu.Unlock()
+
+	actors := am.activeBehaviorActors()
+	reports := make([]ActorHealth, 0, len(actors))
+	for _, behaviorActor := range actors {
+		health := behaviorActor.health(now, config)
+
+		am.liveness.mu.Lock()
+		if record, ok := am.liveness.records[behaviorActor.ID()]; ok {
+			health.Failures = record.failures
+			health.Restarts = record.restarts
+		}
+		am.liveness.mu.Unlock()
+
+		if health.Status == ActorStateError {
+			health.Healthy = false
+		}
+		reports = append(reports, health)
+	}
+	return reports, config, lastCheck
+}
+
+// nextHeartbeat 距上次发送超过间隔且没有待回应的心跳时，返回要发送的心跳并记为待回应
+func (ba *BehaviorActor) nextHeartbeat(now time.Time, interval time.Duration) *Message {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+
+	if !ba.heartbeatPendingSince.IsZero() || now.Sub(ba.heartbeatSentAt) < interval {
+		return nil
+	}
+
+	msg := NewMessage(Heartbeat, "manager", ba.id)
+	msg.SetCorrelationID(fmt.Sprintf("heartbeat_%d", now.UnixNano()))
+	ba.heartbeatID = msg.CorrelationID
+	ba.heartbeatSentAt = now
+	ba.heartbeatPendingSince = now
+	return msg
+}
+
+// ackHeartbeat 记录心跳回应和往返时间，过期的心跳被忽略
+func (ba *BehaviorActor) ackHeartbeat(msg *Message) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+
+	if msg.CorrelationID != ba.heartbeatID {
+		return
+	}
+	now := time.Now()
+	ba.heartbeatAckAt = now
+	ba.heartbeatRTT = now.Sub(ba.heartbeatSentAt)
+	ba.heartbeatPendingSince = time.Time{}
+}
+
+// beginProcessing 记录开始处理的消息，用于卡住检测
+func (ba *BehaviorActor) beginProcessing(msg *Message) {
+	what := string(msg.Type)
+	if msg.Type == FunctionCall {
+		what += ":" + msg.Function
+	} else if msg.Type == Event {
+		what += ":" + msg.Topic
+	}
+
+	ba.mu.Lock()
+	ba.processingSince = time.Now()
+	ba.processingMessage = what
+	ba.mu.Unlock()
+}
+
+// endProcessing 清除正在处理的消息
+func (ba *BehaviorActor) endProcessing() {
+	ba.mu.Lock()
+	ba.processingSince = time.Time{}
+	ba.processingMessage = ""
+	ba.mu.Unlock()
+}
+
+// setStatus 状态为 from 时改为 to
+func (ba *BehaviorActor) setStatus(from, to string) {
+	ba.mu.Lock()
+	defer ba.mu.Unlock()
+	if ba.Status == from {
+		ba.Status = to
+	}
+}
+
+// health 按阈值判断 Actor 的存活状态：处理单条消息超过卡住阈值为 stuck，
+// 空闲时心跳超时没有回应为 unresponsive
+func (ba *BehaviorActor) health(now time.Time, config LivenessConfig) ActorHealth {
+	ba.mu.RLock()
+	health := ActorHealth{
+		ActorID:      ba.id,
+		Healthy:      true,
+		MailboxDepth: ba.mailbox.Len(),
+		Invocations:  len(ba.mailbox.invocations),
+	}
+	if !ba.heartbeatAckAt.IsZero() {
+		ackAt := ba.heartbeatAckAt
+		rtt := ba.heartbeatRTT.Milliseconds()
+		health.LastHeartbeatAt = &ackAt
+		health.RTTMs = &rtt
+	}
+	if !ba.heartbeatPendingSince.IsZero() {
+		pendingSince := ba.heartbeatPendingSince
+		health.PendingSince = &pendingSince
+	}
+	if !ba.processingSince.IsZero() {
+		health.Processing = ba.processingMessage
+		health.ProcessingMs = now.Sub(ba.processingSince).Milliseconds()
+	}
+	stuck := !ba.processingSince.IsZero() && now.Sub(ba.processingSince) > config.StuckThreshold
+	// 正在处理消息时心跳排在其后，是否正常由卡住阈值判断
+	unresponsive := ba.processingSince.IsZero() && health.PendingSince != nil && now.Sub(*health.PendingSince) > config.HeartbeatTimeout
+	ba.mu.RUnlock()
+
+	health.Status = ba.State()
+	switch {
+	case stuck:
+		health.Healthy = false
+		health.Reason = UnhealthyStuck
+	case unresponsive:
+		health.Healthy = false
+		health.Reason = UnhealthyUnresponsive
+	}
+	return health
+}
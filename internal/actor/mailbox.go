@@ -0,0 +1,317 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"uros-restron/internal/models"
+)
+
+// OverflowPolicy 邮箱已满时的处理策略
+type OverflowPolicy string
+
+const (
+	// OverflowBlock 等待邮箱出现空位，超过期限后返回 ErrMailboxTimeout
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropNewest 丢弃新消息，发送方不会收到错误
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowDropOldest 丢弃最早的消息，为新消息腾出空位
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowReject 立即返回 ErrMailboxFull
+	OverflowReject OverflowPolicy = "reject"
+)
+
+// 邮箱默认配置
+const (
+	defaultMailboxCapacity     = 100
+	defaultMailboxBlockTimeout = 5 * time.Second
+	defaultMailboxOverflow     = OverflowBlock
+	defaultMaxInvocations      = 16
+
+	// priorityLaneCapacity 优先通道的容量，已满时丢弃最早的控制消息
+	priorityLaneCapacity = 32
+)
+
+var (
+	// ErrMailboxFull 邮箱已满且策略为 reject
+	ErrMailboxFull = errors.New("mailbox is full")
+	// ErrMailboxTimeout 邮箱已满且在期限内没有空位
+	ErrMailboxTimeout = errors.New("timed out waiting for mailbox capacity")
+	// ErrInvocationLimit 同时执行的函数调用已达上限
+	ErrInvocationLimit = errors.New("too many concurrent invocations")
+)
+
+// MailboxConfig 邮箱容量和溢出策略
+// 函数调用（HTTP、定时、规则和 Ditto messages）不经过邮箱，在调用方的 goroutine 中执行，
+// 同时执行的数量受 MaxInvocations 限制：block 策略等待空位最多 BlockTimeout，其他策略立即拒绝
+type MailboxConfig struct {
+	Capacity       int
+	Overflow       OverflowPolicy
+	BlockTimeout   time.Duration // 仅用于 block 策略
+	MaxInvocations int           // 同时执行的函数调用上限
+}
+
+// DefaultMailboxConfig 返回默认的邮箱配置：容量 100，已满时最多等待 5 秒，最多同时执行 16 个调用
+func DefaultMailboxConfig() MailboxConfig {
+	return MailboxConfig{
+		Capacity:       defaultMailboxCapacity,
+		Overflow:       defaultMailboxOverflow,
+		BlockTimeout:   defaultMailboxBlockTimeout,
+		MaxInvocations: defaultMaxInvocations,
+	}
+}
+
+// withDefaults 用默认配置补全未设置或无效的字段
+func (c MailboxConfig) withDefaults() MailboxConfig {
+	defaults := DefaultMailboxConfig()
+	if c.Capacity <= 0 {
+		c.Capacity = defaults.Capacity
+	}
+	if !validOverflowPolicy(c.Overflow) {
+		c.Overflow = defaults.Overflow
+	}
+	if c.BlockTimeout <= 0 {
+		c.BlockTimeout = defaults.BlockTimeout
+	}
+	if c.MaxInvocations <= 0 {
+		c.MaxInvocations = defaults.MaxInvocations
+	}
+	return c
+}
+
+// withBehavior 用行为定义中的邮箱设置覆盖配置，未设置的字段保持不变
+func (c MailboxConfig) withBehavior(settings models.MailboxSettings) MailboxConfig {
+	if settings.Capacity > 0 {
+		c.Capacity = settings.Capacity
+	}
+	if settings.Overflow != "" {
+		c.Overflow = OverflowPolicy(settings.Overflow)
+	}
+	if settings.BlockTimeoutMs > 0 {
+		c.BlockTimeout = time.Duration(settings.BlockTimeoutMs) * time.Millisecond
+	}
+	if settings.MaxInvocations > 0 {
+		c.MaxInvocations = settings.MaxInvocations
+	}
+	return c.withDefaults()
+}
+
+// validOverflowPolicy 判断是否为支持的溢出策略
+func validOverflowPolicy(policy OverflowPolicy) bool {
+	switch policy {
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowReject:
+		return true
+	}
+	return false
+}
+
+// Mailbox Actor 的邮箱，普通消息按配置的容量和溢出策略排队，
+// 心跳、状态查询和响应等控制消息走独立的优先通道，不受普通消息积压的影响；
+// 同时持有函数调用的执行名额，限制绕过邮箱执行的调用数
+type Mailbox struct {
+	owner       string
+	config      MailboxConfig
+	messages    chan *Message
+	priority    chan *Message
+	invocations chan struct{} // 执行中的函数调用占用的名额
+	done        chan struct{}
+	closed      int32
+
+	enqueued            int64
+	dropped             int64 // drop_newest 和 drop_oldest 丢弃的消息
+	rejected            int64 // reject 和 block 超时拒绝的消息
+	invocationsRejected int64 // 超过同时执行上限被拒绝的调用
+}
+
+// NewMailbox 创建邮箱，未设置的配置使用默认值
+func NewMailbox(owner string, config MailboxConfig) *Mailbox {
+	config = config.withDefaults()
+	return &Mailbox{
+		owner:       owner,
+		config:      config,
+		messages:    make(chan *Message, config.Capacity),
+		priority:    make(chan *Message, priorityLaneCapacity),
+		invocations: make(chan struct{}, config.MaxInvocations),
+		done:        make(chan struct{}),
+	}
+}
+
+// isControlMessage 判断消息是否走优先通道
+func isControlMessage(msg *Message) bool {
+	switch msg.Type {
+	case Heartbeat, StatusQuery, StatusResponse, FunctionResponse, Error:
+		return true
+	}
+	return false
+}
+
+// Put 投递消息，邮箱已关闭时返回 ErrActorStopped
+func (m *Mailbox) Put(msg *Message) error {
+	if m.isClosed() {
+		return fmt.Errorf("%w: %s", ErrActorStopped, m.owner)
+	}
+	if isControlMessage(msg) {
+		m.putPriority(msg)
+		return nil
+	}
+
+	select {
+	case m.messages <- msg:
+		atomic.AddInt64(&m.enqueued, 1)
+		return nil
+	default:
+	}
+
+	switch m.config.Overflow {
+	case OverflowDropNewest:
+		atomic.AddInt64(&m.dropped, 1)
+		log.Printf("Mailbox of actor %s is full, dropping new %s from %s", m.owner, msg.Type, msg.From)
+		return nil
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case m.messages <- msg:
+				atomic.AddInt64(&m.enqueued, 1)
+				return nil
+			default:
+			}
+			select {
+			case oldest := <-m.messages:
+				atomic.AddInt64(&m.dropped, 1)
+				log.Printf("Mailbox of actor %s is full, dropping oldest %s from %s", m.owner, oldest.Type, oldest.From)
+			default:
+			}
+		}
+
+	case OverflowReject:
+		atomic.AddInt64(&m.rejected, 1)
+		return fmt.Errorf("actor %s: %w", m.owner, ErrMailboxFull)
+
+	default:
+		timer := time.NewTimer(m.config.BlockTimeout)
+		defer timer.Stop()
+
+		select {
+		case m.messages <- msg:
+			atomic.AddInt64(&m.enqueued, 1)
+			return nil
+		case <-m.done:
+			return fmt.Errorf("%w: %s", ErrActorStopped, m.owner)
+		case <-timer.C:
+			atomic.AddInt64(&m.rejected, 1)
+			return fmt.Errorf("actor %s: %w after %v", m.owner, ErrMailboxTimeout, m.config.BlockTimeout)
+		}
+	}
+}
+
+// putPriority 投递控制消息，优先通道已满时丢弃最早的控制消息
+func (m *Mailbox) putPriority(msg *Message) {
+	for {
+		select {
+		case m.priority <- msg:
+			atomic.AddInt64(&m.enqueued, 1)
+			return
+		default:
+		}
+		select {
+		case <-m.priority:
+			atomic.AddInt64(&m.dropped, 1)
+		default:
+		}
+	}
+}
+
+// Receive 取出下一条消息，优先通道中的消息先于普通消息
+// 邮箱关闭或 ctx 结束时返回 false
+func (m *Mailbox) Receive(ctx context.Context) (*Message, bool) {
+	select {
+	case msg := <-m.priority:
+		return msg, true
+	default:
+	}
+
+	select {
+	case msg := <-m.priority:
+		return msg, true
+	case msg := <-m.messages:
+		return msg, true
+	case <-m.done:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// acquireInvocation 占用一个函数调用名额，返回释放名额的函数
+// 名额已满时，block 策略等待空位最多 BlockTimeout，其他策略立即返回 ErrInvocationLimit
+// 调用方在等待结果，丢弃调用等同于拒绝
+func (m *Mailbox) acquireInvocation(ctx context.Context) (func(), error) {
+	release := func() { <-m.invocations }
+
+	select {
+	case m.invocations <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if m.config.Overflow != OverflowBlock {
+		atomic.AddInt64(&m.invocationsRejected, 1)
+		return nil, fmt.Errorf("actor %s: %w (limit %d)", m.owner, ErrInvocationLimit, m.config.MaxInvocations)
+	}
+
+	timer := time.NewTimer(m.config.BlockTimeout)
+	defer timer.Stop()
+
+	select {
+	case m.invocations <- struct{}{}:
+		return release, nil
+	case <-m.done:
+		return nil, fmt.Errorf("%w: %s", ErrActorStopped, m.owner)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		atomic.AddInt64(&m.invocationsRejected, 1)
+		return nil, fmt.Errorf("actor %s: %w (limit %d) after %v", m.owner, ErrInvocationLimit, m.config.MaxInvocations, m.config.BlockTimeout)
+	}
+}
+
+// Close 关闭邮箱，之后的投递返回 ErrActorStopped，等待空位的发送方立即返回
+// 可以重复调用
+func (m *Mailbox) Close() {
+	if atomic.CompareAndSwapInt32(&m.closed, 0, 1) {
+		close(m.done)
+	}
+}
+
+// isClosed 判断邮箱是否已关闭
+func (m *Mailbox) isClosed() bool {
+	return atomic.LoadInt32(&m.closed) == 1
+}
+
+// Len 返回排队中的消息数，包括优先通道
+func (m *Mailbox) Len() int {
+	return len(m.messages) + len(m.priority)
+}
+
+// Stats 返回邮箱的容量、深度和溢出统计
+func (m *Mailbox) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"capacity":       m.config.Capacity,
+		"depth":          len(m.messages),
+		"priority_depth": len(m.priority),
+		"overflow":       string(m.config.Overflow),
+		"block_timeout":  m.config.BlockTimeout.String(),
+		"enqueued":       atomic.LoadInt64(&m.enqueued),
+		"dropped":        atomic.LoadInt64(&m.dropped),
+		"rejected":       atomic.LoadInt64(&m.rejected),
+
+		"invocations":          len(m.invocations),
+		"max_invocations":      m.config.MaxInvocations,
+		"invocations_rejected": atomic.LoadInt64(&m.invocationsRejected),
+	}
+}
@@ -0,0 +1,359 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"uros-restron/internal/models"
+)
+
+// topicMessage 创建以主题区分的普通消息
+func topicMessage(topic string) *Message {
+	return NewEventMessage("test", "owner", topic, nil)
+}
+
+// controlMessage 创建以主题区分的控制消息
+func controlMessage(msgType MessageType, topic string) *Message {
+	msg := NewMessage(msgType, "test", "owner")
+	msg.Topic = topic
+	return msg
+}
+
+// drain 依次取出邮箱中排队的消息主题
+func drain(t *testing.T, m *Mailbox) []string {
+	t.Helper()
+	var topics []string
+	for m.Len() > 0 {
+		msg, ok := m.Receive(context.Background())
+		if !ok {
+			t.Fatal("Receive returned false with queued messages")
+		}
+		topics = append(topics, msg.Topic)
+	}
+	return topics
+}
+
+// assertStats 检查邮箱统计中的计数
+func assertStats(t *testing.T, m *Mailbox, want map[string]int64) {
+	t.Helper()
+	stats := m.Stats()
+	for key, value := range want {
+		if stats[key] != value {
+			t.Errorf("expected %s %d, got %v", key, value, stats[key])
+		}
+	}
+}
+
+func TestMailboxOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy   OverflowPolicy
+		err      error
+		queued   []string
+		enqueued int64
+		dropped  int64
+		rejected int64
+	}{
+		{OverflowDropNewest, nil, []string{"1", "2"}, 2, 1, 0},
+		{OverflowDropOldest, nil, []string{"2", "3"}, 3, 1, 0},
+		{OverflowReject, ErrMailboxFull, []string{"1", "2"}, 2, 0, 1},
+		{OverflowBlock, ErrMailboxTimeout, []string{"1", "2"}, 2, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			m := NewMailbox("owner", MailboxConfig{Capacity: 2, Overflow: tt.policy, BlockTimeout: 20 * time.Millisecond})
+
+			for _, topic := range []string{"1", "2"} {
+				if err := m.Put(topicMessage(topic)); err != nil {
+					t.Fatalf("put %s failed: %v", topic, err)
+				}
+			}
+			err := m.Put(topicMessage("3"))
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("put to full mailbox returned %v, want %v", err, tt.err)
+			}
+
+			assertStats(t, m, map[string]int64{"enqueued": tt.enqueued, "dropped": tt.dropped, "rejected": tt.rejected})
+			if topics := drain(t, m); !reflect.DeepEqual(topics, tt.queued) {
+				t.Fatalf("expected queued %v, got %v", tt.queued, topics)
+			}
+		})
+	}
+}
+
+func TestMailboxBlockWaitsForCapacity(t *testing.T) {
+	m := NewMailbox("owner", MailboxConfig{Capacity: 1, Overflow: OverflowBlock, BlockTimeout: 5 * time.Second})
+	if err := m.Put(topicMessage("1")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- m.Put(topicMessage("2"))
+	}()
+
+	select {
+	case err := <-result:
+		t.Fatalf("put to full mailbox returned %v before capacity was freed", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if msg, ok := m.Receive(context.Background()); !ok || msg.Topic != "1" {
+		t.Fatalf("expected message 1, got %v", msg)
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("blocked put failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked put did not complete after capacity was freed")
+	}
+	if topics := drain(t, m); !reflect.DeepEqual(topics, []string{"2"}) {
+		t.Fatalf("expected queued [2], got %v", topics)
+	}
+}
+
+func TestMailboxCloseReleasesBlockedSender(t *testing.T) {
+	m := NewMailbox("owner", MailboxConfig{Capacity: 1, Overflow: OverflowBlock, BlockTimeout: 5 * time.Second})
+	if err := m.Put(topicMessage("1")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- m.Put(topicMessage("2"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	m.Close()
+	m.Close()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrActorStopped) {
+			t.Fatalf("expected ErrActorStopped, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked put did not return after close")
+	}
+
+	if err := m.Put(topicMessage("3")); !errors.Is(err, ErrActorStopped) {
+		t.Fatalf("expected ErrActorStopped after close, got %v", err)
+	}
+	if err := m.Put(controlMessage(Heartbeat, "h")); !errors.Is(err, ErrActorStopped) {
+		t.Fatalf("expected ErrActorStopped for control message after close, got %v", err)
+	}
+}
+
+func TestMailboxReceiveStops(t *testing.T) {
+	m := NewMailbox("owner", MailboxConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if msg, ok := m.Receive(ctx); ok {
+		t.Fatalf("expected Receive to stop when ctx ends, got %v", msg)
+	}
+
+	m.Close()
+	if msg, ok := m.Receive(context.Background()); ok {
+		t.Fatalf("expected Receive to stop after close, got %v", msg)
+	}
+}
+
+func TestMailboxPriorityLane(t *testing.T) {
+	m := NewMailbox("owner", MailboxConfig{Capacity: 1, Overflow: OverflowReject})
+
+	if err := m.Put(topicMessage("event")); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if err := m.Put(topicMessage("rejected")); !errors.Is(err, ErrMailboxFull) {
+		t.Fatalf("expected ErrMailboxFull, got %v", err)
+	}
+
+	// 普通消息已满时控制消息仍然可以投递，并先于普通消息取出
+	for _, msgType := range []MessageType{Heartbeat, StatusQuery, StatusResponse, FunctionResponse, Error} {
+		if err := m.Put(controlMessage(msgType, string(msgType))); err != nil {
+			t.Fatalf("put %s to full mailbox failed: %v", msgType, err)
+		}
+	}
+	if err := m.Put(NewFunctionCallMessage("test", "owner", "f", nil)); !errors.Is(err, ErrMailboxFull) {
+		t.Fatalf("expected function call to use the normal lane, got %v", err)
+	}
+
+	want := []string{"heartbeat", "status_query", "status_response", "function_response", "error", "event"}
+	if topics := drain(t, m); !reflect.DeepEqual(topics, want) {
+		t.Fatalf("expected %v, got %v", want, topics)
+	}
+	assertStats(t, m, map[string]int64{"enqueued": 6, "rejected": 2, "dropped": 0})
+}
+
+func TestMailboxPriorityLaneDropsOldest(t *testing.T) {
+	m := NewMailbox("owner", MailboxConfig{Capacity: 1, Overflow: OverflowReject})
+
+	const extra = 3
+	for i := 0; i < priorityLaneCapacity+extra; i++ {
+		if err := m.Put(controlMessage(Heartbeat, fmt.Sprint(i))); err != nil {
+			t.Fatalf("put heartbeat %d failed: %v", i, err)
+		}
+	}
+
+	topics := drain(t, m)
+	if len(topics) != priorityLaneCapacity {
+		t.Fatalf("expected %d queued control messages, got %d", priorityLaneCapacity, len(topics))
+	}
+	if topics[0] != fmt.Sprint(extra) || topics[len(topics)-1] != fmt.Sprint(priorityLaneCapacity+extra-1) {
+		t.Fatalf("expected the oldest %d heartbeats to be dropped, got %v", extra, topics)
+	}
+	assertStats(t, m, map[string]int64{"dropped": extra})
+}
+
+func TestMailboxInvocationLimit(t *testing.T) {
+	m := NewMailbox("owner", MailboxConfig{Overflow: OverflowReject, MaxInvocations: 2})
+	ctx := context.Background()
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := m.acquireInvocation(ctx)
+		if err != nil {
+			t.Fatalf("acquire %d failed: %v", i+1, err)
+		}
+		releases = append(releases, release)
+	}
+	if _, err := m.acquireInvocation(ctx); !errors.Is(err, ErrInvocationLimit) {
+		t.Fatalf("expected ErrInvocationLimit, got %v", err)
+	}
+	assertStats(t, m, map[string]int64{"invocations_rejected": 1})
+	if stats := m.Stats(); stats["invocations"] != 2 {
+		t.Fatalf("expected 2 running invocations, got %v", stats["invocations"])
+	}
+
+	releases[0]()
+	release, err := m.acquireInvocation(ctx)
+	if err != nil {
+		t.Fatalf("acquire after release failed: %v", err)
+	}
+	release()
+	releases[1]()
+	if stats := m.Stats(); stats["invocations"] != 0 {
+		t.Fatalf("expected no running invocations, got %v", stats["invocations"])
+	}
+}
+
+func TestMailboxInvocationLimitBlocks(t *testing.T) {
+	m := NewMailbox("owner", MailboxConfig{Overflow: OverflowBlock, MaxInvocations: 1, BlockTimeout: 50 * time.Millisecond})
+	release, err := m.acquireInvocation(context.Background())
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	// 等待超过期限
+	if _, err := m.acquireInvocation(context.Background()); !errors.Is(err, ErrInvocationLimit) {
+		t.Fatalf("expected ErrInvocationLimit after block timeout, got %v", err)
+	}
+	assertStats(t, m, map[string]int64{"invocations_rejected": 1})
+
+	// 调用方取消
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := m.acquireInvocation(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	release()
+}
+
+func TestMailboxInvocationLimitWaits(t *testing.T) {
+	m := NewMailbox("owner", MailboxConfig{Overflow: OverflowBlock, MaxInvocations: 1, BlockTimeout: 5 * time.Second})
+	release, err := m.acquireInvocation(context.Background())
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	// 名额释放后等待中的调用继续执行
+	result := make(chan error, 1)
+	go func() {
+		next, err := m.acquireInvocation(context.Background())
+		if err == nil {
+			next()
+		}
+		result <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	release()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("blocked acquire failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked acquire did not complete after release")
+	}
+
+	// 邮箱关闭时等待中的调用立即返回
+	release, err = m.acquireInvocation(context.Background())
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	defer release()
+	go func() {
+		result <- func() error {
+			_, err := m.acquireInvocation(context.Background())
+			return err
+		}()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	m.Close()
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrActorStopped) {
+			t.Fatalf("expected ErrActorStopped, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked acquire did not return after close")
+	}
+}
+
+func TestMailboxConfig(t *testing.T) {
+	defaults := DefaultMailboxConfig()
+
+	tests := []struct {
+		name     string
+		config   MailboxConfig
+		settings models.MailboxSettings
+		want     MailboxConfig
+	}{
+		{"empty uses defaults", MailboxConfig{}, models.MailboxSettings{}, defaults},
+		{"unknown policy falls back", MailboxConfig{Overflow: "spill"}, models.MailboxSettings{}, defaults},
+		{"negative values fall back", MailboxConfig{Capacity: -1, BlockTimeout: -time.Second, MaxInvocations: -1}, models.MailboxSettings{}, defaults},
+		{
+			"behavior overrides",
+			MailboxConfig{Capacity: 10, Overflow: OverflowReject},
+			models.MailboxSettings{Capacity: 5, Overflow: "drop_oldest", BlockTimeoutMs: 250, MaxInvocations: 3},
+			MailboxConfig{Capacity: 5, Overflow: OverflowDropOldest, BlockTimeout: 250 * time.Millisecond, MaxInvocations: 3},
+		},
+		{
+			"unset behavior fields keep config",
+			MailboxConfig{Capacity: 10, Overflow: OverflowReject, BlockTimeout: time.Second, MaxInvocations: 4},
+			models.MailboxSettings{},
+			MailboxConfig{Capacity: 10, Overflow: OverflowReject, BlockTimeout: time.Second, MaxInvocations: 4},
+		},
+		{
+			"invalid behavior policy falls back to default",
+			MailboxConfig{Overflow: OverflowReject},
+			models.MailboxSettings{Overflow: "spill"},
+			defaults,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.withBehavior(tt.settings); got != tt.want {
+				t.Fatalf("withBehavior = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -21,18 +21,22 @@ const (
 	Error MessageType = "error"
 	// Heartbeat 心跳消息
 	Heartbeat MessageType = "heartbeat"
+	// Event 主题消息
+	Event MessageType = "event"
 )
 
 // Message Actor 消息结构
 type Message struct {
-	ID            string                 `json:"id"`             // 消息ID
-	Type          MessageType            `json:"type"`           // 消息类型
-	From          string                 `json:"from"`           // 发送者
-	To            string                 `json:"to"`             // 接收者
-	Function      string                 `json:"function"`       // 要调用的函数名
-	Payload       map[string]interface{} `json:"payload"`        // 消息载荷
-	Timestamp     time.Time              `json:"timestamp"`      // 时间戳
-	CorrelationID string                 `json:"correlation_id"` // 关联ID，用于请求-响应匹配
+	ID            string                 `json:"id"`                 // 消息ID
+	Type          MessageType            `json:"type"`               // 消息类型
+	From          string                 `json:"from"`               // 发送者
+	To            string                 `json:"to"`                 // 接收者
+	Function      string                 `json:"function"`           // 要调用的函数名
+	Payload       map[string]interface{} `json:"payload"`            // 消息载荷
+	Timestamp     time.Time              `json:"timestamp"`          // 时间戳
+	CorrelationID string                 `json:"correlation_id"`     // 关联ID，用于请求-响应匹配
+	ThingID       string                 `json:"thing_id,omitempty"` // 函数执行针对的 Thing
+	Topic         string                 `json:"topic,omitempty"`    // 主题消息的主题
 }
 
 // NewMessage 创建新消息
@@ -68,6 +72,14 @@ func NewFunctionResponseMessage(from, to string, success bool, result map[string
 	return msg
 }
 
+// NewEventMessage 创建主题消息
+func NewEventMessage(from, to, topic string, payload map[string]interface{}) *Message {
+	msg := NewMessage(Event, from, to)
+	msg.Topic = topic
+	msg.Payload = payload
+	return msg
+}
+
 // NewStatusMessage 创建状态消息
 func NewStatusMessage(from, to, status string, details map[string]interface{}) *Message {
 	msg := NewMessage(StatusResponse, from, to)
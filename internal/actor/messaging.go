@@ -0,0 +1,146 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"uros-restron/internal/models"
+)
+
+// isMessagingAction 判断动作是否向其他 Actor 发送消息
+func isMessagingAction(action string) bool {
+	switch action {
+	case models.ActionSendMessage, models.ActionCallActor, models.ActionPublish:
+		return true
+	}
+	return false
+}
+
+// setRouter 设置消息动作使用的路由
+func (fe *FunctionExecutor) setRouter(router *Router) {
+	fe.router = router
+}
+
+// executeMessaging 执行消息动作，params 作为接收方的函数参数或主题消息载荷
+func (fe *FunctionExecutor) executeMessaging(ctx context.Context, step models.ImplementationStep, params map[string]interface{}) (map[string]interface{}, error) {
+	if fe.router == nil {
+		return nil, fmt.Errorf("action %s requires the actor to be managed by an ActorManager", step.Action)
+	}
+
+	if step.Action == models.ActionPublish {
+		if step.Topic == "" {
+			return nil, fmt.Errorf("publish requires a topic")
+		}
+		delivered := fe.router.Publish(fe.behavior.ID, step.Topic, params)
+		return map[string]interface{}{
+			"topic":     step.Topic,
+			"delivered": delivered,
+		}, nil
+	}
+
+	if step.Target == nil || step.Function == "" {
+		return nil, fmt.Errorf("%s requires a target and a function", step.Action)
+	}
+	destinations, err := fe.router.Resolve(ctx, *step.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	if step.Action == models.ActionSendMessage {
+		return fe.sendMessages(step.Function, destinations, params)
+	}
+	return fe.callActors(ctx, step.Function, destinations, params)
+}
+
+// sendMessages 向每个接收方发送函数调用消息，不等待结果
+func (fe *FunctionExecutor) sendMessages(function string, destinations []Destination, params map[string]interface{}) (map[string]interface{}, error) {
+	targets := make([]interface{}, 0, len(destinations))
+	for _, destination := range destinations {
+		msg := NewFunctionCallMessage(fe.behavior.ID, destination.ActorID, function, copyLocals(params))
+		msg.ThingID = destination.ThingID
+		if err := fe.router.Send(msg); err != nil {
+			return nil, fmt.Errorf("failed to send %s to actor %s: %v", function, destination.ActorID, err)
+		}
+		targets = append(targets, destinationMap(destination))
+	}
+
+	return map[string]interface{}{
+		"sent":    len(targets),
+		"targets": targets,
+	}, nil
+}
+
+// callActors 并发调用每个接收方的函数并等待结果，任一调用失败时步骤失败
+// 输出 results 按接收方顺序列出结果，result 为第一个接收方的结果
+func (fe *FunctionExecutor) callActors(ctx context.Context, function string, destinations []Destination, params map[string]interface{}) (map[string]interface{}, error) {
+	results := make([]interface{}, len(destinations))
+	errs := make([]error, len(destinations))
+
+	var wg sync.WaitGroup
+	for i, destination := range destinations {
+		wg.Add(1)
+		go func(i int, destination Destination) {
+			defer wg.Done()
+			result, err := fe.call(ctx, function, destination, params)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			entry := destinationMap(destination)
+			entry["result"] = result
+			results[i] = entry
+		}(i, destination)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	output := map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+	}
+	if len(results) > 0 {
+		output["result"] = results[0].(map[string]interface{})["result"]
+	}
+	return output, nil
+}
+
+// call 调用接收方的函数并返回结果
+// 接收方是当前 Actor 时直接执行，当前函数可能正由邮箱处理，经邮箱请求会等待自身
+func (fe *FunctionExecutor) call(ctx context.Context, function string, destination Destination, params map[string]interface{}) (interface{}, error) {
+	if destination.ActorID == fe.behavior.ID {
+		callCtx := WithCaller(WithThingID(ctx, destination.ThingID), "actor:"+fe.behavior.ID)
+		result, err := fe.router.manager.InvokeFunction(callCtx, destination.ActorID, function, copyLocals(params))
+		if err != nil {
+			return nil, fmt.Errorf("actor %s: %v", destination.ActorID, err)
+		}
+		return result.Output, nil
+	}
+
+	msg := NewFunctionCallMessage(fe.behavior.ID, destination.ActorID, function, copyLocals(params))
+	msg.ThingID = destination.ThingID
+
+	response, err := fe.router.Request(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if success, _ := response.Payload["success"].(bool); !success {
+		return nil, fmt.Errorf("actor %s: %v", destination.ActorID, response.Payload["error"])
+	}
+	return response.Payload["result"], nil
+}
+
+// destinationMap 把接收方转换为步骤输出
+func destinationMap(destination Destination) map[string]interface{} {
+	entry := map[string]interface{}{"actor": destination.ActorID}
+	if destination.ThingID != "" {
+		entry["thing"] = destination.ThingID
+	}
+	return entry
+}
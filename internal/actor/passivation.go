@@ -0,0 +1,312 @@
+package actor
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"uros-restron/internal/events"
+	"uros-restron/internal/models"
+)
+
+// minPassivationInterval 空闲检查的最短间隔
+const minPassivationInterval = time.Second
+
+// SetPassivation 设置快照服务和空闲超时，空闲超过 idleTimeout 的 Actor 会被钝化
+// idleTimeout 为 0 时不钝化；需要在 Start 之前调用
+func (am *ActorManager) SetPassivation(service *models.ActorSnapshotService, idleTimeout time.Duration) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.store.snapshots = service
+	am.idleTimeout = idleTimeout
+}
+
+// RegisterBehavior 登记行为对应的 Actor，Actor 在第一次被使用时才激活
+// 函数订阅的主题立即生效，发布到主题的消息会激活休眠的订阅者
+func (am *ActorManager) RegisterBehavior(behavior *models.Behavior) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.registerLocked(behavior)
+}
+
+// registerLocked 登记行为并订阅其主题，调用方需持有写锁
+func (am *ActorManager) registerLocked(behavior *models.Behavior) {
+	am.registry[behavior.ID] = behavior
+	for _, topic := range behaviorTopics(behavior) {
+		am.router.Subscribe(topic, behavior.ID)
+	}
+}
+
+// activate 激活已登记但处于休眠状态的 Actor
+func (am *ActorManager) activate(actorID string) (Actor, error) {
+	am.mu.Lock()
+	if actor, exists := am.actors[actorID]; exists {
+		am.mu.Unlock()
+		return actor, nil
+	}
+	behavior, registered := am.registry[actorID]
+	if !registered {
+		am.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrActorNotFound, actorID)
+	}
+	actor, err := am.activateLocked(behavior)
+	am.mu.Unlock()
+
+	return am.publishActivated(actor, err)
+}
+
+// publishActivated 在释放写锁之后发布 ActorActivated，事件订阅者可能回调管理器
+func (am *ActorManager) publishActivated(actor *BehaviorActor, err error) (Actor, error) {
+	if err != nil {
+		return nil, err
+	}
+	am.publishLifecycle(events.ActorActivated, actor.ID())
+	return actor, nil
+}
+
+// activateLocked 创建并启动 Actor，存在快照时恢复其状态，调用方需持有写锁
+// 不发布事件，调用方释放锁后通过 publishActivated 发布
+func (am *ActorManager) activateLocked(behavior *models.Behavior) (*BehaviorActor, error) {
+	actor := NewBehaviorActor(behavior)
+	actor.setMailboxDefaults(am.mailboxDefaults)
+	actor.SetThingStateProvider(am.stateProvider)
+	actor.setRouter(am.router)
+
+	// 从快照和之后的事件恢复状态
+	actor.store = am.store
+	state, err := am.store.recover(behavior.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover actor %s: %v", behavior.ID, err)
+	}
+	actor.restoreState(state)
+	actor.activations++
+
+	if err := actor.Start(am.ctx); err != nil {
+		return nil, fmt.Errorf("failed to start actor for behavior %s: %v", behavior.ID, err)
+	}
+
+	am.actors[behavior.ID] = actor
+	am.activations++
+	return actor, nil
+}
+
+// startPassivation 定期钝化空闲的 Actor
+func (am *ActorManager) startPassivation() {
+	am.mu.RLock()
+	idleTimeout := am.idleTimeout
+	am.mu.RUnlock()
+	if idleTimeout <= 0 {
+		return
+	}
+
+	interval := idleTimeout / 2
+	if interval < minPassivationInterval {
+		interval = minPassivationInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				am.passivateIdleActors()
+			case <-am.background.Done():
+				return
+			}
+		}
+	}()
+}
+
+// passivateIdleActors 钝化空闲超时、邮箱为空且没有执行中调用的 Actor，并保存快照
+func (am *ActorManager) passivateIdleActors() {
+	now := time.Now()
+	am.passivate(now, func(behaviorActor *BehaviorActor) bool {
+		return now.Sub(behaviorActor.lastActive()) >= am.idleTimeout
+	})
+}
+
+// PassivateAll 钝化所有邮箱为空且没有执行中调用的 Actor，返回被钝化的数量
+// 集群成员变化时调用，Actor 在下次被使用时按新的归属重新激活
+func (am *ActorManager) PassivateAll() int {
+	return am.passivate(time.Now(), func(*BehaviorActor) bool {
+		return true
+	})
+}
+
+// passivate 钝化满足条件、邮箱为空且没有执行中调用的 Actor 并保存快照，返回被钝化的数量
+func (am *ActorManager) passivate(now time.Time, eligible func(*BehaviorActor) bool) int {
+	am.mu.Lock()
+	var passivated []*BehaviorActor
+	for actorID, actor := range am.actors {
+		behaviorActor, ok := actor.(*BehaviorActor)
+		if !ok || !eligible(behaviorActor) {
+			continue
+		}
+		if behaviorActor.mailbox.Len() > 0 || am.hasRunningInvocations(actorID) {
+			continue
+		}
+
+		if err := behaviorActor.Stop(); err != nil {
+			log.Printf("Failed to passivate actor %s: %v", actorID, err)
+			continue
+		}
+		delete(am.actors, actorID)
+		am.passivations++
+		passivated = append(passivated, behaviorActor)
+	}
+	am.mu.Unlock()
+
+	for _, behaviorActor := range passivated {
+		am.saveSnapshot(behaviorActor, &now)
+		am.publishLifecycle(events.ActorPassivated, behaviorActor.ID())
+		log.Printf("Actor %s passivated after being idle for %v", behaviorActor.ID(), now.Sub(behaviorActor.lastActive()).Round(time.Second))
+	}
+	return len(passivated)
+}
+
+// hasRunningInvocations 判断 Actor 是否有执行中的函数调用
+func (am *ActorManager) hasRunningInvocations(actorID string) bool {
+	am.runningMu.Lock()
+	defer am.runningMu.Unlock()
+
+	for _, run := range am.running {
+		if run.invocation.ActorID == actorID {
+			return true
+		}
+	}
+	return false
+}
+
+// saveSnapshot 保存 Actor 的快照，保存失败只记录日志
+func (am *ActorManager) saveSnapshot(actor *BehaviorActor, passivatedAt *time.Time) {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	am.saveSnapshotLocked(actor, passivatedAt)
+}
+
+// saveSnapshotLocked 保存 Actor 的快照，调用方需持有锁
+func (am *ActorManager) saveSnapshotLocked(actor *BehaviorActor, passivatedAt *time.Time) {
+	if err := am.store.saveSnapshot(actor, passivatedAt); err != nil {
+		log.Printf("Failed to save snapshot of actor %s: %v", actor.ID(), err)
+	}
+}
+
+// DescribeActor 返回 Actor 的状态信息，不会激活休眠的 Actor
+func (am *ActorManager) DescribeActor(actorID string) (map[string]interface{}, error) {
+	am.mu.RLock()
+	actor, active := am.actors[actorID]
+	behavior, registered := am.registry[actorID]
+	am.mu.RUnlock()
+
+	if active {
+		return actor.GetStatus(), nil
+	}
+	if !registered {
+		return nil, fmt.Errorf("%w: %s", ErrActorNotFound, actorID)
+	}
+	return am.dormantStatus(behavior, am.loadSnapshots()[actorID]), nil
+}
+
+// ListActorStatuses 返回所有已登记 Actor 的状态信息，包括休眠的 Actor，按ID排序
+func (am *ActorManager) ListActorStatuses() []map[string]interface{} {
+	am.mu.RLock()
+	statuses := make([]map[string]interface{}, 0, len(am.registry))
+	var dormant []*models.Behavior
+	for actorID, behavior := range am.registry {
+		if actor, active := am.actors[actorID]; active {
+			statuses = append(statuses, actor.GetStatus())
+		} else {
+			dormant = append(dormant, behavior)
+		}
+	}
+	am.mu.RUnlock()
+
+	if len(dormant) > 0 {
+		snapshots := am.loadSnapshots()
+		for _, behavior := range dormant {
+			statuses = append(statuses, am.dormantStatus(behavior, snapshots[behavior.ID]))
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i]["id"].(string) < statuses[j]["id"].(string)
+	})
+	return statuses
+}
+
+// loadSnapshots 按 Actor ID 加载所有快照，加载失败时返回空集合
+func (am *ActorManager) loadSnapshots() map[string]*models.ActorSnapshot {
+	am.mu.RLock()
+	service := am.store.snapshots
+	am.mu.RUnlock()
+
+	snapshots := make(map[string]*models.ActorSnapshot)
+	if service == nil {
+		return snapshots
+	}
+	list, err := service.ListSnapshots()
+	if err != nil {
+		log.Printf("Failed to load actor snapshots: %v", err)
+		return snapshots
+	}
+	for i := range list {
+		snapshots[list[i].ActorID] = &list[i]
+	}
+	return snapshots
+}
+
+// dormantStatus 构造休眠 Actor 的状态信息
+func (am *ActorManager) dormantStatus(behavior *models.Behavior, snapshot *models.ActorSnapshot) map[string]interface{} {
+	functions := make([]string, 0, len(behavior.Functions))
+	for name := range behavior.Functions {
+		functions = append(functions, name)
+	}
+	sort.Strings(functions)
+
+	status := map[string]interface{}{
+		"id":        behavior.ID,
+		"name":      behavior.Name,
+		"status":    string(ActorStateDormant),
+		"functions": functions,
+	}
+	if snapshot != nil {
+		status["last_active"] = snapshot.LastActive
+		status["activations"] = snapshot.Activations
+		status["messages_processed"] = snapshot.MessagesProcessed
+		status["passivated_at"] = snapshot.PassivatedAt
+	}
+	return status
+}
+
+// touch 记录一次活动
+func (ba *BehaviorActor) touch() {
+	ba.mu.Lock()
+	ba.LastActive = time.Now()
+	ba.mu.Unlock()
+}
+
+// lastActive 返回最近一次活动的时间
+func (ba *BehaviorActor) lastActive() time.Time {
+	ba.mu.RLock()
+	defer ba.mu.RUnlock()
+	return ba.LastActive
+}
+
+// snapshot 生成 Actor 当前运行状态的快照
+func (ba *BehaviorActor) snapshot() *models.ActorSnapshot {
+	ba.mu.RLock()
+	defer ba.mu.RUnlock()
+
+	return &models.ActorSnapshot{
+		ActorID:           ba.id,
+		BehaviorID:        ba.behavior.ID,
+		Sequence:          ba.sequence,
+		State:             copyLocals(ba.state),
+		Activations:       ba.activations,
+		MessagesProcessed: ba.messagesProcessed,
+		LastActive:        ba.LastActive,
+	}
+}
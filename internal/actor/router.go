@@ -0,0 +1,289 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"uros-restron/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// defaultRequestTimeout 请求未设置截止时间时等待响应的时长
+const defaultRequestTimeout = 30 * time.Second
+
+// ThingDirectory 按 Thing 和关系查找消息接收方
+type ThingDirectory interface {
+	ThingBehaviorID(thingID string) (string, error)
+	RelatedThingIDs(thingID, relationshipType, direction string) ([]string, error)
+}
+
+// Destination 消息接收方
+type Destination struct {
+	ActorID string `json:"actor"`
+	ThingID string `json:"thing,omitempty"`
+}
+
+// RemoteRouter 集群模式下决定消息由哪个节点处理，并把消息转发到其他节点
+type RemoteRouter interface {
+	// Route 返回处理消息的节点，由本节点处理时 local 为 true
+	Route(msg *Message) (node string, local bool)
+	// Forward 把消息投递到远程节点，wait 为 true 时等待并返回响应
+	Forward(ctx context.Context, node string, msg *Message, wait bool) (*Message, error)
+	// Invoke 在远程节点执行函数调用
+	Invoke(ctx context.Context, node string, request *InvocationRequest) (*InvocationReply, error)
+}
+
+// InvocationRequest 转发到其他节点的函数调用，放置规则与消息相同
+type InvocationRequest struct {
+	ActorID  string                 `json:"actorId"`
+	Function string                 `json:"function"`
+	Params   map[string]interface{} `json:"params"`
+	ThingID  string                 `json:"thingId,omitempty"`
+	Caller   string                 `json:"caller,omitempty"`
+	Async    bool                   `json:"async,omitempty"`
+}
+
+// InvocationReply 远程节点的调用结果，同步调用返回 Result，异步调用返回 Invocation
+type InvocationReply struct {
+	Result     *ExecutionResult   `json:"result,omitempty"`
+	Invocation *models.Invocation `json:"invocation,omitempty"`
+}
+
+// Router 在 Actor 之间投递消息，支持单向发送、请求-响应和主题发布订阅
+// 设置 RemoteRouter 后，由其他节点处理的消息被透明地转发
+type Router struct {
+	manager   *ActorManager
+	directory ThingDirectory
+	remote    RemoteRouter
+	pending   map[string]chan *Message       // 关联ID到等待响应的通道
+	topics    map[string]map[string]struct{} // 主题到订阅的 Actor
+	mu        sync.RWMutex
+}
+
+// newRouter 创建消息路由
+func newRouter(manager *ActorManager) *Router {
+	return &Router{
+		manager: manager,
+		pending: make(map[string]chan *Message),
+		topics:  make(map[string]map[string]struct{}),
+	}
+}
+
+// setDirectory 设置按 Thing 和关系解析接收方使用的目录
+func (r *Router) setDirectory(directory ThingDirectory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.directory = directory
+}
+
+// SetRemote 设置集群模式下的远程路由，传入 nil 时所有消息在本节点处理
+func (r *Router) SetRemote(remote RemoteRouter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remote = remote
+}
+
+// route 返回处理消息的远程节点，由本节点处理时返回空字符串
+func (r *Router) route(msg *Message) (RemoteRouter, string) {
+	r.mu.RLock()
+	remote := r.remote
+	r.mu.RUnlock()
+
+	if remote == nil {
+		return nil, ""
+	}
+	node, local := remote.Route(msg)
+	if local {
+		return nil, ""
+	}
+	return remote, node
+}
+
+// routeInvocation 返回执行函数调用的远程节点，放置键与发给该 Actor 的消息相同
+func (r *Router) routeInvocation(ctx context.Context, actorID string) (RemoteRouter, string) {
+	return r.route(&Message{To: actorID, ThingID: ThingIDFromContext(ctx)})
+}
+
+// Send 把消息投递到接收方的邮箱，不等待处理结果，休眠的接收方会被激活
+func (r *Router) Send(msg *Message) error {
+	if remote, node := r.route(msg); remote != nil {
+		_, err := remote.Forward(context.Background(), node, msg, false)
+		return err
+	}
+	return r.SendLocal(msg)
+}
+
+// SendLocal 把消息投递到本节点的接收方，用于处理其他节点转发来的消息
+func (r *Router) SendLocal(msg *Message) error {
+	return r.manager.SendMessage(msg.To, msg)
+}
+
+// Request 投递消息并等待关联ID相同的响应，ctx 未设置截止时间时最多等待 30 秒
+func (r *Router) Request(ctx context.Context, msg *Message) (*Message, error) {
+	if remote, node := r.route(msg); remote != nil {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, defaultRequestTimeout)
+			defer cancel()
+		}
+		return remote.Forward(ctx, node, msg, true)
+	}
+	return r.RequestLocal(ctx, msg)
+}
+
+// RequestLocal 向本节点的接收方投递消息并等待响应，用于处理其他节点转发来的请求
+func (r *Router) RequestLocal(ctx context.Context, msg *Message) (*Message, error) {
+	if msg.CorrelationID == "" {
+		msg.CorrelationID = uuid.New().String()
+	}
+
+	reply := make(chan *Message, 1)
+	r.mu.Lock()
+	r.pending[msg.CorrelationID] = reply
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, msg.CorrelationID)
+		r.mu.Unlock()
+	}()
+
+	if err := r.SendLocal(msg); err != nil {
+		return nil, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultRequestTimeout)
+		defer cancel()
+	}
+
+	select {
+	case response := <-reply:
+		return response, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no reply from actor %s: %w", msg.To, ctx.Err())
+	}
+}
+
+// Reply 把响应交给等待该关联ID的请求方，请求方已超时放弃时丢弃响应
+func (r *Router) Reply(msg *Message) {
+	r.mu.RLock()
+	reply, waiting := r.pending[msg.CorrelationID]
+	r.mu.RUnlock()
+
+	if !waiting {
+		log.Printf("Dropping %s from %s to %s: no pending request %s", msg.Type, msg.From, msg.To, msg.CorrelationID)
+		return
+	}
+	select {
+	case reply <- msg:
+	default:
+	}
+}
+
+// Subscribe 订阅主题
+func (r *Router) Subscribe(topic, actorID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscribers, ok := r.topics[topic]
+	if !ok {
+		subscribers = make(map[string]struct{})
+		r.topics[topic] = subscribers
+	}
+	subscribers[actorID] = struct{}{}
+}
+
+// UnsubscribeAll 取消 Actor 的全部订阅
+func (r *Router) UnsubscribeAll(actorID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for topic, subscribers := range r.topics {
+		delete(subscribers, actorID)
+		if len(subscribers) == 0 {
+			delete(r.topics, topic)
+		}
+	}
+}
+
+// Subscribers 返回订阅主题的 Actor，按ID排序
+func (r *Router) Subscribers(topic string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	actorIDs := make([]string, 0, len(r.topics[topic]))
+	for actorID := range r.topics[topic] {
+		actorIDs = append(actorIDs, actorID)
+	}
+	sort.Strings(actorIDs)
+	return actorIDs
+}
+
+// Publish 向主题的所有订阅者投递消息，返回成功投递的数量
+func (r *Router) Publish(from, topic string, payload map[string]interface{}) int {
+	delivered := 0
+	for _, actorID := range r.Subscribers(topic) {
+		msg := NewEventMessage(from, actorID, topic, copyLocals(payload))
+		if err := r.Send(msg); err != nil {
+			log.Printf("Failed to publish %s to actor %s: %v", topic, actorID, err)
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// Resolve 解析消息接收方，按关系解析时以 ctx 中的 Thing 为起点
+func (r *Router) Resolve(ctx context.Context, target models.MessageTarget) ([]Destination, error) {
+	if target.Actor != "" {
+		return []Destination{{ActorID: target.Actor}}, nil
+	}
+
+	r.mu.RLock()
+	directory := r.directory
+	r.mu.RUnlock()
+	if directory == nil {
+		return nil, fmt.Errorf("thing directory is not configured")
+	}
+
+	if target.Thing != "" {
+		behaviorID, err := directory.ThingBehaviorID(target.Thing)
+		if err != nil {
+			return nil, fmt.Errorf("thing %s not found: %v", target.Thing, err)
+		}
+		if behaviorID == "" {
+			return nil, fmt.Errorf("thing %s has no behavior", target.Thing)
+		}
+		return []Destination{{ActorID: behaviorID, ThingID: target.Thing}}, nil
+	}
+
+	if target.Relationship == "" {
+		return nil, fmt.Errorf("target requires actor, thing or relationship")
+	}
+	thingID := ThingIDFromContext(ctx)
+	if thingID == "" {
+		return nil, fmt.Errorf("relationship target requires a thing")
+	}
+
+	thingIDs, err := directory.RelatedThingIDs(thingID, target.Relationship, target.Direction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s relationships of thing %s: %v", target.Relationship, thingID, err)
+	}
+
+	destinations := make([]Destination, 0, len(thingIDs))
+	for _, relatedID := range thingIDs {
+		behaviorID, err := directory.ThingBehaviorID(relatedID)
+		if err != nil || behaviorID == "" {
+			log.Printf("Skipping related thing %s without behavior", relatedID)
+			continue
+		}
+		destinations = append(destinations, Destination{ActorID: behaviorID, ThingID: relatedID})
+	}
+	return destinations, nil
+}
@@ -0,0 +1,91 @@
+package actor
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// shutdownPollInterval 关闭时检查 Actor 是否空闲的间隔
+	shutdownPollInterval = 50 * time.Millisecond
+	// shutdownCancelGrace 截止时间到达后等待被取消的调用记录结果的时长
+	shutdownCancelGrace = 2 * time.Second
+)
+
+// Shutdown 优雅关闭 Actor 管理器
+// 先停止心跳、钝化、定时调度等后台任务，再等待 Actor 处理完邮箱中的消息和执行中的调用；
+// ctx 到期后取消剩余的调用，最后停止所有 Actor 并保存快照
+func (am *ActorManager) Shutdown(ctx context.Context) error {
+	am.stopBackground()
+
+	if !am.waitIdle(ctx) {
+		log.Printf("Shutdown deadline reached with %d running invocations, cancelling", am.runningCount())
+		am.cancelRunning()
+
+		// 等待被取消的调用保存结果
+		graceCtx, cancel := context.WithTimeout(context.Background(), shutdownCancelGrace)
+		am.waitIdle(graceCtx)
+		cancel()
+	}
+
+	err := am.StopAllActors()
+	am.cancel()
+	log.Printf("Actor manager stopped")
+	return err
+}
+
+// waitIdle 等待所有 Actor 邮箱为空、没有正在处理的消息和执行中的调用，ctx 结束时返回 false
+func (am *ActorManager) waitIdle(ctx context.Context) bool {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if am.idle() {
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return am.idle()
+		}
+	}
+}
+
+// idle 判断所有 Actor 是否空闲
+func (am *ActorManager) idle() bool {
+	if am.runningCount() > 0 {
+		return false
+	}
+
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	for _, actor := range am.actors {
+		if behaviorActor, ok := actor.(*BehaviorActor); ok && behaviorActor.busy() {
+			return false
+		}
+	}
+	return true
+}
+
+// runningCount 返回执行中的调用数量
+func (am *ActorManager) runningCount() int {
+	am.runningMu.Lock()
+	defer am.runningMu.Unlock()
+	return len(am.running)
+}
+
+// cancelRunning 取消所有执行中的调用，包括后台调用
+func (am *ActorManager) cancelRunning() {
+	am.runningMu.Lock()
+	defer am.runningMu.Unlock()
+	for _, run := range am.running {
+		run.cancel()
+	}
+}
+
+// busy 判断 Actor 是否有排队或正在处理的消息
+func (ba *BehaviorActor) busy() bool {
+	return ba.mailbox.Len() > 0 || atomic.LoadInt32(&ba.processing) == 1
+}
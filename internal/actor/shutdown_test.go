@@ -0,0 +1,208 @@
+package actor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uros-restron/internal/database"
+	"uros-restron/internal/models"
+)
+
+// newRecordedManager 创建在临时数据库中记录调用的 Actor 管理器，worker 行为的 slow 等待 200ms，hang 等待 10 秒
+// 不注册关闭，由测试调用 Shutdown
+func newRecordedManager(t *testing.T) (*ActorManager, *models.InvocationService) {
+	t.Helper()
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "invocations.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		database.Close(db)
+	})
+	if err := db.AutoMigrate(&models.Invocation{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	invocations := models.NewInvocationService(db)
+	am := NewActorManager(nil)
+	am.SetInvocationService(invocations)
+
+	wait := func(name string, ms int) models.Function {
+		return models.Function{
+			Name: name,
+			Implementation: models.FunctionImplementation{Steps: []models.ImplementationStep{
+				{Step: 1, Type: models.StepTypeWait, DurationMs: ms},
+			}},
+		}
+	}
+	if _, err := am.CreateActorFromBehaviorData(&models.Behavior{
+		ID:   "worker",
+		Name: "worker",
+		Functions: map[string]models.Function{
+			"slow": wait("slow", 200),
+			"hang": wait("hang", 10000),
+		},
+	}); err != nil {
+		t.Fatalf("failed to create actor: %v", err)
+	}
+	return am, invocations
+}
+
+// waitRunning 等待管理器中有 n 个执行中的调用
+func waitRunning(t *testing.T, am *ActorManager, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for am.runningCount() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d running invocations, got %d", n, am.runningCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// invocationStatuses 统计调用记录的状态
+func invocationStatuses(t *testing.T, invocations *models.InvocationService) map[models.InvocationStatus]int {
+	t.Helper()
+	records, _, err := invocations.ListInvocations(models.InvocationFilter{ActorID: "worker", Limit: 100})
+	if err != nil {
+		t.Fatalf("failed to list invocations: %v", err)
+	}
+	statuses := make(map[models.InvocationStatus]int)
+	for _, record := range records {
+		statuses[record.Status]++
+	}
+	return statuses
+}
+
+// shutdownWithin 在 deadline 内关闭管理器，返回关闭耗时
+func shutdownWithin(t *testing.T, am *ActorManager, deadline time.Duration) time.Duration {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+
+	started := time.Now()
+	if err := am.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	return time.Since(started)
+}
+
+func TestShutdownWaitsForRunningInvocations(t *testing.T) {
+	am, invocations := newRecordedManager(t)
+
+	syncResult := make(chan error, 1)
+	go func() {
+		_, err := am.InvokeFunction(context.Background(), "worker", "slow", nil)
+		syncResult <- err
+	}()
+	if _, err := am.InvokeFunctionAsync(context.Background(), "worker", "slow", nil); err != nil {
+		t.Fatalf("async invoke failed: %v", err)
+	}
+	waitRunning(t, am, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	started := time.Now()
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- am.Shutdown(ctx)
+	}()
+
+	// 后台任务立即停止，执行中的调用不受影响
+	select {
+	case <-am.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("background context was not cancelled when shutdown started")
+	}
+	if am.runningCount() == 0 {
+		t.Fatal("invocations finished before background context was cancelled")
+	}
+
+	select {
+	case err := <-shutdownDone:
+		if err != nil {
+			t.Fatalf("shutdown failed: %v", err)
+		}
+		if elapsed := time.Since(started); elapsed >= 5*time.Second {
+			t.Fatalf("shutdown waited for its deadline instead of the invocations: %v", elapsed)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("shutdown did not return")
+	}
+
+	if err := <-syncResult; err != nil {
+		t.Fatalf("sync invocation failed: %v", err)
+	}
+	if statuses := invocationStatuses(t, invocations); statuses[models.InvocationSucceeded] != 2 || len(statuses) != 1 {
+		t.Fatalf("expected 2 succeeded invocations, got %v", statuses)
+	}
+	if actors := am.ListActors(); len(actors) != 0 {
+		t.Fatalf("expected all actors to be stopped, got %d", len(actors))
+	}
+}
+
+func TestShutdownDrainsMailbox(t *testing.T) {
+	am, invocations := newRecordedManager(t)
+
+	// 邮箱中的调用逐条执行，第二条在关闭开始时仍在排队
+	for i := 0; i < 2; i++ {
+		if err := am.SendMessage("worker", NewFunctionCallMessage("test", "worker", "slow", nil)); err != nil {
+			t.Fatalf("failed to send message: %v", err)
+		}
+	}
+	waitRunning(t, am, 1)
+
+	shutdownWithin(t, am, 5*time.Second)
+
+	if statuses := invocationStatuses(t, invocations); statuses[models.InvocationSucceeded] != 2 || len(statuses) != 1 {
+		t.Fatalf("expected both queued calls to succeed, got %v", statuses)
+	}
+}
+
+func TestShutdownCancelsAfterDeadline(t *testing.T) {
+	am, invocations := newRecordedManager(t)
+
+	syncResult := make(chan error, 1)
+	go func() {
+		_, err := am.InvokeFunction(context.Background(), "worker", "hang", nil)
+		syncResult <- err
+	}()
+	if _, err := am.InvokeFunctionAsync(context.Background(), "worker", "hang", nil); err != nil {
+		t.Fatalf("async invoke failed: %v", err)
+	}
+	waitRunning(t, am, 2)
+
+	// 截止时间到达后取消，被取消的调用很快结束，不必等满 shutdownCancelGrace
+	elapsed := shutdownWithin(t, am, 100*time.Millisecond)
+	if elapsed < 100*time.Millisecond || elapsed >= shutdownCancelGrace {
+		t.Fatalf("expected shutdown to cancel at its deadline, took %v", elapsed)
+	}
+
+	select {
+	case err := <-syncResult:
+		if err == nil {
+			t.Fatal("expected cancelled sync invocation to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sync invocation did not return after shutdown")
+	}
+	if n := am.runningCount(); n != 0 {
+		t.Fatalf("expected no running invocations, got %d", n)
+	}
+	if statuses := invocationStatuses(t, invocations); statuses[models.InvocationCancelled] != 2 || len(statuses) != 1 {
+		t.Fatalf("expected 2 cancelled invocations, got %v", statuses)
+	}
+}
+
+func TestShutdownIdle(t *testing.T) {
+	am, _ := newRecordedManager(t)
+
+	if elapsed := shutdownWithin(t, am, 5*time.Second); elapsed >= shutdownPollInterval {
+		t.Fatalf("expected idle shutdown to return immediately, took %v", elapsed)
+	}
+	if _, err := am.GetActor("worker"); err == nil {
+		t.Fatal("expected actor registry to be cleared after shutdown")
+	}
+}
@@ -1,11 +1,18 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 	"uros-restron/internal/actor"
 	"uros-restron/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 // ActorHandler Actor系统处理器
@@ -22,15 +29,9 @@ func NewActorHandler(actorManager *actor.ActorManager, hub *Hub) *ActorHandler {
 	}
 }
 
-// ListActors 获取Actor列表
+// ListActors 获取Actor列表，包括休眠的Actor
 func (h *ActorHandler) ListActors(c *gin.Context) {
-	actors := h.actorManager.ListActors()
-
-	// 转换为响应格式
-	var actorList []map[string]interface{}
-	for _, actor := range actors {
-		actorList = append(actorList, actor.GetStatus())
-	}
+	actorList := h.actorManager.ListActorStatuses()
 
 	utils.RespondWithData(c, gin.H{
 		"data":  actorList,
@@ -38,17 +39,75 @@ func (h *ActorHandler) ListActors(c *gin.Context) {
 	})
 }
 
-// GetActor 获取单个Actor
+// GetActor 获取单个Actor，查询不会激活休眠的Actor
 func (h *ActorHandler) GetActor(c *gin.Context) {
 	id := c.Param("id")
 
-	actor, err := h.actorManager.GetActor(id)
+	status, err := h.actorManager.DescribeActor(id)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusNotFound, "Actor not found")
 		return
 	}
 
-	utils.RespondWithData(c, actor.GetStatus())
+	utils.RespondWithData(c, status)
+}
+
+// GetActorState 获取Actor由事件构建的状态，查询不会激活休眠的Actor
+func (h *ActorHandler) GetActorState(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.actorManager.DescribeActor(id); err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Actor not found")
+		return
+	}
+
+	state, err := h.actorManager.ActorState(id)
+	if err != nil {
+		logrus.Error("Failed to load actor state:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to load actor state")
+		return
+	}
+
+	utils.RespondWithData(c, state)
+}
+
+// ListActorEvents 查询Actor的事件日志，支持按 type 过滤和 since 指定起始序号
+func (h *ActorHandler) ListActorEvents(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.actorManager.DescribeActor(id); err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Actor not found")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid limit parameter")
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid offset parameter")
+		return
+	}
+
+	since, err := strconv.ParseInt(c.DefaultQuery("since", "0"), 10, 64)
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid since parameter")
+		return
+	}
+
+	events, total, err := h.actorManager.ListActorEvents(id, c.Query("type"), since, limit, offset)
+	if err != nil {
+		logrus.Error("Failed to list actor events:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list actor events")
+		return
+	}
+
+	utils.RespondWithData(c, gin.H{
+		"data":  events,
+		"count": len(events),
+		"total": total,
+	})
 }
 
 // CallActorFunction 调用Actor函数
@@ -58,32 +117,125 @@ func (h *ActorHandler) CallActorFunction(c *gin.Context) {
 
 	// 直接解析参数，如果body为空则使用空map
 	var params map[string]interface{}
-	if err := c.ShouldBindJSON(&params); err != nil {
+	if err := c.ShouldBindJSON(&params); err != nil || params == nil {
 		// 如果解析失败，使用空map
 		params = make(map[string]interface{})
 	}
 
+	// 查询参数作为补充，按函数定义转换类型，body 中的同名参数优先
+	// thingId 指定函数执行针对的 Thing，async 指定异步执行，均不作为函数参数
+	for key, values := range c.Request.URL.Query() {
+		if key == "thingId" || key == "async" {
+			continue
+		}
+		if _, exists := params[key]; exists {
+			continue
+		}
+		if len(values) == 1 {
+			params[key] = values[0]
+			continue
+		}
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			items[i] = value
+		}
+		params[key] = items
+	}
+
 	// 调用函数
-	result, err := h.actorManager.CallFunction(actorID, functionName, params)
+	ctx := actor.WithThingID(c.Request.Context(), c.Query("thingId"))
+	ctx = actor.WithCaller(ctx, requestCaller(c))
+
+	// 异步调用立即返回调用ID，通过 /invocations/:id 查询进度和结果
+	if isAsyncRequest(c) {
+		invocation, err := h.actorManager.InvokeFunctionAsync(ctx, actorID, functionName, params)
+		if err != nil {
+			utils.RespondWithError(c, invocationErrorStatus(err), err.Error())
+			return
+		}
+
+		statusURL := "/api/v1/invocations/" + invocation.ID
+		c.Header("Location", statusURL)
+		utils.RespondWithDataStatus(c, gin.H{
+			"invocationId": invocation.ID,
+			"actorId":      actorID,
+			"function":     functionName,
+			"thingId":      invocation.ThingID,
+			"status":       invocation.Status,
+			"statusUrl":    statusURL,
+			"startedAt":    invocation.StartedAt,
+		}, http.StatusAccepted)
+		return
+	}
+
+	result, err := h.actorManager.InvokeFunction(ctx, actorID, functionName, params)
 	if err != nil {
-		utils.RespondWithError(c, http.StatusInternalServerError, err.Error())
+		utils.RespondWithError(c, invocationErrorStatus(err), err.Error())
 		return
 	}
 
 	utils.RespondWithData(c, gin.H{
-		"actorId":  actorID,
-		"function": functionName,
-		"result":   result,
+		"invocationId": result.InvocationID,
+		"actorId":      actorID,
+		"function":     functionName,
+		"thingId":      result.ThingID,
+		"params":       result.Params,
+		"variables":    result.Variables,
+		"result":       result.Output,
+		"trace":        result.Trace,
+		"startedAt":    result.StartedAt,
+		"durationMs":   result.DurationMs,
 	})
 }
 
+// invocationErrorStatus 返回函数调用错误对应的状态码：
+// Actor 或函数不存在为 404，参数无效为 400，同时执行的调用达到上限为 429，其他为 500
+func invocationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, actor.ErrActorNotFound), errors.Is(err, actor.ErrFunctionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, actor.ErrInvalidParams):
+		return http.StatusBadRequest
+	case errors.Is(err, actor.ErrInvocationLimit):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// isAsyncRequest 判断是否请求异步执行：?async=true 或 Prefer: respond-async
+func isAsyncRequest(c *gin.Context) bool {
+	if async, err := strconv.ParseBool(c.Query("async")); err == nil {
+		return async
+	}
+	for _, prefer := range strings.Split(c.GetHeader("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(prefer), "respond-async") {
+			return true
+		}
+	}
+	return false
+}
+
+// requestCaller 返回请求的调用方，优先使用 X-Caller 请求头
+func requestCaller(c *gin.Context) string {
+	if caller := c.GetHeader("X-Caller"); caller != "" {
+		return caller
+	}
+	return "http:" + c.ClientIP()
+}
+
 // SendMessageToActor 向Actor发送消息
+// wait 为 true 时等待 Actor 的响应，否则投递到邮箱后立即返回 202
 func (h *ActorHandler) SendMessageToActor(c *gin.Context) {
 	actorID := c.Param("id")
 
 	var request struct {
-		Message string                 `json:"message"`
-		Data    map[string]interface{} `json:"data"`
+		Message   string                 `json:"message"` // 消息类型，默认为 function_call
+		Function  string                 `json:"function"`
+		Topic     string                 `json:"topic"`
+		ThingID   string                 `json:"thingId"`
+		Data      map[string]interface{} `json:"data"`
+		Wait      bool                   `json:"wait"`
+		TimeoutMs int                    `json:"timeoutMs"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -91,26 +243,95 @@ func (h *ActorHandler) SendMessageToActor(c *gin.Context) {
 		return
 	}
 
-	// 获取Actor
-	_, err := h.actorManager.GetActor(actorID)
-	if err != nil {
+	// 检查Actor是否已登记，不在此激活：集群模式下消息可能由其他节点处理
+	if _, err := h.actorManager.DescribeActor(actorID); err != nil {
 		utils.RespondWithError(c, http.StatusNotFound, "Actor not found")
 		return
 	}
 
-	// 发送消息 - 这里需要根据实际的ActorManager接口来实现
-	// response, err := h.actorManager.SendMessageToActor(actorID, request.Message, request.Data)
-	// 暂时返回一个占位符响应
-	response := map[string]interface{}{
-		"actorId": actorID,
-		"message": request.Message,
-		"data":    request.Data,
-		"status":  "Message sent successfully",
+	caller := requestCaller(c)
+	var msg *actor.Message
+	switch actor.MessageType(request.Message) {
+	case "", actor.FunctionCall:
+		if request.Function == "" {
+			utils.ValidationErrorResponse(c, "function is required for function_call messages")
+			return
+		}
+		msg = actor.NewFunctionCallMessage(caller, actorID, request.Function, request.Data)
+	case actor.Event:
+		if request.Topic == "" {
+			utils.ValidationErrorResponse(c, "topic is required for event messages")
+			return
+		}
+		msg = actor.NewEventMessage(caller, actorID, request.Topic, request.Data)
+	case actor.StatusQuery:
+		msg = actor.NewMessage(actor.StatusQuery, caller, actorID)
+	default:
+		utils.ValidationErrorResponse(c, fmt.Sprintf("unsupported message type %q", request.Message))
+		return
 	}
+	msg.ThingID = request.ThingID
 
+	router := h.actorManager.Router()
+	if !request.Wait {
+		if err := router.Send(msg); err != nil {
+			utils.RespondWithError(c, sendErrorStatus(err), err.Error())
+			return
+		}
+		utils.RespondWithDataStatus(c, gin.H{"messageId": msg.ID, "status": "sent"}, http.StatusAccepted)
+		return
+	}
+	if msg.Type == actor.Event {
+		utils.ValidationErrorResponse(c, "event messages have no reply, wait is not supported")
+		return
+	}
+
+	ctx := c.Request.Context()
+	if request.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(request.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
+	response, err := router.Request(ctx, msg)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			utils.RespondWithError(c, http.StatusGatewayTimeout, err.Error())
+			return
+		}
+		utils.RespondWithError(c, sendErrorStatus(err), err.Error())
+		return
+	}
 	utils.RespondWithData(c, response)
 }
 
+// sendErrorStatus 返回投递失败对应的状态码，邮箱已满时为 429
+func sendErrorStatus(err error) int {
+	if errors.Is(err, actor.ErrMailboxFull) || errors.Is(err, actor.ErrMailboxTimeout) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusServiceUnavailable
+}
+
+// PublishToTopic 向主题的所有订阅者发布消息
+func (h *ActorHandler) PublishToTopic(c *gin.Context) {
+	topic := c.Param("topic")
+
+	var payload map[string]interface{}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	router := h.actorManager.Router()
+	delivered := router.Publish(requestCaller(c), topic, payload)
+	utils.RespondWithDataStatus(c, gin.H{
+		"topic":       topic,
+		"subscribers": router.Subscribers(topic),
+		"delivered":   delivered,
+	}, http.StatusAccepted)
+}
+
 // GetActorFunctions 获取Actor函数列表
 func (h *ActorHandler) GetActorFunctions(c *gin.Context) {
 	actorID := c.Param("id")
@@ -133,16 +354,7 @@ func (h *ActorHandler) GetActorFunctions(c *gin.Context) {
 	utils.RespondWithData(c, functions)
 }
 
-// HealthCheck Actor系统健康检查
+// HealthCheck Actor系统健康检查，包含每个已激活 Actor 的心跳和消息处理情况
 func (h *ActorHandler) HealthCheck(c *gin.Context) {
-	// 获取系统状态 - 这里需要根据实际的ActorManager接口来实现
-	// status := h.actorManager.GetSystemStatus()
-	// 暂时返回一个占位符响应
-	status := map[string]interface{}{
-		"status":    "healthy",
-		"actors":    len(h.actorManager.ListActors()),
-		"timestamp": "2024-01-01T00:00:00Z",
-	}
-
-	utils.RespondWithData(c, status)
+	utils.RespondWithData(c, h.actorManager.HealthCheck())
 }
@@ -9,10 +9,15 @@ func SetupActorRoutes(router *gin.RouterGroup, handler *ActorHandler) {
 	// Actor 管理
 	router.GET("/actors", handler.ListActors)
 	router.GET("/actors/:id", handler.GetActor)
+	router.GET("/actors/:id/state", handler.GetActorState)
+	router.GET("/actors/:id/events", handler.ListActorEvents)
 
 	// Actor 函数调用
 	router.POST("/actors/:id/functions/:function", handler.CallActorFunction)
 	router.POST("/actors/:id/messages", handler.SendMessageToActor)
 	router.GET("/actors/:id/functions", handler.GetActorFunctions)
 	router.GET("/actors/health", handler.HealthCheck)
+
+	// 主题发布
+	router.POST("/topics/:topic", handler.PublishToTopic)
 }
@@ -1,8 +1,11 @@
 package api
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"uros-restron/internal/actor"
 	"uros-restron/internal/models"
 	"uros-restron/internal/utils"
 
@@ -15,19 +18,29 @@ type BehaviorHandler struct {
 	behaviorService     *models.BehaviorService
 	thingTypeService    *models.ThingTypeService
 	thingService        *models.ThingService
+	actorManager        *actor.ActorManager
 	hub                 *Hub
 }
 
 // NewBehaviorHandler 创建新的行为处理器
-func NewBehaviorHandler(behaviorService *models.BehaviorService, thingTypeService *models.ThingTypeService, thingService *models.ThingService, hub *Hub) *BehaviorHandler {
+func NewBehaviorHandler(behaviorService *models.BehaviorService, thingTypeService *models.ThingTypeService, thingService *models.ThingService, actorManager *actor.ActorManager, hub *Hub) *BehaviorHandler {
 	return &BehaviorHandler{
 		behaviorService:  behaviorService,
 		thingTypeService: thingTypeService,
 		thingService:     thingService,
+		actorManager:     actorManager,
 		hub:              hub,
 	}
 }
 
+// reloadActor 按数据库中的最新定义重建行为对应的 Actor，新建的行为会被登记，已删除的行为停止其 Actor
+// 行为已经保存，重建失败只记录日志
+func (h *BehaviorHandler) reloadActor(id string) {
+	if err := h.actorManager.ReloadActor(id); err != nil {
+		logrus.Warnf("Failed to reload actor %s: %v", id, err)
+	}
+}
+
 // ListBehaviors 获取行为列表
 func (h *BehaviorHandler) ListBehaviors(c *gin.Context) {
 	// 获取查询参数
@@ -60,21 +73,27 @@ func (h *BehaviorHandler) ListBehaviors(c *gin.Context) {
 	})
 }
 
-// CreateBehavior 创建行为
+// CreateBehavior 创建行为，请求体可以是 JSON 或 YAML
 func (h *BehaviorHandler) CreateBehavior(c *gin.Context) {
 	var behavior models.Behavior
-	if err := c.ShouldBindJSON(&behavior); err != nil {
+	if err := bindBehavior(c, &behavior); err != nil {
 		utils.ValidationErrorResponse(c, err.Error())
 		return
 	}
 
+	if result := h.behaviorService.ValidateBehavior(&behavior); !result.Valid {
+		utils.ValidationFailedResponse(c, "Behavior validation failed", result)
+		return
+	}
+
 	if err := h.behaviorService.CreateBehavior(&behavior); err != nil {
 		logrus.Error("Failed to create behavior:", err)
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create behavior")
 		return
 	}
+	h.reloadActor(behavior.ID)
 
-	utils.RespondWithDataStatus(c, behavior, http.StatusCreated)
+	respondWithBehavior(c, &behavior, http.StatusCreated)
 }
 
 // GetBehavior 获取单个行为
@@ -87,7 +106,7 @@ func (h *BehaviorHandler) GetBehavior(c *gin.Context) {
 		return
 	}
 
-	utils.RespondWithData(c, behavior)
+	respondWithBehavior(c, behavior, http.StatusOK)
 }
 
 // UpdateBehavior 更新行为
@@ -100,14 +119,34 @@ func (h *BehaviorHandler) UpdateBehavior(c *gin.Context) {
 		return
 	}
 
-	if err := h.behaviorService.UpdateBehavior(id, updates); err != nil {
+	existing, err := h.behaviorService.GetBehavior(id)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Behavior not found")
+		return
+	}
+
+	// 将更新合并到现有定义上，校验通过后整体替换
+	behavior, err := mergeBehaviorUpdates(existing, updates)
+	if err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	behavior.ID = id
+
+	if result := h.behaviorService.ValidateBehavior(behavior); !result.Valid {
+		utils.ValidationFailedResponse(c, "Behavior validation failed", result)
+		return
+	}
+
+	if err := h.behaviorService.ReplaceBehavior(behavior); err != nil {
 		logrus.Error("Failed to update behavior:", err)
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update behavior")
 		return
 	}
+	h.reloadActor(id)
 
 	// 获取更新后的数据
-	behavior, err := h.behaviorService.GetBehavior(id)
+	behavior, err = h.behaviorService.GetBehavior(id)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to get updated behavior")
 		return
@@ -116,6 +155,72 @@ func (h *BehaviorHandler) UpdateBehavior(c *gin.Context) {
 	utils.RespondWithData(c, behavior)
 }
 
+// ValidateBehavior 校验行为定义但不保存
+func (h *BehaviorHandler) ValidateBehavior(c *gin.Context) {
+	var behavior models.Behavior
+	if err := bindBehavior(c, &behavior); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, h.behaviorService.ValidateBehavior(&behavior))
+}
+
+// bindBehavior 按 Content-Type 解析 JSON 或 YAML 格式的行为定义
+func bindBehavior(c *gin.Context, behavior *models.Behavior) error {
+	if !utils.IsYAMLRequest(c) {
+		return c.ShouldBindJSON(behavior)
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	return models.UnmarshalBehaviorYAML(data, behavior)
+}
+
+// respondWithBehavior 按 Accept 头输出 YAML 文档或 JSON 响应
+func respondWithBehavior(c *gin.Context, behavior *models.Behavior, statusCode int) {
+	if !utils.WantsYAML(c) {
+		utils.RespondWithDataStatus(c, behavior, statusCode)
+		return
+	}
+
+	data, err := models.MarshalBehaviorYAML(behavior)
+	if err != nil {
+		logrus.Error("Failed to encode behavior as YAML:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to encode behavior")
+		return
+	}
+	utils.RespondWithYAML(c, statusCode, data)
+}
+
+// mergeBehaviorUpdates 把部分更新合并到行为定义，顶层字段整体替换
+func mergeBehaviorUpdates(behavior *models.Behavior, updates map[string]interface{}) (*models.Behavior, error) {
+	data, err := json.Marshal(behavior)
+	if err != nil {
+		return nil, err
+	}
+	var merged map[string]interface{}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range updates {
+		merged[key] = value
+	}
+
+	data, err = json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	var result models.Behavior
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	result.Source = behavior.Source
+	return &result, nil
+}
+
 // DeleteBehavior 删除行为
 func (h *BehaviorHandler) DeleteBehavior(c *gin.Context) {
 	id := c.Param("id")
@@ -125,6 +230,7 @@ func (h *BehaviorHandler) DeleteBehavior(c *gin.Context) {
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to delete behavior")
 		return
 	}
+	h.reloadActor(id)
 
 	utils.RespondWithData(c, gin.H{"message": "Behavior deleted successfully"})
 }
@@ -148,16 +254,31 @@ func (h *BehaviorHandler) GetPredefinedBehaviors(c *gin.Context) {
 	utils.RespondWithData(c, behaviors)
 }
 
-// SeedBehaviors 种子数据
+// SeedBehaviors 从行为目录重新加载预定义行为
 func (h *BehaviorHandler) SeedBehaviors(c *gin.Context) {
-	// 这里需要根据实际的BehaviorService接口来实现
-	// if err := h.behaviorService.SeedBehaviors(); err != nil {
-	// 	logrus.Error("Failed to seed behaviors:", err)
-	// 	utils.RespondWithError(c, http.StatusInternalServerError, "Failed to seed behaviors")
-	// 	return
-	// }
-
-	utils.RespondWithData(c, gin.H{"message": "Behaviors seeded successfully"})
+	changed, err := h.behaviorService.SeedPredefinedBehaviors()
+	if err != nil {
+		logrus.Error("Failed to seed behaviors:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to seed behaviors")
+		return
+	}
+	for _, id := range changed {
+		h.reloadActor(id)
+	}
+
+	utils.RespondWithData(c, gin.H{
+		"message":    "Behaviors seeded successfully",
+		"loadErrors": h.behaviorService.GetLoadErrors(),
+	})
+}
+
+// GetLoadErrors 获取行为文件加载错误
+func (h *BehaviorHandler) GetLoadErrors(c *gin.Context) {
+	loadErrors := h.behaviorService.GetLoadErrors()
+	utils.RespondWithData(c, gin.H{
+		"data":  loadErrors,
+		"count": len(loadErrors),
+	})
 }
 
 // GetThingTypeBehaviors 获取事物类型的行为
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"uros-restron/internal/actor"
+	"uros-restron/internal/database"
+	"uros-restron/internal/events"
+	"uros-restron/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBehaviorChangesReloadActor(t *testing.T) {
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "behaviors.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close(db)
+	if err := db.AutoMigrate(&models.Behavior{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	var mu sync.Mutex
+	var published []events.Type
+	bus := events.NewBus()
+	bus.Subscribe(func(event events.Event) {
+		if event.Aggregate == events.AggregateBehavior {
+			mu.Lock()
+			published = append(published, event.Type)
+			mu.Unlock()
+		}
+	})
+
+	behaviorService := models.NewBehaviorService(db)
+	behaviorService.SetEventBus(bus)
+	am := actor.NewActorManager(behaviorService)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		am.Shutdown(ctx)
+	}()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	SetupBehaviorRoutes(router.Group("/api/v1"), NewBehaviorHandler(behaviorService, nil, nil, am, nil))
+	request := func(method, path, body string) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(recorder, req)
+		if recorder.Code >= 300 {
+			t.Fatalf("%s %s returned %d: %s", method, path, recorder.Code, recorder.Body.String())
+		}
+	}
+	functions := func(id string) []string {
+		t.Helper()
+		target, err := am.GetActor(id)
+		if err != nil {
+			t.Fatalf("failed to get actor %s: %v", id, err)
+		}
+		var names []string
+		for _, name := range []string{"ping", "pong"} {
+			if _, err := target.(*actor.BehaviorActor).GetFunctionInfo(name); err == nil {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	// 新建的行为立即可以调用
+	request(http.MethodPost, "/api/v1/behaviors", `{"id": "echo", "name": "echo", "type": "test", "functions": {"ping": {"name": "ping"}}}`)
+	if got := functions("echo"); !reflect.DeepEqual(got, []string{"ping"}) {
+		t.Fatalf("expected created actor to have ping, got %v", got)
+	}
+
+	// 激活中的 Actor 按更新后的定义重建
+	request(http.MethodPut, "/api/v1/behaviors/echo", `{"functions": {"pong": {"name": "pong"}}}`)
+	if got := functions("echo"); !reflect.DeepEqual(got, []string{"pong"}) {
+		t.Fatalf("expected updated actor to have only pong, got %v", got)
+	}
+	if _, err := am.InvokeFunction(context.Background(), "echo", "pong", nil); err != nil {
+		t.Fatalf("failed to invoke updated function: %v", err)
+	}
+
+	request(http.MethodDelete, "/api/v1/behaviors/echo", "")
+	if _, err := am.GetActor("echo"); !errors.Is(err, actor.ErrActorNotFound) {
+		t.Fatalf("expected deleted behavior's actor to be stopped, got %v", err)
+	}
+
+	// 每次变更只发布一个事件
+	mu.Lock()
+	defer mu.Unlock()
+	want := []events.Type{events.BehaviorCreated, events.BehaviorUpdated, events.BehaviorDeleted}
+	if !reflect.DeepEqual(published, want) {
+		t.Fatalf("expected events %v, got %v", want, published)
+	}
+}
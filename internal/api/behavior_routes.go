@@ -9,6 +9,7 @@ func SetupBehaviorRoutes(router *gin.RouterGroup, handler *BehaviorHandler) {
 	// 行为 CRUD 操作
 	router.GET("/behaviors", handler.ListBehaviors)
 	router.POST("/behaviors", handler.CreateBehavior)
+	router.POST("/behaviors/validate", handler.ValidateBehavior)
 	router.GET("/behaviors/:id", handler.GetBehavior)
 	router.PUT("/behaviors/:id", handler.UpdateBehavior)
 	router.DELETE("/behaviors/:id", handler.DeleteBehavior)
@@ -17,6 +18,7 @@ func SetupBehaviorRoutes(router *gin.RouterGroup, handler *BehaviorHandler) {
 	router.GET("/behaviors/category/:category", handler.GetBehaviorsByCategory)
 	router.GET("/behaviors/predefined", handler.GetPredefinedBehaviors)
 	router.POST("/behaviors/seed", handler.SeedBehaviors)
+	router.GET("/behaviors/load-errors", handler.GetLoadErrors)
 
 	// 事物类型行为路由
 	router.GET("/thing-types/:id/behaviors", handler.GetThingTypeBehaviors)
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"uros-restron/internal/actor"
+	"uros-restron/internal/cluster"
+	"uros-restron/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ClusterHandler 集群状态查询和节点间消息转发处理器
+type ClusterHandler struct {
+	cluster      *cluster.Cluster // 未开启集群模式时为 nil
+	actorManager *actor.ActorManager
+}
+
+// NewClusterHandler 创建集群处理器
+func NewClusterHandler(clusterNode *cluster.Cluster, actorManager *actor.ActorManager) *ClusterHandler {
+	return &ClusterHandler{
+		cluster:      clusterNode,
+		actorManager: actorManager,
+	}
+}
+
+// requireCluster 未开启集群模式时返回 404
+func (h *ClusterHandler) requireCluster(c *gin.Context) bool {
+	if h.cluster == nil {
+		utils.RespondWithError(c, http.StatusNotFound, "cluster mode is disabled")
+		return false
+	}
+	return true
+}
+
+// GetClusterStatus 获取本节点和所有节点的状态
+func (h *ClusterHandler) GetClusterStatus(c *gin.Context) {
+	if h.cluster == nil {
+		utils.RespondWithData(c, gin.H{"enabled": false})
+		return
+	}
+
+	status := h.cluster.Status()
+	status["enabled"] = true
+	utils.RespondWithData(c, status)
+}
+
+// Ping 节点探测，返回本节点ID和数据库标识
+func (h *ClusterHandler) Ping(c *gin.Context) {
+	if !h.requireCluster(c) {
+		return
+	}
+	utils.RespondWithData(c, gin.H{"nodeId": h.cluster.NodeID(), "storeId": h.cluster.StoreID()})
+}
+
+// GetPlacement 查询 Thing 或 Actor 的消息由哪个节点处理，thingId 优先
+func (h *ClusterHandler) GetPlacement(c *gin.Context) {
+	if !h.requireCluster(c) {
+		return
+	}
+
+	msg := &actor.Message{To: c.Query("actorId"), ThingID: c.Query("thingId")}
+	key := cluster.PlacementKey(msg)
+	if key == "" {
+		utils.ValidationErrorResponse(c, "thingId or actorId is required")
+		return
+	}
+	owner := h.cluster.Owner(key)
+	utils.RespondWithData(c, gin.H{
+		"key":   key,
+		"node":  owner,
+		"local": owner == h.cluster.NodeID(),
+	})
+}
+
+// ReceiveMessage 接收其他节点转发的消息并在本节点投递，wait=true 时返回响应
+func (h *ClusterHandler) ReceiveMessage(c *gin.Context) {
+	if !h.requireCluster(c) {
+		return
+	}
+
+	var msg actor.Message
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	if msg.To == "" {
+		utils.ValidationErrorResponse(c, "message recipient is required")
+		return
+	}
+	wait, _ := strconv.ParseBool(c.Query("wait"))
+	logrus.Debugf("Received %s for %s from node %s", msg.Type, msg.To, c.GetHeader(cluster.NodeHeader))
+
+	router := h.actorManager.Router()
+	if !wait {
+		if err := router.SendLocal(&msg); err != nil {
+			utils.RespondWithError(c, sendErrorStatus(err), err.Error())
+			return
+		}
+		utils.RespondWithDataStatus(c, gin.H{"messageId": msg.ID, "status": "sent"}, http.StatusAccepted)
+		return
+	}
+
+	// 转发方断开或超时时请求上下文被取消
+	response, err := router.RequestLocal(c.Request.Context(), &msg)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			utils.RespondWithError(c, http.StatusGatewayTimeout, err.Error())
+			return
+		}
+		utils.RespondWithError(c, sendErrorStatus(err), err.Error())
+		return
+	}
+	utils.RespondWithData(c, response)
+}
+
+// ReceiveInvocation 执行其他节点转发的函数调用，同步调用返回执行结果，异步调用返回运行中的调用记录
+func (h *ClusterHandler) ReceiveInvocation(c *gin.Context) {
+	if !h.requireCluster(c) {
+		return
+	}
+
+	var request actor.InvocationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	if request.ActorID == "" || request.Function == "" {
+		utils.ValidationErrorResponse(c, "actorId and function are required")
+		return
+	}
+	if request.Params == nil {
+		request.Params = make(map[string]interface{})
+	}
+	logrus.Debugf("Received invocation of %s.%s from node %s", request.ActorID, request.Function, c.GetHeader(cluster.NodeHeader))
+
+	// 转发方断开或超时时请求上下文被取消，异步调用不受影响
+	ctx := actor.WithThingID(c.Request.Context(), request.ThingID)
+	ctx = actor.WithCaller(ctx, request.Caller)
+	if request.Async {
+		invocation, err := h.actorManager.InvokeFunctionAsyncLocal(ctx, request.ActorID, request.Function, request.Params)
+		if err != nil {
+			utils.RespondWithError(c, invocationErrorStatus(err), err.Error())
+			return
+		}
+		utils.RespondWithData(c, actor.InvocationReply{Invocation: invocation})
+		return
+	}
+
+	result, err := h.actorManager.InvokeFunctionLocal(ctx, request.ActorID, request.Function, request.Params)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			utils.RespondWithError(c, http.StatusGatewayTimeout, err.Error())
+			return
+		}
+		utils.RespondWithError(c, invocationErrorStatus(err), err.Error())
+		return
+	}
+	utils.RespondWithData(c, actor.InvocationReply{Result: result})
+}
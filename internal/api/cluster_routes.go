@@ -0,0 +1,14 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// SetupClusterRoutes 设置集群相关的路由
+func SetupClusterRoutes(router *gin.RouterGroup, handler *ClusterHandler) {
+	router.GET("/cluster", handler.GetClusterStatus)
+	router.GET("/cluster/ping", handler.Ping)
+	router.GET("/cluster/placement", handler.GetPlacement)
+	router.POST("/cluster/messages", handler.ReceiveMessage)
+	router.POST("/cluster/invocations", handler.ReceiveInvocation)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultEventLogSize 事件日志默认保存的事件数
+const defaultEventLogSize = 1000
+
+// eventLog 保存最近广播的事件，每个事件分配单调递增的序号，供断线重连的客户端补发
+// 超过容量时丢弃最早的事件
+type eventLog struct {
+	mu       sync.RWMutex
+	entries  []BroadcastMessage // 环形缓冲区
+	start    int                // 最早事件在缓冲区中的位置
+	size     int
+	sequence uint64 // 最后分配的序号
+}
+
+// newEventLog 创建事件日志，capacity 不大于 0 时使用默认值
+func newEventLog(capacity int) *eventLog {
+	if capacity <= 0 {
+		capacity = defaultEventLogSize
+	}
+	return &eventLog{entries: make([]BroadcastMessage, capacity)}
+}
+
+// append 为事件分配序号并保存，返回带序号的事件
+func (l *eventLog) append(message BroadcastMessage) BroadcastMessage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sequence++
+	message.Seq = l.sequence
+	capacity := len(l.entries)
+	if l.size < capacity {
+		l.entries[(l.start+l.size)%capacity] = message
+		l.size++
+	} else {
+		l.entries[l.start] = message
+		l.start = (l.start + 1) % capacity
+	}
+	return message
+}
+
+// between 返回序号在 (since, upto] 内的事件
+// 其中一部分已被丢弃时，gapFrom 和 gapTo 为丢失事件的序号范围，否则均为 0
+func (l *eventLog) between(since, upto uint64) (entries []BroadcastMessage, gapFrom, gapTo uint64) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if upto > l.sequence {
+		upto = l.sequence
+	}
+	if since >= upto {
+		return nil, 0, 0
+	}
+
+	oldest := l.sequence + 1
+	if l.size > 0 {
+		oldest = l.entries[l.start].Seq
+	}
+	if since+1 < oldest {
+		gapFrom, gapTo = since+1, oldest-1
+		if gapTo > upto {
+			gapTo = upto
+		}
+	}
+
+	capacity := len(l.entries)
+	for i := 0; i < l.size; i++ {
+		entry := l.entries[(l.start+i)%capacity]
+		if entry.Seq > upto {
+			break
+		}
+		if entry.Seq > since {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, gapFrom, gapTo
+}
+
+// last 返回最后分配的序号
+func (l *eventLog) last() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.sequence
+}
+
+// capacity 返回日志最多保存的事件数
+func (l *eventLog) capacity() int {
+	return len(l.entries)
+}
+
+// resumeRequest 订阅时请求补发事件
+type resumeRequest struct {
+	client       *Client
+	subscription *Subscription
+	since        uint64
+}
+
+// gapNotice 请求补发的事件已从事件日志中丢弃，或客户端发送缓冲区放不下时发送
+type gapNotice struct {
+	Type           string `json:"type"`
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	From           uint64 `json:"from"`
+	To             uint64 `json:"to"`
+}
+
+// replay 向客户端补发 (since, client.seq] 内匹配的事件，只在 Run 中调用
+// subscription 为 nil 时按连接的过滤条件匹配，否则只匹配该订阅并在补发后激活它
+// 补发的事件超过发送队列的空位时丢弃较早的事件，并在前面发送 gap 通知
+func (h *Hub) replay(client *Client, since uint64, subscription *Subscription) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.closed {
+		return
+	}
+	if subscription != nil {
+		defer func() { subscription.pending = false }()
+	}
+
+	entries, gapFrom, gapTo := h.events.between(since, client.seq)
+	var matched [][]byte
+	var seqs []uint64
+	for _, entry := range entries {
+		scope := newEventScope(h, entry)
+		var subscriptionIDs []string
+		if subscription != nil {
+			if !subscription.matches(scope) {
+				continue
+			}
+			subscriptionIDs = []string{subscription.ID}
+		} else if ids, ok := client.matchLocked(scope); ok {
+			subscriptionIDs = ids
+		} else {
+			continue
+		}
+		matched = append(matched, scope.encode(subscriptionIDs))
+		seqs = append(seqs, entry.Seq)
+	}
+
+	// 留出四分之一的队列给之后的实时事件，避免补发后立即溢出
+	room := cap(client.send) - len(client.send) - cap(client.send)/4
+	if gapFrom > 0 || len(matched) > room {
+		room--
+	}
+	if room < 0 {
+		room = 0
+	}
+	if overflow := len(matched) - room; overflow > 0 {
+		if gapFrom == 0 {
+			gapFrom = since + 1
+		}
+		gapTo = seqs[overflow-1]
+		matched = matched[overflow:]
+	}
+
+	if gapFrom > 0 {
+		notice := gapNotice{Type: "gap", From: gapFrom, To: gapTo}
+		if subscription != nil {
+			notice.SubscriptionID = subscription.ID
+		}
+		if data, err := json.Marshal(notice); err == nil && !client.enqueueLocked(data) {
+			return
+		}
+		logrus.Infof("WebSocket client missed events %d-%d", gapFrom, gapTo)
+	}
+	for _, data := range matched {
+		if !client.enqueueLocked(data) {
+			return
+		}
+	}
+}
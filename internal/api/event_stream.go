@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"uros-restron/internal/utils"
+)
+
+// 事件流参数
+const (
+	streamKeepaliveInterval = 15 * time.Second
+	streamRetryMs           = 3000 // 建议客户端断线后重连的间隔
+	streamSubscriptionID    = "events"
+)
+
+// handleEventStream 以 Server-Sent Events 推送 Hub 广播的事件
+// 查询参数与 WebSocket 订阅的过滤条件相同，Last-Event-ID 请求头或 ?since= 指定补发的起点
+func (s *Server) handleEventStream(c *gin.Context) {
+	filter := streamFilter(c)
+	id := c.DefaultQuery("id", streamSubscriptionID)
+	sub, err := newSubscription(id, filter)
+	if err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	var since *uint64
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("since")
+	}
+	if lastEventID != "" {
+		seq, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			utils.ValidationErrorResponse(c, "Last-Event-ID must be a non-negative integer")
+			return
+		}
+		since = &seq
+	}
+
+	client := newClient(c.Request.Context(), s.hub, nil, "sse:"+c.ClientIP())
+	defer client.cancel()
+	client.since = since
+	client.subscriptions = []*Subscription{sub}
+
+	select {
+	case s.hub.register <- client:
+	case <-s.hub.stop:
+		utils.RespondWithError(c, http.StatusServiceUnavailable, "server shutting down")
+		return
+	}
+	defer func() {
+		select {
+		case s.hub.unregister <- client:
+		case <-s.hub.done:
+		}
+	}()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", streamRetryMs)
+	c.Writer.Flush()
+
+	keepalive := time.NewTicker(streamKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case data, ok := <-client.send:
+			if !ok {
+				return
+			}
+			if err := writeStreamEvent(c.Writer, data); err != nil {
+				logrus.Debugf("Event stream closed: %v", err)
+				return
+			}
+			c.Writer.Flush()
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-s.streamsClosed:
+			return
+		case <-client.ctx.Done():
+			return
+		}
+	}
+}
+
+// writeStreamEvent 写入一个 SSE 事件，事件序号作为 id，消息类型作为 event
+// gap 通知没有序号，不改变客户端记录的 Last-Event-ID
+func writeStreamEvent(w gin.ResponseWriter, data []byte) error {
+	var header struct {
+		Seq  uint64 `json:"seq"`
+		Type string `json:"type"`
+	}
+	json.Unmarshal(data, &header)
+
+	var b strings.Builder
+	if header.Seq > 0 {
+		fmt.Fprintf(&b, "id: %d\n", header.Seq)
+	}
+	if header.Type != "" {
+		fmt.Fprintf(&b, "event: %s\n", header.Type)
+	}
+	fmt.Fprintf(&b, "data: %s\n\n", data)
+	_, err := w.WriteString(b.String())
+	return err
+}
+
+// streamFilter 从查询参数读取过滤条件，列表参数可以重复或用逗号分隔
+func streamFilter(c *gin.Context) SubscriptionFilter {
+	filter := SubscriptionFilter{
+		ThingIDs:   queryList(c, "thingId"),
+		ThingTypes: queryList(c, "thingType"),
+		Events:     queryList(c, "event"),
+		Where:      c.Query("where"),
+	}
+	if related := c.Query("relatedTo"); related != "" {
+		includeSelf, _ := strconv.ParseBool(c.Query("includeSelf"))
+		filter.Relationship = &RelationshipScope{
+			ThingID:     related,
+			Type:        c.Query("relationshipType"),
+			IncludeSelf: includeSelf,
+		}
+	}
+	return filter
+}
+
+// queryList 读取列表查询参数
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, value := range c.QueryArray(key) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamEvent 事件流中的一个事件
+type streamEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// readStreamEvent 读取下一个带数据的事件，跳过 retry 和注释
+func readStreamEvent(t *testing.T, scanner *bufio.Scanner) streamEvent {
+	t.Helper()
+	var event streamEvent
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event.data != "" {
+				return event
+			}
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	t.Fatalf("event stream ended: %v", scanner.Err())
+	return event
+}
+
+// openEventStream 以 Last-Event-ID 打开事件流
+func openEventStream(t *testing.T, hub *Hub, lastEventID string) *bufio.Scanner {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	server := &Server{hub: hub, streamsClosed: make(chan struct{})}
+	router := gin.New()
+	router.GET("/events", server.handleEventStream)
+	ts := httptest.NewServer(router)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", lastEventID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	t.Cleanup(func() {
+		close(server.streamsClosed)
+		resp.Body.Close()
+		cancel()
+		ts.Close()
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", contentType)
+	}
+	return bufio.NewScanner(resp.Body)
+}
+
+func TestEventStreamResumesFromLastEventID(t *testing.T) {
+	hub := startHub(t, 0)
+	broadcastAndWait(t, hub, 5)
+
+	scanner := openEventStream(t, hub, "3")
+	for _, id := range []string{"4", "5"} {
+		if event := readStreamEvent(t, scanner); event.id != id || event.event != "test" {
+			t.Fatalf("expected replayed event %s, got %+v", id, event)
+		}
+	}
+
+	waitFor(t, func() bool { return hub.Stats().Connections == 1 })
+	hub.Broadcast("test", nil)
+	if event := readStreamEvent(t, scanner); event.id != "6" {
+		t.Fatalf("expected live event 6, got %+v", event)
+	}
+}
+
+func TestEventStreamSendsGapForEvictedEvents(t *testing.T) {
+	hub := startHub(t, 2)
+	broadcastAndWait(t, hub, 5)
+
+	scanner := openEventStream(t, hub, "1")
+	gap := readStreamEvent(t, scanner)
+	if gap.event != "gap" || gap.id != "" || !strings.Contains(gap.data, `"from":2,"to":3`) {
+		t.Fatalf("expected gap notice for 2-3 without id, got %+v", gap)
+	}
+	for _, id := range []string{"4", "5"} {
+		if event := readStreamEvent(t, scanner); event.id != id {
+			t.Fatalf("expected replayed event %s, got %+v", id, event)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"uros-restron/internal/models"
+	"uros-restron/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// HistoryHandler 事件历史处理器
+type HistoryHandler struct {
+	historyService *models.HistoryService
+}
+
+// NewHistoryHandler 创建事件历史处理器
+func NewHistoryHandler(historyService *models.HistoryService) *HistoryHandler {
+	return &HistoryHandler{historyService: historyService}
+}
+
+// ListEvents 查询事件历史
+// 支持按 type、aggregate、aggregateId、thingId 过滤，since/until 为 RFC3339 时间
+func (h *HistoryHandler) ListEvents(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid limit parameter")
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid offset parameter")
+		return
+	}
+
+	filter := models.HistoryFilter{
+		Type:        c.Query("type"),
+		Aggregate:   c.Query("aggregate"),
+		AggregateID: c.Query("aggregateId"),
+		ThingID:     c.Query("thingId"),
+		Limit:       limit,
+		Offset:      offset,
+	}
+
+	if filter.Since, err = parseTimeQuery(c, "since"); err != nil {
+		utils.ValidationErrorResponse(c, "Invalid since parameter")
+		return
+	}
+	if filter.Until, err = parseTimeQuery(c, "until"); err != nil {
+		utils.ValidationErrorResponse(c, "Invalid until parameter")
+		return
+	}
+
+	entries, total, err := h.historyService.ListEvents(filter)
+	if err != nil {
+		logrus.Error("Failed to list event history:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list event history")
+		return
+	}
+
+	utils.RespondWithData(c, gin.H{
+		"data":  entries,
+		"count": len(entries),
+		"total": total,
+	})
+}
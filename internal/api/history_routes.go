@@ -0,0 +1,10 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// SetupHistoryRoutes 设置事件历史相关的路由
+func SetupHistoryRoutes(router *gin.RouterGroup, handler *HistoryHandler) {
+	router.GET("/history", handler.ListEvents)
+}
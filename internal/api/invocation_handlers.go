@@ -0,0 +1,127 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"uros-restron/internal/actor"
+	"uros-restron/internal/models"
+	"uros-restron/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// InvocationHandler 函数调用记录处理器
+type InvocationHandler struct {
+	invocationService *models.InvocationService
+	actorManager      *actor.ActorManager
+}
+
+// NewInvocationHandler 创建调用记录处理器
+func NewInvocationHandler(invocationService *models.InvocationService, actorManager *actor.ActorManager) *InvocationHandler {
+	return &InvocationHandler{
+		invocationService: invocationService,
+		actorManager:      actorManager,
+	}
+}
+
+// ListInvocations 查询调用记录
+// 支持按 actorId、thingId、function、caller、status 过滤，since/until 为 RFC3339 时间
+func (h *InvocationHandler) ListInvocations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid limit parameter")
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid offset parameter")
+		return
+	}
+
+	filter := models.InvocationFilter{
+		ActorID:  c.Query("actorId"),
+		ThingID:  c.Query("thingId"),
+		Function: c.Query("function"),
+		Caller:   c.Query("caller"),
+		Status:   models.InvocationStatus(c.Query("status")),
+		Limit:    limit,
+		Offset:   offset,
+	}
+
+	if filter.Since, err = parseTimeQuery(c, "since"); err != nil {
+		utils.ValidationErrorResponse(c, "Invalid since parameter")
+		return
+	}
+	if filter.Until, err = parseTimeQuery(c, "until"); err != nil {
+		utils.ValidationErrorResponse(c, "Invalid until parameter")
+		return
+	}
+
+	invocations, total, err := h.invocationService.ListInvocations(filter)
+	if err != nil {
+		logrus.Error("Failed to list invocations:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list invocations")
+		return
+	}
+
+	utils.RespondWithData(c, gin.H{
+		"data":  invocations,
+		"count": len(invocations),
+		"total": total,
+	})
+}
+
+// GetInvocation 获取单个调用记录
+func (h *InvocationHandler) GetInvocation(c *gin.Context) {
+	invocation, err := h.invocationService.GetInvocation(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Invocation not found")
+		return
+	}
+
+	utils.RespondWithData(c, invocation)
+}
+
+// CancelInvocation 取消正在执行的调用，调用结束后状态变为 cancelled
+func (h *InvocationHandler) CancelInvocation(c *gin.Context) {
+	id := c.Param("id")
+
+	invocation, err := h.invocationService.GetInvocation(id)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Invocation not found")
+		return
+	}
+
+	if err := h.actorManager.CancelInvocation(id); err != nil {
+		if errors.Is(err, actor.ErrInvocationNotRunning) {
+			utils.RespondWithError(c, http.StatusConflict, "Invocation is not running (status: "+string(invocation.Status)+")")
+			return
+		}
+		logrus.Error("Failed to cancel invocation:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to cancel invocation")
+		return
+	}
+
+	utils.RespondWithDataStatus(c, gin.H{
+		"invocationId": id,
+		"status":       "cancelling",
+	}, http.StatusAccepted)
+}
+
+// parseTimeQuery 解析 RFC3339 格式的时间查询参数，参数为空时返回 nil
+func parseTimeQuery(c *gin.Context, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
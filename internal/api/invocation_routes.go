@@ -0,0 +1,12 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// SetupInvocationRoutes 设置函数调用记录相关的路由
+func SetupInvocationRoutes(router *gin.RouterGroup, handler *InvocationHandler) {
+	router.GET("/invocations", handler.ListInvocations)
+	router.GET("/invocations/:id", handler.GetInvocation)
+	router.DELETE("/invocations/:id", handler.CancelInvocation)
+}
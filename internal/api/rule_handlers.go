@@ -0,0 +1,159 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"uros-restron/internal/models"
+	"uros-restron/internal/rules"
+	"uros-restron/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RuleHandler 规则处理器
+type RuleHandler struct {
+	ruleService *models.RuleService
+	engine      *rules.Engine
+}
+
+// NewRuleHandler 创建规则处理器
+func NewRuleHandler(ruleService *models.RuleService, engine *rules.Engine) *RuleHandler {
+	return &RuleHandler{
+		ruleService: ruleService,
+		engine:      engine,
+	}
+}
+
+// ListRules 获取规则列表，支持按 thingId 过滤
+func (h *RuleHandler) ListRules(c *gin.Context) {
+	ruleList, err := h.ruleService.ListRules(c.Query("thingId"))
+	if err != nil {
+		logrus.Error("Failed to list rules:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list rules")
+		return
+	}
+
+	utils.RespondWithData(c, gin.H{
+		"data":  ruleList,
+		"count": len(ruleList),
+	})
+}
+
+// CreateRule 创建规则
+func (h *RuleHandler) CreateRule(c *gin.Context) {
+	var rule models.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	rule.ID = ""
+
+	if err := h.engine.CreateRule(&rule); err != nil {
+		h.respondRuleError(c, "Failed to create rule", err)
+		return
+	}
+
+	utils.RespondWithDataStatus(c, rule, http.StatusCreated)
+}
+
+// GetRule 获取单个规则
+func (h *RuleHandler) GetRule(c *gin.Context) {
+	rule, err := h.ruleService.GetRule(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	utils.RespondWithData(c, rule)
+}
+
+// UpdateRule 更新规则，规则的触发状态被重置
+func (h *RuleHandler) UpdateRule(c *gin.Context) {
+	existing, err := h.ruleService.GetRule(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	var rule models.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	rule.ID = existing.ID
+	rule.CreatedAt = existing.CreatedAt
+	if rule.Enabled == nil {
+		rule.Enabled = existing.Enabled
+	}
+
+	if err := h.engine.UpdateRule(&rule); err != nil {
+		h.respondRuleError(c, "Failed to update rule", err)
+		return
+	}
+
+	utils.RespondWithData(c, rule)
+}
+
+// DeleteRule 删除规则及其评估记录
+func (h *RuleHandler) DeleteRule(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.ruleService.GetRule(id); err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	if err := h.engine.DeleteRule(id); err != nil {
+		logrus.Error("Failed to delete rule:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to delete rule")
+		return
+	}
+
+	utils.RespondWithData(c, gin.H{"message": "Rule deleted successfully"})
+}
+
+// ListEvaluations 查询规则的评估记录，支持按 thingId、outcome 过滤
+func (h *RuleHandler) ListEvaluations(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.ruleService.GetRule(id); err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid limit parameter")
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid offset parameter")
+		return
+	}
+
+	evaluations, total, err := h.ruleService.ListEvaluations(id, c.Query("thingId"), c.Query("outcome"), limit, offset)
+	if err != nil {
+		logrus.Error("Failed to list rule evaluations:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list rule evaluations")
+		return
+	}
+
+	utils.RespondWithData(c, gin.H{
+		"data":  evaluations,
+		"count": len(evaluations),
+		"total": total,
+	})
+}
+
+// respondRuleError 配置无效时返回 400，其余返回 500
+func (h *RuleHandler) respondRuleError(c *gin.Context, message string, err error) {
+	if errors.Is(err, rules.ErrInvalidRule) {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	logrus.Error(message+":", err)
+	utils.RespondWithError(c, http.StatusInternalServerError, message)
+}
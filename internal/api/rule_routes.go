@@ -0,0 +1,15 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRuleRoutes 设置规则相关的路由
+func SetupRuleRoutes(router *gin.RouterGroup, handler *RuleHandler) {
+	router.GET("/rules", handler.ListRules)
+	router.POST("/rules", handler.CreateRule)
+	router.GET("/rules/:id", handler.GetRule)
+	router.PUT("/rules/:id", handler.UpdateRule)
+	router.DELETE("/rules/:id", handler.DeleteRule)
+	router.GET("/rules/:id/evaluations", handler.ListEvaluations)
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"uros-restron/internal/models"
+	"uros-restron/internal/scheduler"
+	"uros-restron/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ScheduleHandler 定时调用计划处理器
+type ScheduleHandler struct {
+	scheduleService *models.ScheduleService
+	scheduler       *scheduler.Scheduler
+}
+
+// NewScheduleHandler 创建计划处理器
+func NewScheduleHandler(scheduleService *models.ScheduleService, scheduler *scheduler.Scheduler) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService: scheduleService,
+		scheduler:       scheduler,
+	}
+}
+
+// ListSchedules 获取计划列表，支持按 actorId、thingId 过滤
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	schedules, err := h.scheduleService.ListSchedules(c.Query("actorId"), c.Query("thingId"))
+	if err != nil {
+		logrus.Error("Failed to list schedules:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list schedules")
+		return
+	}
+
+	utils.RespondWithData(c, gin.H{
+		"data":  schedules,
+		"count": len(schedules),
+	})
+}
+
+// CreateSchedule 创建计划
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	var schedule models.Schedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	schedule.ID = ""
+
+	if err := h.scheduler.CreateSchedule(&schedule); err != nil {
+		h.respondScheduleError(c, "Failed to create schedule", err)
+		return
+	}
+
+	utils.RespondWithDataStatus(c, schedule, http.StatusCreated)
+}
+
+// GetSchedule 获取单个计划
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	schedule, err := h.scheduleService.GetSchedule(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Schedule not found")
+		return
+	}
+
+	utils.RespondWithData(c, schedule)
+}
+
+// UpdateSchedule 更新计划配置，执行记录保持不变
+func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
+	existing, err := h.scheduleService.GetSchedule(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Schedule not found")
+		return
+	}
+
+	var schedule models.Schedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	schedule.ID = existing.ID
+	schedule.CreatedAt = existing.CreatedAt
+	schedule.LastRunAt = existing.LastRunAt
+	schedule.LastStatus = existing.LastStatus
+	schedule.LastError = existing.LastError
+	schedule.LastInvocationID = existing.LastInvocationID
+	schedule.RunCount = existing.RunCount
+	if schedule.Enabled == nil {
+		schedule.Enabled = existing.Enabled
+	}
+
+	if err := h.scheduler.UpdateSchedule(&schedule); err != nil {
+		h.respondScheduleError(c, "Failed to update schedule", err)
+		return
+	}
+
+	utils.RespondWithData(c, schedule)
+}
+
+// DeleteSchedule 删除计划
+func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.scheduleService.GetSchedule(id); err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Schedule not found")
+		return
+	}
+
+	if err := h.scheduler.DeleteSchedule(id); err != nil {
+		logrus.Error("Failed to delete schedule:", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to delete schedule")
+		return
+	}
+
+	utils.RespondWithData(c, gin.H{"message": "Schedule deleted successfully"})
+}
+
+// respondScheduleError 配置无效时返回 400，其余返回 500
+func (h *ScheduleHandler) respondScheduleError(c *gin.Context, message string, err error) {
+	if errors.Is(err, scheduler.ErrInvalidSchedule) {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	logrus.Error(message+":", err)
+	utils.RespondWithError(c, http.StatusInternalServerError, message)
+}
@@ -0,0 +1,14 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// SetupScheduleRoutes 设置定时调用计划相关的路由
+func SetupScheduleRoutes(router *gin.RouterGroup, handler *ScheduleHandler) {
+	router.GET("/schedules", handler.ListSchedules)
+	router.POST("/schedules", handler.CreateSchedule)
+	router.GET("/schedules/:id", handler.GetSchedule)
+	router.PUT("/schedules/:id", handler.UpdateSchedule)
+	router.DELETE("/schedules/:id", handler.DeleteSchedule)
+}
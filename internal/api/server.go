@@ -1,10 +1,15 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"uros-restron/internal/actor"
+	"uros-restron/internal/cluster"
 	"uros-restron/internal/config"
 	"uros-restron/internal/models"
+	"uros-restron/internal/rules"
+	"uros-restron/internal/scheduler"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,22 +20,49 @@ type Server struct {
 	thingTypeService    *models.ThingTypeService
 	relationshipService *models.RelationshipService
 	behaviorService     *models.BehaviorService
+	invocationService   *models.InvocationService
+	historyService      *models.HistoryService
+	scheduleService     *models.ScheduleService
+	scheduler           *scheduler.Scheduler
+	ruleService         *models.RuleService
+	ruleEngine          *rules.Engine
 	actorManager        *actor.ActorManager
+	cluster             *cluster.Cluster
 	hub                 *Hub
+	streamsClosed       chan struct{} // Shutdown 开始时关闭，结束所有事件流
 	router              *gin.Engine
+	httpServer          *http.Server
 }
 
-func NewServer(cfg *config.Config, thingService *models.ThingService, thingTypeService *models.ThingTypeService, relationshipService *models.RelationshipService, behaviorService *models.BehaviorService, actorManager *actor.ActorManager, hub *Hub) *Server {
+func NewServer(cfg *config.Config, thingService *models.ThingService, thingTypeService *models.ThingTypeService, relationshipService *models.RelationshipService, behaviorService *models.BehaviorService, invocationService *models.InvocationService, historyService *models.HistoryService, scheduleService *models.ScheduleService, taskScheduler *scheduler.Scheduler, ruleService *models.RuleService, ruleEngine *rules.Engine, actorManager *actor.ActorManager, clusterNode *cluster.Cluster, hub *Hub) *Server {
 	server := &Server{
 		config:              cfg,
 		thingService:        thingService,
 		thingTypeService:    thingTypeService,
 		relationshipService: relationshipService,
 		behaviorService:     behaviorService,
+		invocationService:   invocationService,
+		historyService:      historyService,
+		scheduleService:     scheduleService,
+		scheduler:           taskScheduler,
+		ruleService:         ruleService,
+		ruleEngine:          ruleEngine,
 		actorManager:        actorManager,
+		cluster:             clusterNode,
 		hub:                 hub,
+		streamsClosed:       make(chan struct{}),
 	}
+	hub.useServices(thingService, relationshipService)
+	hub.useCommands(newDittoCommands(thingService, actorManager))
 	server.setupRoutes()
+	server.httpServer = &http.Server{
+		Addr:    cfg.Server.Host + ":" + cfg.Server.Port,
+		Handler: server.router,
+	}
+	// 事件流不会自行结束，关闭时主动断开，否则 Shutdown 要等到超时
+	server.httpServer.RegisterOnShutdown(func() {
+		close(server.streamsClosed)
+	})
 	return server
 }
 
@@ -71,16 +103,42 @@ func (s *Server) setupRoutes() {
 		SetupRelationshipRoutes(api, relationshipHandler)
 
 		// 行为管理相关路由 - 使用独立的处理器
-		behaviorHandler := NewBehaviorHandler(s.behaviorService, s.thingTypeService, s.thingService, s.hub)
+		behaviorHandler := NewBehaviorHandler(s.behaviorService, s.thingTypeService, s.thingService, s.actorManager, s.hub)
 		SetupBehaviorRoutes(api, behaviorHandler)
 
 		// Actor系统相关路由 - 使用独立的处理器
 		actorHandler := NewActorHandler(s.actorManager, s.hub)
 		SetupActorRoutes(api, actorHandler)
 
+		// 集群相关路由
+		clusterHandler := NewClusterHandler(s.cluster, s.actorManager)
+		SetupClusterRoutes(api, clusterHandler)
+
+		// 函数调用记录相关路由
+		invocationHandler := NewInvocationHandler(s.invocationService, s.actorManager)
+		SetupInvocationRoutes(api, invocationHandler)
+
+		// 事件历史相关路由
+		historyHandler := NewHistoryHandler(s.historyService)
+		SetupHistoryRoutes(api, historyHandler)
+
+		// 定时调用计划相关路由
+		scheduleHandler := NewScheduleHandler(s.scheduleService, s.scheduler)
+		SetupScheduleRoutes(api, scheduleHandler)
+
+		// 规则相关路由
+		ruleHandler := NewRuleHandler(s.ruleService, s.ruleEngine)
+		SetupRuleRoutes(api, ruleHandler)
+
 		// WebSocket 路由
 		api.GET("/ws", s.handleWebSocket)
 
+		// Server-Sent Events 事件流
+		api.GET("/events", s.handleEventStream)
+
+		// WebSocket 和事件流连接统计
+		api.GET("/ws/stats", s.handleHubStats)
+
 	}
 
 	// 健康检查
@@ -89,9 +147,18 @@ func (s *Server) setupRoutes() {
 	})
 }
 
+// Start 启动 HTTP 服务器，阻塞直到出错或 Shutdown 被调用，Shutdown 导致的退出返回 nil
 func (s *Server) Start() error {
-	addr := s.config.Server.Host + ":" + s.config.Server.Port
-	return s.router.Run(addr)
+	if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown 停止接受新连接并等待处理中的请求完成，ctx 结束时返回
+// WebSocket 连接已被接管，由 Hub.Stop 关闭；事件流在 Shutdown 开始时断开
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
 }
 
 // CORS 中间件
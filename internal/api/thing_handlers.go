@@ -91,9 +91,6 @@ func (h *ThingHandler) CreateThing(c *gin.Context) {
 		return
 	}
 
-	// 广播新事物创建事件
-	h.hub.Broadcast("thing_created", thing)
-
 	utils.RespondWithDataStatus(c, thing, http.StatusCreated)
 }
 
@@ -139,9 +136,6 @@ func (h *ThingHandler) UpdateThing(c *gin.Context) {
 		return
 	}
 
-	// 广播更新事件
-	h.hub.Broadcast("thing_updated", thing)
-
 	utils.RespondWithData(c, thing)
 }
 
@@ -155,9 +149,6 @@ func (h *ThingHandler) DeleteThing(c *gin.Context) {
 		return
 	}
 
-	// 广播删除事件
-	h.hub.Broadcast("thing_deleted", gin.H{"id": id})
-
 	utils.RespondWithData(c, gin.H{"message": "Thing deleted successfully"})
 }
 
@@ -177,20 +168,6 @@ func (h *ThingHandler) UpdateStatus(c *gin.Context) {
 		return
 	}
 
-	// 获取更新后的数据
-	thing, err := h.thingService.GetThing(id)
-	if err != nil {
-		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to get updated thing")
-		return
-	}
-
-	// 广播状态更新事件
-	h.hub.Broadcast("status_updated", gin.H{
-		"thingId": id,
-		"status":  status,
-		"thing":   thing,
-	})
-
 	utils.RespondWithData(c, gin.H{"message": "Status updated successfully"})
 }
 
@@ -188,8 +188,5 @@ func (h *ThingTypeHandler) CreateThingFromType(c *gin.Context) {
 		return
 	}
 
-	// 广播新事物创建事件
-	h.hub.Broadcast("thing_created", thing)
-
 	utils.RespondWithDataStatus(c, thing, http.StatusCreated)
 }
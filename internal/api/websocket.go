@@ -1,43 +1,136 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
+
+	"uros-restron/internal/events"
+	"uros-restron/internal/models"
+	"uros-restron/internal/utils"
 )
 
 // Hub 维护活跃的客户端连接
+// 广播的事件先写入事件日志，再由 Run 按序号依次投递，Broadcast 不会阻塞也不会丢弃事件；
+// 每个客户端有容量固定的发送队列，队列已满的客户端被断开，只有 writePump 写连接
 type Hub struct {
 	clients    map[*Client]bool
 	register   chan *Client
 	unregister chan *Client
-	broadcast  chan BroadcastMessage
+	resume     chan resumeRequest
+	notify     chan struct{} // 有新事件时通知 Run，容量为 1
 	mutex      sync.RWMutex
+
+	events    *eventLog // 最近广播的事件，Run 从中读取待投递的事件，也供客户端断线重连后补发
+	delivered uint64    // Run 投递过的最后一个事件序号，原子访问
+
+	stop     chan struct{} // Stop 时关闭，通知 Run 断开所有客户端
+	stopOnce sync.Once
+	done     chan struct{} // Run 退出后关闭
+	writers  sync.WaitGroup
+
+	sentMessages    int64 // 放入发送队列的消息数
+	slowDisconnects int64 // 发送队列已满被断开的客户端数
+	lostEvents      int64 // 投递前已从事件日志中丢弃的事件数
+
+	// 订阅按 Thing 类型和关系过滤时使用
+	thingService        *models.ThingService
+	relationshipService *models.RelationshipService
+
+	// commands 执行客户端发送的 Ditto 协议命令
+	commands *dittoCommands
 }
 
-// Client 表示一个 WebSocket 客户端
+// 连接参数
+const (
+	sendQueueSize  = 256              // 每个客户端发送队列的容量
+	writeWait      = 10 * time.Second // 写一条消息的超时时间
+	pongWait       = 60 * time.Second // 超过该时长没有收到客户端的消息或 pong 时断开
+	maxMessageSize = 64 * 1024        // 客户端消息的最大字节数
+	closeWriteWait = time.Second
+)
+
+// pingPeriod writePump 发送 ping 的间隔，需小于 pongWait
+var pingPeriod = pongWait * 9 / 10
+
+// Client 表示一个 WebSocket 或事件流客户端
 type Client struct {
-	hub     *Hub
-	conn    *websocket.Conn
-	send    chan []byte
-	thingID string // 可选的：只订阅特定事物的更新
+	hub  *Hub
+	conn *websocket.Conn // 事件流客户端为 nil
+	send chan []byte     // 发送队列，只在持有 mu 时写入和关闭
+
+	ctx         context.Context // 连接断开时取消，执行中的命令随之取消
+	cancel      context.CancelFunc
+	caller      string        // 命令调用函数时记录的调用方
+	commands    chan struct{} // 限制同时执行的命令数
+	connectedAt time.Time
+
+	mu               sync.Mutex
+	closed           bool
+	overflowed       bool    // 因发送队列已满被断开
+	since            *uint64 // 连接时的 ?since=，注册后补发之后的事件
+	seq              uint64  // Hub 为该客户端处理过的最后一个事件序号
+	thingID          string  // 可选的：没有订阅时只接收特定事物的更新
+	subscriptions    []*Subscription
+	nextSubscription int
 }
 
-// BroadcastMessage 广播消息结构
+// BroadcastMessage 广播消息结构，Seq 为单调递增的事件序号，投递给订阅客户端时带上匹配的订阅ID
+// 来自领域事件的消息带有聚合和发件箱序号，经发件箱重复发布的事件 Sequence 相同，客户端可据此去重
 type BroadcastMessage struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	Seq             uint64      `json:"seq"`
+	Type            string      `json:"type"`
+	ThingID         string      `json:"thingId,omitempty"`
+	Aggregate       string      `json:"aggregate,omitempty"`
+	AggregateID     string      `json:"aggregateId,omitempty"`
+	Sequence        uint64      `json:"sequence,omitempty"` // 发件箱序号，未经发件箱的事件为 0
+	SubscriptionIDs []string    `json:"subscriptionIds,omitempty"`
+	Data            interface{} `json:"data"`
+
+	domain bool         // 来自领域事件，ThingID 由事件指定，不从数据推断
+	scope  messageScope // 广播时解析，分发和补发时不查询数据库
 }
 
 // Message 客户端消息结构
 type Message struct {
-	Type    string      `json:"type"`
-	ThingID string      `json:"thingId,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
+	Type    string              `json:"type"`
+	ID      string              `json:"id,omitempty"` // 订阅ID，用于 subscribe 和 unsubscribe
+	ThingID string              `json:"thingId,omitempty"`
+	Filter  *SubscriptionFilter `json:"filter,omitempty"`
+	Since   *uint64             `json:"since,omitempty"` // 订阅时补发该序号之后的事件
+	Data    interface{}         `json:"data,omitempty"`
+}
+
+// HubStats 连接和投递统计
+type HubStats struct {
+	Connections     int           `json:"connections"`
+	Sequence        uint64        `json:"sequence"`  // 最后分配的事件序号
+	Delivered       uint64        `json:"delivered"` // 最后投递的事件序号
+	EventLogSize    int           `json:"eventLogSize"`
+	SentMessages    int64         `json:"sentMessages"`
+	SlowDisconnects int64         `json:"slowDisconnects"`
+	LostEvents      int64         `json:"lostEvents"`
+	Clients         []ClientStats `json:"clients"`
+}
+
+// ClientStats 单个客户端的统计
+type ClientStats struct {
+	Caller        string    `json:"caller"`
+	ConnectedAt   time.Time `json:"connectedAt"`
+	Queued        int       `json:"queued"`
+	QueueCapacity int       `json:"queueCapacity"`
+	Subscriptions int       `json:"subscriptions"`
+	Seq           uint64    `json:"seq"`
 }
 
 var upgrader = websocket.Upgrader{
@@ -46,75 +139,200 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// NewHub 创建新的 Hub
-func NewHub() *Hub {
+// NewHub 创建新的 Hub，eventLogSize 为保存的最近事件数，不大于 0 时使用默认值
+func NewHub(eventLogSize int) *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
-		broadcast:  make(chan BroadcastMessage),
+		resume:     make(chan resumeRequest),
+		notify:     make(chan struct{}, 1),
+		events:     newEventLog(eventLogSize),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// newClient 创建客户端，ctx 结束或连接断开时取消客户端的上下文
+func newClient(ctx context.Context, hub *Hub, conn *websocket.Conn, caller string) *Client {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Client{
+		hub:         hub,
+		conn:        conn,
+		send:        make(chan []byte, sendQueueSize),
+		ctx:         ctx,
+		cancel:      cancel,
+		caller:      caller,
+		commands:    make(chan struct{}, maxConcurrentCommands),
+		connectedAt: time.Now(),
 	}
 }
 
-// Run 启动 Hub
+// Run 启动 Hub，Stop 后断开所有客户端并退出
 func (h *Hub) Run() {
+	defer close(h.done)
+
 	for {
 		select {
+		case <-h.stop:
+			h.mutex.Lock()
+			for client := range h.clients {
+				delete(h.clients, client)
+				client.closeSend()
+			}
+			h.mutex.Unlock()
+			logrus.Info("WebSocket hub stopped")
+			return
+
 		case client := <-h.register:
 			h.mutex.Lock()
 			h.clients[client] = true
 			h.mutex.Unlock()
+			client.mu.Lock()
+			client.seq = atomic.LoadUint64(&h.delivered)
+			client.mu.Unlock()
+			if client.since != nil {
+				h.replay(client, *client.since, nil)
+			}
 			logrus.Info("Client connected")
 
 		case client := <-h.unregister:
-			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
-			h.mutex.Unlock()
+			h.removeClients(client)
 			logrus.Info("Client disconnected")
 
-		case message := <-h.broadcast:
-			h.mutex.RLock()
-			for client := range h.clients {
-				// 如果客户端订阅了特定事物，只发送相关消息
-				if client.thingID != "" && message.Type != "thing_created" {
-					// 检查消息是否与客户端订阅的事物相关
-					if !h.isMessageRelevant(client.thingID, message) {
-						continue
-					}
-				}
-
-				select {
-				case client.send <- h.encodeMessage(message):
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
-			}
-			h.mutex.RUnlock()
+		case request := <-h.resume:
+			h.replay(request.client, request.since, request.subscription)
+
+		case <-h.notify:
+			h.dispatch()
 		}
 	}
 }
 
-// isMessageRelevant 检查消息是否与特定事物相关
-func (h *Hub) isMessageRelevant(thingID string, message BroadcastMessage) bool {
-	switch message.Type {
-	case "thing_updated", "property_updated", "status_updated":
-		if data, ok := message.Data.(map[string]interface{}); ok {
-			if id, exists := data["thingId"]; exists {
-				return id == thingID
-			}
-		}
-		return false
-	case "thing_deleted":
-		if data, ok := message.Data.(map[string]interface{}); ok {
-			if id, exists := data["id"]; exists {
-				return id == thingID
+// dispatch 按序号投递事件日志中的新事件
+// Run 落后太多、事件在投递前已被丢弃时，向所有客户端发送 gap 通知
+func (h *Hub) dispatch() {
+	entries, gapFrom, gapTo := h.events.between(atomic.LoadUint64(&h.delivered), math.MaxUint64)
+	if gapFrom > 0 {
+		atomic.AddInt64(&h.lostEvents, int64(gapTo-gapFrom+1))
+		logrus.Warnf("WebSocket hub fell behind, events %d-%d were evicted before delivery", gapFrom, gapTo)
+		data, _ := json.Marshal(gapNotice{Type: "gap", From: gapFrom, To: gapTo})
+		h.deliverEach(func(client *Client) bool {
+			return client.skip(gapTo, data)
+		})
+		atomic.StoreUint64(&h.delivered, gapTo)
+	}
+
+	for _, entry := range entries {
+		scope := newEventScope(h, entry)
+		h.deliverEach(func(client *Client) bool {
+			// 只发送与客户端订阅匹配的消息
+			subscriptionIDs, ok := client.match(scope)
+			if !ok {
+				return true
 			}
+			return client.enqueue(scope.encode(subscriptionIDs))
+		})
+		atomic.StoreUint64(&h.delivered, entry.Seq)
+	}
+}
+
+// deliverEach 对每个客户端执行投递，返回 false 的客户端已断开，随后移除
+func (h *Hub) deliverEach(deliver func(client *Client) bool) {
+	var disconnected []*Client
+	h.mutex.RLock()
+	for client := range h.clients {
+		if !deliver(client) {
+			disconnected = append(disconnected, client)
 		}
+	}
+	h.mutex.RUnlock()
+	h.removeClients(disconnected...)
+}
+
+// removeClients 移除客户端并关闭其发送队列，可以重复移除
+func (h *Hub) removeClients(clients ...*Client) {
+	if len(clients) == 0 {
+		return
+	}
+	h.mutex.Lock()
+	for _, client := range clients {
+		delete(h.clients, client)
+	}
+	h.mutex.Unlock()
+	for _, client := range clients {
+		client.closeSend()
+	}
+}
+
+// Stop 停止 Hub：断开所有客户端并发送关闭帧，等待发送完成或 ctx 结束
+func (h *Hub) Stop(ctx context.Context) error {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+	})
+
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	written := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(written)
+	}()
+	select {
+	case <-written:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stopping 判断 Hub 是否正在停止
+func (h *Hub) stopping() bool {
+	select {
+	case <-h.stop:
+		return true
+	default:
 		return false
+	}
+}
+
+// Stats 返回连接和投递统计
+func (h *Hub) Stats() HubStats {
+	h.mutex.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mutex.RUnlock()
+
+	stats := HubStats{
+		Connections:     len(clients),
+		Sequence:        h.events.last(),
+		Delivered:       atomic.LoadUint64(&h.delivered),
+		EventLogSize:    h.events.capacity(),
+		SentMessages:    atomic.LoadInt64(&h.sentMessages),
+		SlowDisconnects: atomic.LoadInt64(&h.slowDisconnects),
+		LostEvents:      atomic.LoadInt64(&h.lostEvents),
+		Clients:         make([]ClientStats, 0, len(clients)),
+	}
+	for _, client := range clients {
+		stats.Clients = append(stats.Clients, client.stats())
+	}
+	sort.Slice(stats.Clients, func(i, j int) bool {
+		return stats.Clients[i].ConnectedAt.Before(stats.Clients[j].ConnectedAt)
+	})
+	return stats
+}
+
+// isMessageRelevant 检查消息是否与特定事物相关
+func isMessageRelevant(thingID string, scope *eventScope) bool {
+	switch scope.message.Type {
+	case "thing_updated", "property_updated", "status_updated", "thing_deleted":
+		return scope.thingID == thingID
 	default:
 		return true
 	}
@@ -130,91 +348,291 @@ func (h *Hub) encodeMessage(message BroadcastMessage) []byte {
 	return data
 }
 
+// useServices 设置订阅过滤查询 Thing 类型和关系使用的服务
+func (h *Hub) useServices(thingService *models.ThingService, relationshipService *models.RelationshipService) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.thingService = thingService
+	h.relationshipService = relationshipService
+}
+
+// useCommands 设置执行 Ditto 协议命令的处理器
+func (h *Hub) useCommands(commands *dittoCommands) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.commands = commands
+}
+
 // Broadcast 广播消息给所有客户端
 func (h *Hub) Broadcast(messageType string, data interface{}) {
-	message := BroadcastMessage{
-		Type: messageType,
-		Data: data,
+	h.broadcast(BroadcastMessage{Type: messageType, Data: data})
+}
+
+// HandleEvent 把领域事件作为广播消息推送，可直接订阅事件总线
+// 事件携带的 Thing ID 用于订阅过滤，不从消息数据中推断；聚合和发件箱序号原样转发
+func (h *Hub) HandleEvent(event events.Event) {
+	h.broadcast(BroadcastMessage{
+		Type:        string(event.Type),
+		ThingID:     event.ThingID,
+		Aggregate:   event.Aggregate,
+		AggregateID: event.ID,
+		Sequence:    event.Sequence,
+		Data:        event.Data,
+		domain:      true,
+	})
+}
+
+// broadcast 在调用方的 goroutine 中解析订阅过滤需要的 Thing 类型和关系，
+// 再把消息追加到事件日志并唤醒分发；服务端消费者订阅事件总线，不经过 Hub
+func (h *Hub) broadcast(message BroadcastMessage) {
+	if h.stopping() {
+		return
 	}
+
+	message.scope = h.resolveScope(message)
+	h.events.append(message)
 	select {
-	case h.broadcast <- message:
+	case h.notify <- struct{}{}:
 	default:
-		logrus.Warn("Broadcast channel is full, dropping message")
 	}
 }
 
 // handleWebSocket 处理 WebSocket 连接
 func (s *Server) handleWebSocket(c *gin.Context) {
+	thingID := c.Query("thingId") // 可选的：只订阅特定事物
+
+	// 可选的：补发该序号之后的事件
+	var since *uint64
+	if raw := c.Query("since"); raw != "" {
+		seq, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			utils.ValidationErrorResponse(c, "since must be a non-negative integer")
+			return
+		}
+		since = &seq
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		logrus.Error("Failed to upgrade connection:", err)
 		return
 	}
 
-	thingID := c.Query("thingId") // 可选的：只订阅特定事物
+	client := newClient(context.Background(), s.hub, conn, "ws:"+c.ClientIP())
+	client.thingID = thingID
+	client.since = since
 
-	client := &Client{
-		hub:     s.hub,
-		conn:    conn,
-		send:    make(chan []byte, 256),
-		thingID: thingID,
+	// Hub 已停止时直接关闭连接
+	s.hub.writers.Add(1)
+	select {
+	case client.hub.register <- client:
+	case <-client.hub.stop:
+		s.hub.writers.Done()
+		client.cancel()
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(closeWriteWait))
+		conn.Close()
+		return
 	}
 
-	client.hub.register <- client
-
 	// 启动 goroutine 处理客户端
 	go client.writePump()
 	go client.readPump()
 }
 
-// readPump 读取客户端消息
+// readPump 读取客户端消息，超过 pongWait 没有收到消息或 pong 时断开
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.cancel()
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
 	for {
-		var message Message
-		err := c.conn.ReadJSON(&message)
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				logrus.Error("WebSocket error:", err)
 			}
 			break
 		}
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		// 带 topic 的消息为 Ditto 协议命令，其余为订阅等控制消息
+		var message struct {
+			Message
+			Topic *string `json:"topic"`
+		}
+		if err := json.Unmarshal(data, &message); err != nil {
+			c.reply(subscriptionReply{Type: errorReply, Error: "invalid message: " + err.Error()})
+			continue
+		}
+		if message.Topic != nil {
+			var command DittoEnvelope
+			json.Unmarshal(data, &command)
+			c.runCommand(&command)
+			continue
+		}
 
 		// 处理客户端消息
-		c.handleMessage(message)
+		c.handleMessage(message.Message)
 	}
 }
 
-// writePump 向客户端发送消息
+// writePump 是唯一写连接的 goroutine：发送队列中的消息、定期发送 ping，发送队列关闭后发送关闭帧
 func (c *Client) writePump() {
-	defer c.conn.Close()
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		c.hub.writers.Done()
+	}()
 
-	for message := range c.send {
-		if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			logrus.Error("Failed to write message:", err)
-			return
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				c.writeClose()
+				return
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				logrus.Error("Failed to write message:", err)
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
 		}
 	}
 }
 
+// writeClose 发送关闭帧：Hub 停止时为 1001，发送队列溢出时为 1008
+func (c *Client) writeClose() {
+	c.mu.Lock()
+	overflowed := c.overflowed
+	c.mu.Unlock()
+
+	code, reason := websocket.CloseNormalClosure, ""
+	switch {
+	case c.hub.stopping():
+		code, reason = websocket.CloseGoingAway, "server shutting down"
+	case overflowed:
+		code, reason = websocket.ClosePolicyViolation, "send queue overflow"
+	}
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(closeWriteWait))
+}
+
 // handleMessage 处理客户端消息
 func (c *Client) handleMessage(message Message) {
 	switch message.Type {
 	case "subscribe":
-		// 客户端请求订阅特定事物
+		// 旧协议：data 为事物ID，只接收该事物的更新
 		if thingID, ok := message.Data.(string); ok {
+			c.mu.Lock()
 			c.thingID = thingID
+			c.mu.Unlock()
 			logrus.Info("Client subscribed to thing:", thingID)
+			return
 		}
+		c.subscribe(message)
+	case "unsubscribe":
+		c.unsubscribe(message)
+	case "subscriptions":
+		c.listSubscriptions()
 	case "ping":
 		// 心跳检测
-		response := Message{Type: "pong"}
-		c.conn.WriteJSON(response)
+		c.reply(Message{Type: "pong"})
 	default:
 		logrus.Warn("Unknown message type:", message.Type)
+		c.reply(subscriptionReply{Type: errorReply, Error: "unknown message type: " + message.Type})
+	}
+}
+
+// reply 通过发送队列应答客户端
+func (c *Client) reply(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logrus.Error("Failed to encode reply:", err)
+		return
+	}
+	c.enqueue(data)
+}
+
+// enqueue 把消息放入发送队列，客户端已断开或队列已满时返回 false
+func (c *Client) enqueue(data []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enqueueLocked(data)
+}
+
+// enqueueLocked 把消息放入发送队列，队列已满时关闭队列断开客户端，调用方需持有 c.mu
+func (c *Client) enqueueLocked(data []byte) bool {
+	if c.closed {
+		return false
+	}
+	select {
+	case c.send <- data:
+		atomic.AddInt64(&c.hub.sentMessages, 1)
+		return true
+	default:
+	}
+
+	c.overflowed = true
+	c.closeSendLocked()
+	atomic.AddInt64(&c.hub.slowDisconnects, 1)
+	logrus.Warnf("Client %s send queue is full, disconnecting", c.caller)
+	return false
+}
+
+// skip 记录 Hub 跳过了已丢弃的事件并发送 gap 通知
+func (c *Client) skip(seq uint64, notice []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq = seq
+	return c.enqueueLocked(notice)
+}
+
+// closeSend 关闭发送队列，writePump 随后发送关闭帧并退出，可以重复调用
+func (c *Client) closeSend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeSendLocked()
+}
+
+// closeSendLocked 关闭发送队列，调用方需持有 c.mu
+func (c *Client) closeSendLocked() {
+	if !c.closed {
+		c.closed = true
+		close(c.send)
 	}
 }
+
+// stats 返回客户端的统计
+func (c *Client) stats() ClientStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ClientStats{
+		Caller:        c.caller,
+		ConnectedAt:   c.connectedAt,
+		Queued:        len(c.send),
+		QueueCapacity: cap(c.send),
+		Subscriptions: len(c.subscriptions),
+		Seq:           c.seq,
+	}
+}
+
+// handleHubStats 返回 WebSocket 和事件流连接的统计
+func (s *Server) handleHubStats(c *gin.Context) {
+	utils.RespondWithData(c, s.hub.Stats())
+}
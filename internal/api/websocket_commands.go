@@ -0,0 +1,668 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"uros-restron/internal/actor"
+	"uros-restron/internal/models"
+)
+
+// 每个客户端同时执行的命令数，超过时暂停读取新的命令
+const maxConcurrentCommands = 16
+
+// defaultMessageTimeout live 消息等待函数执行结果的默认时间
+const defaultMessageTimeout = 60 * time.Second
+
+// thingDocumentFields 可以通过命令修改的 Thing 字段
+var thingDocumentFields = map[string]bool{
+	"name":        true,
+	"type":        true,
+	"description": true,
+	"attributes":  true,
+	"features":    true,
+	"behaviorId":  true,
+}
+
+// thingReadOnlyFields 整体修改时忽略的只读字段
+var thingReadOnlyFields = map[string]bool{
+	"id":        true,
+	"behavior":  true,
+	"createdAt": true,
+	"updatedAt": true,
+}
+
+// DittoEnvelope Eclipse Ditto 协议信封，命令、响应和错误都使用该结构
+type DittoEnvelope struct {
+	Topic   string                 `json:"topic"`
+	Headers map[string]interface{} `json:"headers,omitempty"`
+	Path    string                 `json:"path"`
+	Value   interface{}            `json:"value,omitempty"`
+	Status  int                    `json:"status,omitempty"`
+}
+
+// dittoTopic 解析后的主题：{namespace}/{name}/things/{channel}/{criterion}/{action}
+type dittoTopic struct {
+	Namespace string
+	Name      string
+	Channel   string // twin 或 live
+	Criterion string // commands 或 messages
+	Action    string // 命令名称，messages 时为消息主题（函数名）
+}
+
+// dittoError 命令失败的状态码和错误码
+type dittoError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *dittoError) Error() string {
+	return e.Message
+}
+
+// newDittoError 创建命令错误
+func newDittoError(status int, code, format string, args ...interface{}) *dittoError {
+	return &dittoError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// parseDittoTopic 解析主题，消息主题中可以包含斜杠
+func parseDittoTopic(topic string) (*dittoTopic, error) {
+	parts := strings.SplitN(topic, "/", 6)
+	if len(parts) != 6 || parts[0] == "" || parts[1] == "" || parts[5] == "" {
+		return nil, fmt.Errorf("topic %q must be {namespace}/{name}/things/{channel}/{criterion}/{action}", topic)
+	}
+	if parts[2] != "things" {
+		return nil, fmt.Errorf("unsupported group %q, only things is supported", parts[2])
+	}
+	return &dittoTopic{
+		Namespace: parts[0],
+		Name:      parts[1],
+		Channel:   parts[3],
+		Criterion: parts[4],
+		Action:    parts[5],
+	}, nil
+}
+
+// ThingID 返回主题对应的 Thing ID，命名空间为 _ 时直接使用名称
+func (t *dittoTopic) ThingID() string {
+	if t.Name == "_" {
+		return ""
+	}
+	if t.Namespace == "_" {
+		return t.Name
+	}
+	return t.Namespace + ":" + t.Name
+}
+
+// forThing 返回指向另一个 Thing 的同类主题，用于创建时生成 ID 的情况
+func (t *dittoTopic) forThing(thingID string) string {
+	namespace, name := "_", thingID
+	if i := strings.Index(thingID, ":"); i > 0 {
+		namespace, name = thingID[:i], thingID[i+1:]
+	}
+	return strings.Join([]string{namespace, name, "things", t.Channel, t.Criterion, t.Action}, "/")
+}
+
+// errorTopic 返回错误响应的主题
+func (t *dittoTopic) errorTopic() string {
+	return strings.Join([]string{t.Namespace, t.Name, "things", t.Channel, "errors"}, "/")
+}
+
+// dittoCommands 执行 WebSocket 上的 Ditto 协议命令
+// twin 命令读写 Thing，与 REST 接口一样由 ThingService 发布 thing_created、thing_updated 和 thing_deleted；
+// live 消息调用 Thing 所关联行为的函数
+type dittoCommands struct {
+	thingService *models.ThingService
+	actorManager *actor.ActorManager
+}
+
+// newDittoCommands 创建命令处理器
+func newDittoCommands(thingService *models.ThingService, actorManager *actor.ActorManager) *dittoCommands {
+	return &dittoCommands{
+		thingService: thingService,
+		actorManager: actorManager,
+	}
+}
+
+// handle 执行命令并返回响应，不需要响应时返回 nil
+func (d *dittoCommands) handle(ctx context.Context, caller string, command *DittoEnvelope) *DittoEnvelope {
+	response := &DittoEnvelope{
+		Topic:   command.Topic,
+		Headers: responseHeaders(command.Headers),
+		Path:    command.Path,
+	}
+	if response.Path == "" {
+		response.Path = "/"
+	}
+
+	topic, err := parseDittoTopic(command.Topic)
+	if err != nil {
+		return d.fail(response, nil, newDittoError(http.StatusBadRequest, "things:topic.invalid", "%v", err))
+	}
+
+	var status int
+	var value interface{}
+	switch {
+	case topic.Channel == "twin" && topic.Criterion == "commands":
+		status, value, err = d.twin(topic, response, command.Value)
+	case topic.Channel == "live" && topic.Criterion == "messages":
+		status, value, err = d.message(ctx, caller, topic, command)
+	default:
+		err = newDittoError(http.StatusBadRequest, "things:topic.invalid", "unsupported criterion %s/%s", topic.Channel, topic.Criterion)
+	}
+	if err != nil {
+		return d.fail(response, topic, err)
+	}
+
+	if required, ok := command.Headers["response-required"].(bool); ok && !required {
+		return nil
+	}
+	response.Status = status
+	response.Value = value
+	return response
+}
+
+// fail 把错误写入响应，主题改为对应的 errors 主题
+func (d *dittoCommands) fail(response *DittoEnvelope, topic *dittoTopic, err error) *DittoEnvelope {
+	var commandErr *dittoError
+	if !errors.As(err, &commandErr) {
+		commandErr = newDittoError(http.StatusInternalServerError, "things:internal.error", "%v", err)
+	}
+	if topic != nil {
+		response.Topic = topic.errorTopic()
+	}
+	response.Status = commandErr.Status
+	response.Value = map[string]interface{}{
+		"status":  commandErr.Status,
+		"error":   commandErr.Code,
+		"message": commandErr.Message,
+	}
+	return response
+}
+
+// twin 执行 Thing 命令
+func (d *dittoCommands) twin(topic *dittoTopic, response *DittoEnvelope, value interface{}) (int, interface{}, error) {
+	segments, err := pathSegments(response.Path)
+	if err != nil {
+		return 0, nil, err
+	}
+	thingID := topic.ThingID()
+
+	if thingID == "" {
+		switch topic.Action {
+		case "create":
+			return d.create(topic, response, "", value)
+		case "retrieve":
+			return d.query(value)
+		}
+		return 0, nil, newDittoError(http.StatusBadRequest, "things:id.missing", "command %s requires a thing id in the topic", topic.Action)
+	}
+
+	switch topic.Action {
+	case "create":
+		if len(segments) > 0 {
+			return 0, nil, newDittoError(http.StatusBadRequest, "things:path.invalid", "create only supports path /")
+		}
+		return d.create(topic, response, thingID, value)
+	case "retrieve":
+		return d.retrieve(thingID, segments)
+	case "modify":
+		return d.modify(topic, response, thingID, segments, value, false)
+	case "merge":
+		return d.modify(topic, response, thingID, segments, value, true)
+	case "delete":
+		return d.delete(thingID, segments)
+	}
+	return 0, nil, newDittoError(http.StatusBadRequest, "things:command.unknown", "unknown command %s", topic.Action)
+}
+
+// create 创建 Thing，ID 为空时自动生成
+func (d *dittoCommands) create(topic *dittoTopic, response *DittoEnvelope, thingID string, value interface{}) (int, interface{}, error) {
+	if thingID != "" {
+		if _, err := d.thingService.GetThing(thingID); err == nil {
+			return 0, nil, newDittoError(http.StatusConflict, "things:thing.conflict", "thing %s already exists", thingID)
+		}
+	}
+
+	document, ok := value.(map[string]interface{})
+	if !ok {
+		return 0, nil, newDittoError(http.StatusBadRequest, "things:payload.invalid", "create requires an object value")
+	}
+	thing := &models.Thing{ID: thingID}
+	if err := applyThingDocument(thing, document); err != nil {
+		return 0, nil, err
+	}
+	if err := d.thingService.CreateThing(thing); err != nil {
+		return 0, nil, fmt.Errorf("failed to create thing: %v", err)
+	}
+
+	response.Topic = topic.forThing(thing.ID)
+	return http.StatusCreated, thing, nil
+}
+
+// query 查询多个 Thing：value 为 {"thingIds": [...]} 或 {"type", "limit", "offset"}
+func (d *dittoCommands) query(value interface{}) (int, interface{}, error) {
+	var options struct {
+		ThingIDs []string `json:"thingIds"`
+		Type     string   `json:"type"`
+		Limit    int      `json:"limit"`
+		Offset   int      `json:"offset"`
+	}
+	if value != nil {
+		raw, _ := json.Marshal(value)
+		if err := json.Unmarshal(raw, &options); err != nil {
+			return 0, nil, newDittoError(http.StatusBadRequest, "things:payload.invalid", "invalid query: %v", err)
+		}
+	}
+
+	if len(options.ThingIDs) > 0 {
+		things := make([]*models.Thing, 0, len(options.ThingIDs))
+		for _, id := range options.ThingIDs {
+			if thing, err := d.thingService.GetThing(id); err == nil {
+				things = append(things, thing)
+			}
+		}
+		return http.StatusOK, things, nil
+	}
+
+	if options.Limit <= 0 {
+		options.Limit = 50
+	}
+	things, err := d.thingService.ListThings(options.Type, options.Limit, options.Offset)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to list things: %v", err)
+	}
+	return http.StatusOK, things, nil
+}
+
+// retrieve 读取 Thing 或其中路径指向的值
+func (d *dittoCommands) retrieve(thingID string, segments []string) (int, interface{}, error) {
+	thing, err := d.getThing(thingID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(segments) == 0 {
+		return http.StatusOK, thing, nil
+	}
+
+	document := normalize(thing)
+	value, ok := getPath(document, segments)
+	if !ok {
+		return 0, nil, newDittoError(http.StatusNotFound, "things:path.notfound", "path /%s not found on thing %s", strings.Join(segments, "/"), thingID)
+	}
+	return http.StatusOK, value, nil
+}
+
+// modify 替换或合并路径指向的值；对不存在的 Thing 整体修改时创建该 Thing
+func (d *dittoCommands) modify(topic *dittoTopic, response *DittoEnvelope, thingID string, segments []string, value interface{}, merge bool) (int, interface{}, error) {
+	thing, err := d.getThing(thingID)
+	if err != nil {
+		var commandErr *dittoError
+		if !merge && len(segments) == 0 && errors.As(err, &commandErr) && commandErr.Status == http.StatusNotFound {
+			return d.create(topic, response, thingID, value)
+		}
+		return 0, nil, err
+	}
+
+	document := thingDocument(thing)
+	if len(segments) == 0 {
+		patch, ok := value.(map[string]interface{})
+		if !ok {
+			return 0, nil, newDittoError(http.StatusBadRequest, "things:payload.invalid", "value for path / must be an object")
+		}
+		if merge {
+			document = mergePatch(document, patch).(map[string]interface{})
+		} else {
+			document = patch
+		}
+	} else {
+		if !thingDocumentFields[segments[0]] {
+			return 0, nil, newDittoError(http.StatusBadRequest, "things:path.invalid", "field %s cannot be modified", segments[0])
+		}
+		if merge {
+			current, _ := getPath(document, segments)
+			value = mergePatch(current, value)
+		}
+		if err := setPath(document, segments, value); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if err := d.save(thing, document); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// delete 删除 Thing 或其中路径指向的值
+func (d *dittoCommands) delete(thingID string, segments []string) (int, interface{}, error) {
+	thing, err := d.getThing(thingID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(segments) == 0 {
+		if err := d.thingService.DeleteThing(thingID); err != nil {
+			return 0, nil, fmt.Errorf("failed to delete thing: %v", err)
+		}
+		return http.StatusNoContent, nil, nil
+	}
+
+	if segments[0] != "attributes" && segments[0] != "features" {
+		return 0, nil, newDittoError(http.StatusBadRequest, "things:path.invalid", "only attributes and features can be deleted")
+	}
+	document := thingDocument(thing)
+	if !deletePath(document, segments) {
+		return 0, nil, newDittoError(http.StatusNotFound, "things:path.notfound", "path /%s not found on thing %s", strings.Join(segments, "/"), thingID)
+	}
+	if err := d.save(thing, document); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// save 把修改后的文档写回 Thing，由 ThingService 发布 thing_updated
+func (d *dittoCommands) save(thing *models.Thing, document map[string]interface{}) error {
+	updated := &models.Thing{ID: thing.ID}
+	if err := applyThingDocument(updated, document); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"name":        updated.Name,
+		"type":        updated.Type,
+		"description": updated.Description,
+		"attributes":  updated.Attributes,
+		"features":    updated.Features,
+		"behavior_id": updated.BehaviorID,
+	}
+	if updated.Attributes == nil {
+		updates["attributes"] = ""
+	}
+	if updated.Features == nil {
+		updates["features"] = ""
+	}
+	if err := d.thingService.UpdateThing(thing.ID, updates); err != nil {
+		return fmt.Errorf("failed to update thing: %v", err)
+	}
+	return nil
+}
+
+// getThing 读取 Thing，不存在时返回 404
+func (d *dittoCommands) getThing(thingID string) (*models.Thing, error) {
+	thing, err := d.thingService.GetThing(thingID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, newDittoError(http.StatusNotFound, "things:thing.notfound", "thing %s not found", thingID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thing: %v", err)
+	}
+	return thing, nil
+}
+
+// message 调用 Thing 所关联行为的函数，消息主题为函数名，value 为参数
+// 请求头 timeout 指定等待结果的时间，例如 "10s"
+func (d *dittoCommands) message(ctx context.Context, caller string, topic *dittoTopic, command *DittoEnvelope) (int, interface{}, error) {
+	thingID := topic.ThingID()
+	if thingID == "" {
+		return 0, nil, newDittoError(http.StatusBadRequest, "things:id.missing", "messages require a thing id in the topic")
+	}
+	thing, err := d.getThing(thingID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if thing.BehaviorID == "" {
+		return 0, nil, newDittoError(http.StatusNotFound, "messages:receiver.notfound", "thing %s has no behavior to receive messages", thingID)
+	}
+
+	params := make(map[string]interface{})
+	if command.Value != nil {
+		values, ok := command.Value.(map[string]interface{})
+		if !ok {
+			return 0, nil, newDittoError(http.StatusBadRequest, "messages:payload.invalid", "message value must be an object of function parameters")
+		}
+		params = values
+	}
+
+	timeout := defaultMessageTimeout
+	if raw, ok := command.Headers["timeout"].(string); ok {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return 0, nil, newDittoError(http.StatusBadRequest, "messages:timeout.invalid", "invalid timeout header %q", raw)
+		}
+		timeout = parsed
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ctx = actor.WithThingID(ctx, thingID)
+	ctx = actor.WithCaller(ctx, caller)
+	result, err := d.actorManager.InvokeFunction(ctx, thing.BehaviorID, topic.Action, params)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return 0, nil, newDittoError(http.StatusRequestTimeout, "messages:timeout", "function %s did not finish within %v", topic.Action, timeout)
+	case errors.Is(err, actor.ErrActorNotFound), errors.Is(err, actor.ErrFunctionNotFound):
+		return 0, nil, newDittoError(http.StatusNotFound, "messages:function.notfound", "%v", err)
+	case errors.Is(err, actor.ErrInvalidParams):
+		return 0, nil, newDittoError(http.StatusBadRequest, "messages:payload.invalid", "%v", err)
+	case errors.Is(err, actor.ErrInvocationLimit):
+		return 0, nil, newDittoError(http.StatusTooManyRequests, "messages:too.many.requests", "%v", err)
+	case err != nil:
+		return 0, nil, newDittoError(http.StatusInternalServerError, "messages:execution.failed", "%v", err)
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"invocationId": result.InvocationID,
+		"result":       result.Output,
+		"durationMs":   result.DurationMs,
+	}, nil
+}
+
+// responseHeaders 复制响应需要带回的请求头
+func responseHeaders(headers map[string]interface{}) map[string]interface{} {
+	response := make(map[string]interface{})
+	if id, ok := headers["correlation-id"]; ok {
+		response["correlation-id"] = id
+	}
+	response["content-type"] = "application/json"
+	return response
+}
+
+// thingDocument 返回 Thing 中可修改的字段
+func thingDocument(thing *models.Thing) map[string]interface{} {
+	document := normalize(thing).(map[string]interface{})
+	for field := range document {
+		if !thingDocumentFields[field] {
+			delete(document, field)
+		}
+	}
+	return document
+}
+
+// applyThingDocument 把文档中的字段写入 Thing，校验字段和类型
+func applyThingDocument(thing *models.Thing, document map[string]interface{}) error {
+	for field, value := range document {
+		if thingReadOnlyFields[field] {
+			continue
+		}
+		if !thingDocumentFields[field] {
+			return newDittoError(http.StatusBadRequest, "things:payload.invalid", "unknown field %s", field)
+		}
+
+		switch field {
+		case "attributes", "features":
+			if value == nil {
+				continue
+			}
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return newDittoError(http.StatusBadRequest, "things:payload.invalid", "%s must be an object", field)
+			}
+			if field == "attributes" {
+				thing.Attributes = object
+			} else {
+				thing.Features = object
+			}
+		default:
+			if value == nil {
+				continue
+			}
+			text, ok := value.(string)
+			if !ok {
+				return newDittoError(http.StatusBadRequest, "things:payload.invalid", "%s must be a string", field)
+			}
+			switch field {
+			case "name":
+				thing.Name = text
+			case "type":
+				thing.Type = text
+			case "description":
+				thing.Description = text
+			case "behaviorId":
+				thing.BehaviorID = text
+			}
+		}
+	}
+	return nil
+}
+
+// pathSegments 把 JSON 指针形式的路径拆分为字段
+func pathSegments(path string) ([]string, error) {
+	if path == "" || path == "/" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, newDittoError(http.StatusBadRequest, "things:path.invalid", "path %q must start with /", path)
+	}
+	segments := strings.Split(strings.TrimSuffix(path[1:], "/"), "/")
+	for i, segment := range segments {
+		if segment == "" {
+			return nil, newDittoError(http.StatusBadRequest, "things:path.invalid", "path %q contains an empty segment", path)
+		}
+		segments[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+	}
+	return segments, nil
+}
+
+// normalize 通过 JSON 编码把值转换为 map 和 slice
+func normalize(value interface{}) interface{} {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var normalized interface{}
+	json.Unmarshal(raw, &normalized)
+	return normalized
+}
+
+// getPath 读取路径指向的值
+func getPath(document interface{}, segments []string) (interface{}, bool) {
+	current := document
+	for _, segment := range segments {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[segment]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setPath 设置路径指向的值，自动创建中间对象
+func setPath(document map[string]interface{}, segments []string, value interface{}) error {
+	current := document
+	for _, segment := range segments[:len(segments)-1] {
+		next, ok := current[segment].(map[string]interface{})
+		if !ok {
+			if current[segment] != nil {
+				return newDittoError(http.StatusBadRequest, "things:path.invalid", "%s is not an object", segment)
+			}
+			next = make(map[string]interface{})
+			current[segment] = next
+		}
+		current = next
+	}
+	current[segments[len(segments)-1]] = value
+	return nil
+}
+
+// deletePath 删除路径指向的值，不存在时返回 false
+func deletePath(document map[string]interface{}, segments []string) bool {
+	parent, ok := getPath(document, segments[:len(segments)-1])
+	if !ok {
+		return false
+	}
+	object, ok := parent.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, exists := object[segments[len(segments)-1]]; !exists {
+		return false
+	}
+	delete(object, segments[len(segments)-1])
+	return true
+}
+
+// mergePatch 按 RFC 7396 合并，patch 中的 null 删除对应字段
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// runCommand 在独立的 goroutine 中执行命令并通过发送通道返回响应
+func (c *Client) runCommand(command *DittoEnvelope) {
+	commands := c.hub.commands
+	if commands == nil {
+		c.reply(&DittoEnvelope{
+			Topic:   command.Topic,
+			Headers: responseHeaders(command.Headers),
+			Path:    command.Path,
+			Status:  http.StatusServiceUnavailable,
+			Value:   map[string]interface{}{"status": http.StatusServiceUnavailable, "error": "things:unavailable", "message": "commands are not supported"},
+		})
+		return
+	}
+
+	select {
+	case c.commands <- struct{}{}:
+	case <-c.ctx.Done():
+		return
+	}
+	go func() {
+		defer func() { <-c.commands }()
+
+		response := commands.handle(c.ctx, c.caller, command)
+		if response == nil {
+			return
+		}
+		if response.Status >= 400 {
+			logrus.Warnf("WebSocket command %s failed with status %d", command.Topic, response.Status)
+		}
+		c.reply(response)
+	}()
+}
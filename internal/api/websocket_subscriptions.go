@@ -0,0 +1,351 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"uros-restron/internal/expr"
+	"uros-restron/internal/models"
+)
+
+// maxSubscriptionsPerClient 每个客户端最多持有的订阅数
+const maxSubscriptionsPerClient = 32
+
+// 服务端发给客户端的订阅应答类型
+const (
+	subscribedReply    = "subscribed"
+	unsubscribedReply  = "unsubscribed"
+	subscriptionsReply = "subscriptions"
+	errorReply         = "error"
+)
+
+// RelationshipScope 按关系限定订阅范围：与 ThingID 存在关系的 Thing 的事件
+type RelationshipScope struct {
+	ThingID     string `json:"thingId"`
+	Type        string `json:"type,omitempty"`        // 关系类型，为空时不限
+	IncludeSelf bool   `json:"includeSelf,omitempty"` // 同时匹配 ThingID 本身的事件
+}
+
+// SubscriptionFilter 订阅过滤条件
+// 不同条件之间为“与”，同一列表中的值为“或”，未设置的条件不做限制
+type SubscriptionFilter struct {
+	ThingIDs     []string           `json:"thingIds,omitempty"`
+	ThingTypes   []string           `json:"thingTypes,omitempty"`
+	Events       []string           `json:"events,omitempty"`
+	Where        string             `json:"where,omitempty"` // 表达式，可以引用 type、thingId、thingType 和 data
+	Relationship *RelationshipScope `json:"relationship,omitempty"`
+}
+
+// Subscription 客户端的一个订阅
+type Subscription struct {
+	ID     string             `json:"id"`
+	Filter SubscriptionFilter `json:"filter"`
+
+	where   *expr.Expression
+	pending bool // 等待 Hub 补发历史事件，补发完成前不匹配新事件
+}
+
+// subscriptionReply 订阅请求的应答
+type subscriptionReply struct {
+	Type           string              `json:"type"`
+	SubscriptionID string              `json:"subscriptionId,omitempty"`
+	Filter         *SubscriptionFilter `json:"filter,omitempty"`
+	Subscriptions  []*Subscription     `json:"subscriptions,omitempty"`
+	Error          string              `json:"error,omitempty"`
+}
+
+// newSubscription 校验过滤条件并编译表达式
+func newSubscription(id string, filter SubscriptionFilter) (*Subscription, error) {
+	for _, event := range filter.Events {
+		if strings.TrimSpace(event) == "" {
+			return nil, fmt.Errorf("event types must not be empty")
+		}
+	}
+	if filter.Relationship != nil && filter.Relationship.ThingID == "" {
+		return nil, fmt.Errorf("relationship scope requires thingId")
+	}
+
+	sub := &Subscription{ID: id, Filter: filter}
+	if filter.Where != "" {
+		where, err := expr.Parse(filter.Where)
+		if err != nil {
+			return nil, fmt.Errorf("invalid where expression: %v", err)
+		}
+		sub.where = where
+	}
+	return sub, nil
+}
+
+// matches 判断订阅是否匹配广播消息
+func (s *Subscription) matches(scope *eventScope) bool {
+	filter := &s.Filter
+	if len(filter.Events) > 0 && !contains(filter.Events, scope.message.Type) {
+		return false
+	}
+	if len(filter.ThingIDs) > 0 && !contains(filter.ThingIDs, scope.thingID) {
+		return false
+	}
+	if len(filter.ThingTypes) > 0 && !contains(filter.ThingTypes, scope.thingType()) {
+		return false
+	}
+	if filter.Relationship != nil && !scope.relatedTo(filter.Relationship) {
+		return false
+	}
+	if s.where != nil {
+		matched, err := s.where.EvalBool(scope.env())
+		if err != nil {
+			logrus.Debugf("Subscription %s filter failed on %s: %v", s.ID, scope.message.Type, err)
+			return false
+		}
+		return matched
+	}
+	return true
+}
+
+// messageScope 订阅过滤使用的消息范围，在广播时由发布方解析，随消息保存在事件日志中
+// Hub 的分发和补发持有锁，只读取这里的结果，不查询数据库
+type messageScope struct {
+	data      interface{} // 经 JSON 归一化的消息数据
+	thingID   string
+	thingType string
+	related   []models.Relationship
+}
+
+// resolveScope 归一化消息数据并查询所属 Thing 的类型和关系，在调用 Broadcast 的 goroutine 中执行
+func (h *Hub) resolveScope(message BroadcastMessage) messageScope {
+	scope := messageScope{thingID: message.ThingID}
+	if raw, err := json.Marshal(message.Data); err == nil {
+		json.Unmarshal(raw, &scope.data)
+	}
+	fields, _ := scope.data.(map[string]interface{})
+	if fields != nil && !message.domain {
+		// 直接广播的消息依次查找 thingId 和 id 字段
+		if id, _ := fields["thingId"].(string); id != "" {
+			scope.thingID = id
+		} else if id, _ := fields["id"].(string); id != "" {
+			scope.thingID = id
+		}
+	}
+	if scope.thingID == "" {
+		return scope
+	}
+
+	h.mutex.RLock()
+	thingService, relationshipService := h.thingService, h.relationshipService
+	h.mutex.RUnlock()
+
+	// 消息数据本身是 Thing 时直接读取类型，thing_deleted 的数据带有被删除 Thing 的类型
+	if fields != nil && fields["id"] == scope.thingID {
+		scope.thingType, _ = fields["type"].(string)
+	}
+	if scope.thingType == "" && thingService != nil {
+		if thing, err := thingService.GetThing(scope.thingID); err == nil {
+			scope.thingType = thing.Type
+		}
+	}
+	if relationshipService != nil {
+		relationships, err := relationshipService.GetThingRelationships(scope.thingID)
+		if err != nil {
+			logrus.Warnf("Failed to load relationships of %s: %v", scope.thingID, err)
+		}
+		scope.related = relationships
+	}
+	return scope
+}
+
+// eventScope 广播消息的匹配上下文，每条消息在分发或补发时创建一次
+type eventScope struct {
+	hub     *Hub
+	message BroadcastMessage
+	data    interface{}
+	thingID string
+	encoded map[string][]byte
+}
+
+// newEventScope 创建广播消息的匹配上下文
+func newEventScope(hub *Hub, message BroadcastMessage) *eventScope {
+	return &eventScope{
+		hub:     hub,
+		message: message,
+		data:    message.scope.data,
+		thingID: message.scope.thingID,
+		encoded: make(map[string][]byte),
+	}
+}
+
+// thingType 返回消息所属 Thing 的类型
+func (s *eventScope) thingType() string {
+	return s.message.scope.thingType
+}
+
+// relatedTo 判断消息所属 Thing 是否在关系范围内
+func (s *eventScope) relatedTo(scope *RelationshipScope) bool {
+	if s.thingID == "" {
+		return false
+	}
+	if s.thingID == scope.ThingID {
+		return scope.IncludeSelf
+	}
+	for _, relationship := range s.message.scope.related {
+		if scope.Type != "" && string(relationship.Type) != scope.Type {
+			continue
+		}
+		if relationship.SourceID == scope.ThingID || relationship.TargetID == scope.ThingID {
+			return true
+		}
+	}
+	return false
+}
+
+// env 返回 where 表达式的求值环境
+func (s *eventScope) env() expr.Env {
+	return expr.Env{
+		"type":      s.message.Type,
+		"thingId":   s.thingID,
+		"thingType": s.thingType(),
+		"data":      s.data,
+	}
+}
+
+// encode 编码投递给客户端的消息，相同订阅ID组合的编码结果会被复用
+func (s *eventScope) encode(subscriptionIDs []string) []byte {
+	key := strings.Join(subscriptionIDs, "\x00")
+	if data, ok := s.encoded[key]; ok {
+		return data
+	}
+	message := s.message
+	message.ThingID = s.thingID
+	message.SubscriptionIDs = subscriptionIDs
+	data := s.hub.encodeMessage(message)
+	s.encoded[key] = data
+	return data
+}
+
+// match 返回与消息匹配的订阅ID并记录处理过的事件序号
+func (c *Client) match(scope *eventScope) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq = scope.message.Seq
+	return c.matchLocked(scope)
+}
+
+// matchLocked 返回与消息匹配的订阅ID；没有订阅的客户端沿用按 thingId 过滤的旧行为，调用方需持有 c.mu
+func (c *Client) matchLocked(scope *eventScope) ([]string, bool) {
+	if len(c.subscriptions) == 0 {
+		if c.thingID == "" || scope.message.Type == "thing_created" {
+			return nil, true
+		}
+		return nil, isMessageRelevant(c.thingID, scope)
+	}
+
+	var ids []string
+	for _, sub := range c.subscriptions {
+		if !sub.pending && sub.matches(scope) {
+			ids = append(ids, sub.ID)
+		}
+	}
+	return ids, len(ids) > 0
+}
+
+// subscribe 添加或替换订阅，未指定ID时自动生成
+func (c *Client) subscribe(message Message) {
+	id := message.ID
+	var filter SubscriptionFilter
+	if message.Filter != nil {
+		filter = *message.Filter
+	}
+
+	sub, err := newSubscription(id, filter)
+	if err != nil {
+		c.reply(subscriptionReply{Type: errorReply, SubscriptionID: id, Error: err.Error()})
+		return
+	}
+
+	c.mu.Lock()
+	if id == "" {
+		c.nextSubscription++
+		id = fmt.Sprintf("sub-%d", c.nextSubscription)
+		sub.ID = id
+	}
+	replaced := false
+	for i, existing := range c.subscriptions {
+		if existing.ID == id {
+			c.subscriptions[i] = sub
+			replaced = true
+			break
+		}
+	}
+	if !replaced && len(c.subscriptions) >= maxSubscriptionsPerClient {
+		c.mu.Unlock()
+		c.reply(subscriptionReply{Type: errorReply, SubscriptionID: id, Error: fmt.Sprintf("too many subscriptions, limit is %d", maxSubscriptionsPerClient)})
+		return
+	}
+	if !replaced {
+		c.subscriptions = append(c.subscriptions, sub)
+	}
+	sub.pending = message.Since != nil
+	c.mu.Unlock()
+
+	logrus.Infof("Client subscribed: %s", id)
+	c.reply(subscriptionReply{Type: subscribedReply, SubscriptionID: id, Filter: &sub.Filter})
+
+	// 由 Hub 补发历史事件，保证补发的事件排在之后的新事件前面
+	if message.Since != nil {
+		select {
+		case c.hub.resume <- resumeRequest{client: c, subscription: sub, since: *message.Since}:
+		case <-c.hub.done:
+		}
+	}
+}
+
+// unsubscribe 取消订阅
+func (c *Client) unsubscribe(message Message) {
+	if message.ID == "" {
+		c.reply(subscriptionReply{Type: errorReply, Error: "subscription id is required"})
+		return
+	}
+
+	c.mu.Lock()
+	found := false
+	for i, sub := range c.subscriptions {
+		if sub.ID == message.ID {
+			c.subscriptions = append(c.subscriptions[:i], c.subscriptions[i+1:]...)
+			found = true
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	if !found {
+		c.reply(subscriptionReply{Type: errorReply, SubscriptionID: message.ID, Error: "subscription not found"})
+		return
+	}
+	c.reply(subscriptionReply{Type: unsubscribedReply, SubscriptionID: message.ID})
+}
+
+// listSubscriptions 返回客户端当前的订阅
+func (c *Client) listSubscriptions() {
+	c.mu.Lock()
+	subscriptions := make([]*Subscription, len(c.subscriptions))
+	copy(subscriptions, c.subscriptions)
+	c.mu.Unlock()
+
+	sort.SliceStable(subscriptions, func(i, j int) bool {
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+	c.reply(subscriptionReply{Type: subscriptionsReply, Subscriptions: subscriptions})
+}
+
+// contains 判断字符串是否在列表中
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"uros-restron/internal/database"
+	"uros-restron/internal/models"
+)
+
+func TestThingTypeSubscriptionReceivesDeletes(t *testing.T) {
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "things.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close(db)
+	if err := db.AutoMigrate(&models.Behavior{}, &models.Thing{}, &models.Relationship{}, &models.OutboxEvent{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	outbox := models.NewOutboxService(db)
+	thingService := models.NewThingService(db)
+	thingService.SetOutbox(outbox)
+
+	hub := startHub(t, 0)
+	hub.useServices(thingService, models.NewRelationshipService(db))
+
+	for _, thing := range []*models.Thing{
+		{ID: "lamp-1", Name: "lamp", Type: "lamp"},
+		{ID: "fan-1", Name: "fan", Type: "fan"},
+	} {
+		if err := thingService.CreateThing(thing); err != nil {
+			t.Fatalf("failed to create %s: %v", thing.ID, err)
+		}
+	}
+	sub, err := newSubscription("lamps", SubscriptionFilter{ThingTypes: []string{"lamp"}, Events: []string{"thing_deleted"}})
+	if err != nil {
+		t.Fatalf("invalid subscription: %v", err)
+	}
+	client := newClient(context.Background(), hub, nil, "test")
+	client.subscriptions = []*Subscription{sub}
+	hub.register <- client
+
+	for _, id := range []string{"fan-1", "lamp-1"} {
+		if err := thingService.DeleteThing(id); err != nil {
+			t.Fatalf("failed to delete %s: %v", id, err)
+		}
+	}
+
+	// 与发件箱分发器一样把事件交给 Hub，此时 Thing 已从数据库删除
+	pending, err := outbox.Pending(0, 10)
+	if err != nil {
+		t.Fatalf("failed to read outbox: %v", err)
+	}
+	for i := range pending {
+		hub.HandleEvent(pending[i].Event())
+	}
+
+	message := receive(t, client)
+	if message["type"] != "thing_deleted" || message["thingId"] != "lamp-1" {
+		t.Fatalf("expected the lamp deletion, got %v", message)
+	}
+	broadcastAndWait(t, hub, 0)
+	select {
+	case data := <-client.send:
+		t.Fatalf("unexpected message %s", data)
+	default:
+	}
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const testTimeout = 5 * time.Second
+
+// startHub 启动 Hub，测试结束时停止
+func startHub(t *testing.T, eventLogSize int) *Hub {
+	t.Helper()
+	hub := NewHub(eventLogSize)
+	go hub.Run()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		if err := hub.Stop(ctx); err != nil {
+			t.Errorf("hub did not stop: %v", err)
+		}
+	})
+	return hub
+}
+
+// broadcastAndWait 广播 n 条消息并等待 Run 投递完成
+func broadcastAndWait(t *testing.T, hub *Hub, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		hub.Broadcast("test", map[string]interface{}{"n": i})
+	}
+	last := hub.events.last()
+	waitFor(t, func() bool { return hub.Stats().Delivered == last })
+}
+
+// registerClient 注册没有连接的客户端，与事件流客户端相同
+func registerClient(t *testing.T, hub *Hub, since *uint64) *Client {
+	t.Helper()
+	client := newClient(context.Background(), hub, nil, "test")
+	client.since = since
+	select {
+	case hub.register <- client:
+	case <-time.After(testTimeout):
+		t.Fatal("hub did not accept the client")
+	}
+	return client
+}
+
+// waitFor 等待条件成立
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// receive 从客户端的发送队列读取一条消息
+func receive(t *testing.T, client *Client) map[string]interface{} {
+	t.Helper()
+	select {
+	case data, ok := <-client.send:
+		if !ok {
+			t.Fatal("send queue closed")
+		}
+		var message map[string]interface{}
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("invalid message %s: %v", data, err)
+		}
+		return message
+	case <-time.After(testTimeout):
+		t.Fatal("no message received")
+	}
+	return nil
+}
+
+func TestHubDisconnectsSlowConsumer(t *testing.T) {
+	hub := startHub(t, 0)
+	client := registerClient(t, hub, nil)
+
+	broadcastAndWait(t, hub, sendQueueSize+10)
+
+	stats := hub.Stats()
+	if stats.SlowDisconnects != 1 {
+		t.Fatalf("expected 1 slow disconnect, got %d", stats.SlowDisconnects)
+	}
+	if stats.Connections != 0 {
+		t.Fatalf("expected the slow client to be removed, %d connections left", stats.Connections)
+	}
+
+	// 断开前放入队列的消息仍可读取，之后队列关闭
+	received := 0
+	for range client.send {
+		received++
+	}
+	if received != sendQueueSize {
+		t.Fatalf("expected %d queued messages, got %d", sendQueueSize, received)
+	}
+	client.mu.Lock()
+	overflowed := client.overflowed
+	client.mu.Unlock()
+	if !overflowed {
+		t.Fatal("client not marked as overflowed")
+	}
+}
+
+func TestHubUnregisterTwice(t *testing.T) {
+	hub := startHub(t, 0)
+	client := registerClient(t, hub, nil)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case hub.unregister <- client:
+		case <-time.After(testTimeout):
+			t.Fatal("hub did not accept the unregistration")
+		}
+	}
+	client.closeSend()
+
+	if _, ok := <-client.send; ok {
+		t.Fatal("send queue not closed")
+	}
+	if n := hub.Stats().Connections; n != 0 {
+		t.Fatalf("expected no connections, got %d", n)
+	}
+
+	// 已移除的客户端不再接收消息
+	broadcastAndWait(t, hub, 1)
+	if client.enqueue([]byte("{}")) {
+		t.Fatal("enqueue succeeded on a closed client")
+	}
+}
+
+func TestHubReplaySinceWithGap(t *testing.T) {
+	hub := startHub(t, 4)
+	broadcastAndWait(t, hub, 10)
+
+	since := uint64(2)
+	client := registerClient(t, hub, &since)
+
+	gap := receive(t, client)
+	if gap["type"] != "gap" || gap["from"] != float64(3) || gap["to"] != float64(6) {
+		t.Fatalf("expected gap notice for 3-6, got %v", gap)
+	}
+	for seq := 7; seq <= 10; seq++ {
+		message := receive(t, client)
+		if message["seq"] != float64(seq) {
+			t.Fatalf("expected event %d, got %v", seq, message)
+		}
+	}
+
+	// 补发之后继续接收实时事件
+	hub.Broadcast("test", nil)
+	if message := receive(t, client); message["seq"] != float64(11) {
+		t.Fatalf("expected live event 11, got %v", message)
+	}
+}
+
+func TestHubReplayOverflowSendsGap(t *testing.T) {
+	hub := startHub(t, 1000)
+	broadcastAndWait(t, hub, sendQueueSize)
+
+	since := uint64(0)
+	client := registerClient(t, hub, &since)
+
+	// 补发只占用四分之三的队列，其中一个位置留给 gap 通知
+	room := sendQueueSize - sendQueueSize/4 - 1
+	gap := receive(t, client)
+	expectedTo := float64(sendQueueSize - room)
+	if gap["type"] != "gap" || gap["from"] != float64(1) || gap["to"] != expectedTo {
+		t.Fatalf("expected gap notice for 1-%v, got %v", expectedTo, gap)
+	}
+	if message := receive(t, client); message["seq"] != expectedTo+1 {
+		t.Fatalf("expected replay to continue at %v, got %v", expectedTo+1, message)
+	}
+	if n := hub.Stats().SlowDisconnects; n != 0 {
+		t.Fatalf("replay disconnected the client: %d slow disconnects", n)
+	}
+}
+
+func TestWebSocketPingWhileWriting(t *testing.T) {
+	defaultPingPeriod := pingPeriod
+	pingPeriod = 10 * time.Millisecond
+	defer func() { pingPeriod = defaultPingPeriod }()
+
+	gin.SetMode(gin.TestMode)
+	hub := startHub(t, 0)
+	server := &Server{hub: hub}
+	router := gin.New()
+	router.GET("/ws", server.handleWebSocket)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	pings := make(chan struct{}, 1024)
+	conn.SetPingHandler(func(data string) error {
+		pings <- struct{}{}
+		// 服务端关闭连接后 pong 写入失败，不影响读取关闭帧
+		conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		return nil
+	})
+	waitFor(t, func() bool { return hub.Stats().Connections == 1 })
+
+	// 广播和应用层心跳的应答与 ping 一起写入连接
+	const broadcasts = 200
+	go func() {
+		for i := 0; i < broadcasts; i++ {
+			hub.Broadcast("test", map[string]interface{}{"n": i})
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	if err := conn.WriteJSON(Message{Type: "ping"}); err != nil {
+		t.Fatalf("failed to send ping message: %v", err)
+	}
+
+	received, pongs := 0, 0
+	conn.SetReadDeadline(time.Now().Add(testTimeout))
+	for received < broadcasts || pongs == 0 || len(pings) == 0 {
+		var message map[string]interface{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("read failed after %d messages: %v", received, err)
+		}
+		switch message["type"] {
+		case "test":
+			received++
+		case "pong":
+			pongs++
+		}
+	}
+
+	// Hub 停止时 writePump 发送 1001 关闭帧，服务端随即关闭连接，不回复关闭帧
+	conn.SetCloseHandler(func(int, string) error { return nil })
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := hub.Stop(ctx); err != nil {
+		t.Fatalf("hub did not stop: %v", err)
+	}
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+				t.Fatalf("expected going away close, got %v", err)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,484 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"uros-restron/internal/actor"
+)
+
+// 节点之间通信使用的路径和请求头
+const (
+	PingPath     = "/api/v1/cluster/ping"
+	MessagesPath = "/api/v1/cluster/messages"
+	// InvocationsPath 转发函数调用的路径
+	InvocationsPath = "/api/v1/cluster/invocations"
+
+	// NodeHeader 转发请求携带的来源节点ID
+	NodeHeader = "X-Restron-Node"
+)
+
+// 默认的探测和转发参数
+const (
+	defaultPingInterval   = 2 * time.Second
+	defaultPingTimeout    = time.Second
+	defaultForwardTimeout = 10 * time.Second
+)
+
+// ErrNodeUnavailable 目标节点不可达
+var ErrNodeUnavailable = errors.New("cluster node is unavailable")
+
+// Config 集群配置，节点通过静态列表互相发现
+type Config struct {
+	NodeID       string
+	Nodes        map[string]string // 节点ID到基础地址，例如 http://localhost:8081，包含本节点
+	PingInterval time.Duration
+	VirtualNodes int
+	// StoreID 本节点数据库的标识，设置后只有报告相同标识的节点才能加入哈希环
+	// 转发的调用在负责的节点读取 Thing、事件日志和快照，所有节点必须使用同一个数据库
+	StoreID string
+}
+
+// ParseNodes 解析 "n1=http://localhost:8081,n2=http://localhost:8082" 形式的节点列表
+func ParseNodes(spec string) (map[string]string, error) {
+	nodes := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid cluster node %q, expected id=address", entry)
+		}
+		nodes[strings.TrimSpace(parts[0])] = strings.TrimRight(strings.TrimSpace(parts[1]), "/")
+	}
+	return nodes, nil
+}
+
+// Node 集群节点及其可达状态
+type Node struct {
+	ID       string     `json:"id"`
+	Address  string     `json:"address"`
+	Self     bool       `json:"self"`
+	Alive    bool       `json:"alive"`
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Cluster 集群成员和消息放置
+// 可达节点组成一致性哈希环，带 Thing 的消息按 Thing ID 放置，其他消息按 Actor ID 放置；
+// 成员变化时重建哈希环并钝化本节点的 Actor，之后的消息按新的归属路由
+type Cluster struct {
+	config       Config
+	actorManager *actor.ActorManager
+	client       *http.Client
+
+	nodes map[string]*Node
+	ring  *HashRing
+	mu    sync.RWMutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New 创建集群节点，本节点必须出现在节点列表中
+func New(config Config, actorManager *actor.ActorManager) (*Cluster, error) {
+	if config.NodeID == "" {
+		return nil, fmt.Errorf("cluster node id is required")
+	}
+	if _, ok := config.Nodes[config.NodeID]; !ok {
+		return nil, fmt.Errorf("cluster node %s is not in the node list", config.NodeID)
+	}
+	if config.PingInterval <= 0 {
+		config.PingInterval = defaultPingInterval
+	}
+
+	c := &Cluster{
+		config:       config,
+		actorManager: actorManager,
+		client:       &http.Client{},
+		nodes:        make(map[string]*Node),
+		ring:         NewHashRing(config.VirtualNodes),
+	}
+	for id, address := range config.Nodes {
+		// 其他节点在第一次探测成功后才加入哈希环
+		c.nodes[id] = &Node{ID: id, Address: address, Self: id == config.NodeID, Alive: id == config.NodeID}
+	}
+	c.rebuildLocked()
+	return c, nil
+}
+
+// NodeID 返回本节点ID
+func (c *Cluster) NodeID() string {
+	return c.config.NodeID
+}
+
+// StoreID 返回本节点数据库的标识
+func (c *Cluster) StoreID() string {
+	return c.config.StoreID
+}
+
+// Start 立即探测一次其他节点，之后定期探测，ctx 取消或调用 Stop 后停止
+func (c *Cluster) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	c.probe(ctx)
+
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.config.PingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.probe(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	log.Printf("Cluster node %s started with %d nodes", c.config.NodeID, len(c.config.Nodes))
+}
+
+// Stop 停止探测并等待正在进行的探测和重新平衡完成，ctx 到期时不再等待
+// 停止后成员保持不变，消息和调用仍按当前的哈希环路由
+func (c *Cluster) Stop(ctx context.Context) error {
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// probe 并发探测其他节点，可达节点集合变化时重新平衡
+func (c *Cluster) probe(ctx context.Context) {
+	var wg sync.WaitGroup
+	results := make(map[string]error)
+	var resultsMu sync.Mutex
+
+	for id, address := range c.config.Nodes {
+		if id == c.config.NodeID {
+			continue
+		}
+		wg.Add(1)
+		go func(id, address string) {
+			defer wg.Done()
+			err := c.ping(ctx, id, address)
+			resultsMu.Lock()
+			results[id] = err
+			resultsMu.Unlock()
+		}(id, address)
+	}
+	wg.Wait()
+
+	now := time.Now()
+	changed := false
+	c.mu.Lock()
+	for id, err := range results {
+		node := c.nodes[id]
+		alive := err == nil
+		if alive {
+			seen := now
+			node.LastSeen = &seen
+			node.Error = ""
+		} else {
+			if !node.Alive && node.Error != err.Error() {
+				// 没有加入哈希环的节点只在原因变化时记录，例如使用了不同的数据库
+				log.Printf("Cluster node %s is unavailable: %v", id, err)
+			}
+			node.Error = err.Error()
+		}
+		if node.Alive != alive {
+			node.Alive = alive
+			changed = true
+			if alive {
+				log.Printf("Cluster node %s joined", id)
+			} else {
+				log.Printf("Cluster node %s left: %v", id, err)
+			}
+		}
+	}
+	if changed {
+		c.rebuildLocked()
+	}
+	c.mu.Unlock()
+
+	if changed {
+		c.rebalance()
+	}
+}
+
+// ping 探测节点，返回的节点ID必须与配置一致，设置了数据库标识时两个节点的标识也必须相同
+func (c *Cluster) ping(ctx context.Context, id, address string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultPingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address+PingPath, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(NodeHeader, c.config.NodeID)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data struct {
+			NodeID  string `json:"nodeId"`
+			StoreID string `json:"storeId"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("invalid ping response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ping returned status %d", resp.StatusCode)
+	}
+	if body.Data.NodeID != id {
+		return fmt.Errorf("expected node %s, got %q", id, body.Data.NodeID)
+	}
+	if c.config.StoreID != "" && body.Data.StoreID != c.config.StoreID {
+		return fmt.Errorf("node %s uses a different database (store %q, expected %q), cluster nodes must share one database", id, body.Data.StoreID, c.config.StoreID)
+	}
+	return nil
+}
+
+// rebuildLocked 用可达节点重建哈希环，调用方需持有写锁
+func (c *Cluster) rebuildLocked() {
+	var alive []string
+	for id, node := range c.nodes {
+		if node.Alive {
+			alive = append(alive, id)
+		}
+	}
+	sort.Strings(alive)
+	c.ring.Set(alive)
+}
+
+// rebalance 成员变化后钝化本节点空闲的 Actor，保存快照后按新的归属重新激活
+func (c *Cluster) rebalance() {
+	passivated := c.actorManager.PassivateAll()
+	log.Printf("Cluster membership changed, passivated %d local actors", passivated)
+}
+
+// markUnavailable 转发失败时把节点移出哈希环，等待下次探测成功后重新加入
+func (c *Cluster) markUnavailable(id string, err error) {
+	c.mu.Lock()
+	node, ok := c.nodes[id]
+	if !ok || !node.Alive || node.Self {
+		c.mu.Unlock()
+		return
+	}
+	node.Alive = false
+	node.Error = err.Error()
+	c.rebuildLocked()
+	c.mu.Unlock()
+
+	log.Printf("Cluster node %s left: %v", id, err)
+	c.rebalance()
+}
+
+// PlacementKey 返回消息的放置键：带 Thing 的消息为 Thing ID，否则为接收方 Actor ID
+func PlacementKey(msg *actor.Message) string {
+	if msg.ThingID != "" {
+		return msg.ThingID
+	}
+	return msg.To
+}
+
+// Owner 返回放置键所属的节点
+func (c *Cluster) Owner(key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ring.Owner(key)
+}
+
+// Route 实现 actor.RemoteRouter，返回处理消息的节点
+func (c *Cluster) Route(msg *actor.Message) (string, bool) {
+	owner := c.Owner(PlacementKey(msg))
+	return owner, owner == "" || owner == c.config.NodeID
+}
+
+// Forward 实现 actor.RemoteRouter，把消息转发到远程节点
+// 节点不可达时移出哈希环并按新的归属再投递一次，可能由本节点处理
+func (c *Cluster) Forward(ctx context.Context, node string, msg *actor.Message, wait bool) (*actor.Message, error) {
+	response, err := c.forward(ctx, node, msg, wait)
+	if !errors.Is(err, ErrNodeUnavailable) {
+		return response, err
+	}
+
+	c.markUnavailable(node, err)
+	router := c.actorManager.Router()
+	if wait {
+		return router.Request(ctx, msg)
+	}
+	return nil, router.Send(msg)
+}
+
+// forward 通过 HTTP 把消息投递到节点
+func (c *Cluster) forward(ctx context.Context, node string, msg *actor.Message, wait bool) (*actor.Message, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultForwardTimeout)
+		defer cancel()
+	}
+
+	var response *actor.Message
+	path := fmt.Sprintf("%s?wait=%t", MessagesPath, wait)
+	err := c.post(ctx, node, path, msg, &response, map[int]error{
+		http.StatusTooManyRequests: actor.ErrMailboxFull,
+	})
+	return response, err
+}
+
+// Invoke 实现 actor.RemoteRouter，在远程节点执行函数调用
+// 节点不可达时移出哈希环并按新的归属再调用一次，可能由本节点执行；
+// 同步调用可能包含等待步骤，不设置默认的超时时间，由调用方的 ctx 控制
+func (c *Cluster) Invoke(ctx context.Context, node string, request *actor.InvocationRequest) (*actor.InvocationReply, error) {
+	var reply actor.InvocationReply
+	// Actor 不存在和函数不存在在调用方都按 404 处理，统一对应 ErrFunctionNotFound
+	err := c.post(ctx, node, InvocationsPath, request, &reply, map[int]error{
+		http.StatusNotFound:        actor.ErrFunctionNotFound,
+		http.StatusBadRequest:      actor.ErrInvalidParams,
+		http.StatusTooManyRequests: actor.ErrInvocationLimit,
+	})
+	if err == nil {
+		return &reply, nil
+	}
+	if !errors.Is(err, ErrNodeUnavailable) {
+		return nil, err
+	}
+
+	c.markUnavailable(node, err)
+	callCtx := actor.WithCaller(actor.WithThingID(ctx, request.ThingID), request.Caller)
+	if request.Async {
+		invocation, err := c.actorManager.InvokeFunctionAsync(callCtx, request.ActorID, request.Function, request.Params)
+		if err != nil {
+			return nil, err
+		}
+		return &actor.InvocationReply{Invocation: invocation}, nil
+	}
+	result, err := c.actorManager.InvokeFunction(callCtx, request.ActorID, request.Function, request.Params)
+	if err != nil {
+		return nil, err
+	}
+	return &actor.InvocationReply{Result: result}, nil
+}
+
+// post 把请求体以 JSON 发送到节点，响应的 data 解码到 out
+// 网关超时对应 context.DeadlineExceeded，statusErrors 中的状态码对应给定的错误，连接失败为 ErrNodeUnavailable
+func (c *Cluster) post(ctx context.Context, node, path string, payload, out interface{}, statusErrors map[int]error) error {
+	c.mu.RLock()
+	target, ok := c.nodes[node]
+	c.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: unknown node %s", ErrNodeUnavailable, node)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Address+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(NodeHeader, c.config.NodeID)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("no reply from node %s: %w", node, ctx.Err())
+		}
+		return fmt.Errorf("%w: %s: %v", ErrNodeUnavailable, node, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response from node %s: %v", node, err)
+	}
+	var result struct {
+		Data  json.RawMessage `json:"data"`
+		Error string          `json:"error"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("invalid response from node %s: %v", node, err)
+	}
+
+	if resp.StatusCode == http.StatusGatewayTimeout {
+		return fmt.Errorf("node %s: %s: %w", node, result.Error, context.DeadlineExceeded)
+	}
+	if resp.StatusCode >= 300 {
+		return &remoteError{node: node, message: result.Error, cause: statusErrors[resp.StatusCode]}
+	}
+	if len(result.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return fmt.Errorf("invalid response from node %s: %v", node, err)
+	}
+	return nil
+}
+
+// remoteError 远程节点返回的错误，保留原始信息，按状态码对应的错误判断类型
+type remoteError struct {
+	node    string
+	message string
+	cause   error
+}
+
+func (e *remoteError) Error() string {
+	return fmt.Sprintf("node %s: %s", e.node, e.message)
+}
+
+func (e *remoteError) Unwrap() error {
+	return e.cause
+}
+
+// Status 返回本节点、所有节点的状态和可达节点数
+func (c *Cluster) Status() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	nodes := make([]Node, 0, len(c.nodes))
+	alive := 0
+	for _, node := range c.nodes {
+		nodes = append(nodes, *node)
+		if node.Alive {
+			alive++
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+
+	return map[string]interface{}{
+		"nodeId":  c.config.NodeID,
+		"storeId": c.config.StoreID,
+		"nodes":   nodes,
+		"alive":   alive,
+	}
+}
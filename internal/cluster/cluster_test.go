@@ -0,0 +1,244 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"uros-restron/internal/actor"
+)
+
+// fakeNode 模拟远程节点，应答探测并记录收到的转发请求
+type fakeNode struct {
+	id      string
+	storeID string
+	server  *httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+	response interface{}
+}
+
+// newFakeNode 启动模拟节点，转发请求默认应答 200 和空数据
+func newFakeNode(t *testing.T, id string) *fakeNode {
+	t.Helper()
+	node := &fakeNode{id: id, status: http.StatusOK}
+	node.server = httptest.NewServer(http.HandlerFunc(node.serve))
+	t.Cleanup(node.server.Close)
+	return node
+}
+
+func (n *fakeNode) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == PingPath {
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"nodeId": n.id, "storeId": n.storeID}})
+		return
+	}
+
+	var body json.RawMessage
+	json.NewDecoder(r.Body).Decode(&body)
+	n.mu.Lock()
+	n.requests = append(n.requests, r)
+	n.bodies = append(n.bodies, body)
+	status, response := n.status, n.response
+	n.mu.Unlock()
+
+	w.WriteHeader(status)
+	if status >= 300 {
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": response})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": response})
+}
+
+// reply 设置之后转发请求的应答
+func (n *fakeNode) reply(status int, response interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.status, n.response = status, response
+}
+
+// received 返回收到的转发请求
+func (n *fakeNode) received() ([]*http.Request, [][]byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*http.Request(nil), n.requests...), append([][]byte(nil), n.bodies...)
+}
+
+// startCluster 创建本节点 n1 和模拟节点 n2 组成的集群，探测后 n2 加入哈希环
+func startCluster(t *testing.T) (*Cluster, *fakeNode, *actor.ActorManager) {
+	t.Helper()
+	remote := newFakeNode(t, "n2")
+	actorManager := actor.NewActorManager(nil)
+	c, err := New(Config{
+		NodeID:       "n1",
+		Nodes:        map[string]string{"n1": "http://127.0.0.1:0", "n2": remote.server.URL},
+		PingInterval: time.Hour,
+	}, actorManager)
+	if err != nil {
+		t.Fatalf("failed to create cluster: %v", err)
+	}
+	actorManager.Router().SetRemote(c)
+	c.Start(context.Background())
+	t.Cleanup(func() {
+		c.Stop(context.Background())
+	})
+	return c, remote, actorManager
+}
+
+// keyOwnedBy 返回属于节点的 Thing ID
+func keyOwnedBy(t *testing.T, c *Cluster, node string) string {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("thing-%d", i)
+		if c.Owner(key) == node {
+			return key
+		}
+	}
+	t.Fatalf("no key owned by %s", node)
+	return ""
+}
+
+func TestNodeWithDifferentStoreStaysOutOfRing(t *testing.T) {
+	remote := newFakeNode(t, "n2")
+	remote.storeID = "store-b"
+	c, err := New(Config{
+		NodeID:       "n1",
+		Nodes:        map[string]string{"n1": "http://127.0.0.1:0", "n2": remote.server.URL},
+		PingInterval: time.Hour,
+		StoreID:      "store-a",
+	}, actor.NewActorManager(nil))
+	if err != nil {
+		t.Fatalf("failed to create cluster: %v", err)
+	}
+	c.Start(context.Background())
+	defer c.Stop(context.Background())
+
+	for i := 0; i < 100; i++ {
+		if owner := c.Owner(fmt.Sprintf("thing-%d", i)); owner != "n1" {
+			t.Fatalf("expected n1 to own every key, thing-%d is owned by %s", i, owner)
+		}
+	}
+	for _, node := range c.Status()["nodes"].([]Node) {
+		if node.ID == "n2" && (node.Alive || !strings.Contains(node.Error, "different database")) {
+			t.Fatalf("expected n2 to be rejected for its database, got %+v", node)
+		}
+	}
+}
+
+func TestNewRequiresSelfInNodeList(t *testing.T) {
+	if _, err := New(Config{NodeID: "n1", Nodes: map[string]string{"n2": "http://n2"}}, nil); err == nil {
+		t.Fatal("expected an error for a node missing from the list")
+	}
+	if _, err := New(Config{Nodes: map[string]string{"n1": "http://n1"}}, nil); err == nil {
+		t.Fatal("expected an error for an empty node id")
+	}
+}
+
+func TestParseNodes(t *testing.T) {
+	nodes, err := ParseNodes(" n1=http://localhost:8081/ , n2=http://localhost:8082,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 2 || nodes["n1"] != "http://localhost:8081" || nodes["n2"] != "http://localhost:8082" {
+		t.Fatalf("unexpected nodes: %v", nodes)
+	}
+	if _, err := ParseNodes("n1"); err == nil {
+		t.Fatal("expected an error for an entry without address")
+	}
+}
+
+func TestRouteFollowsPlacementKey(t *testing.T) {
+	c, _, _ := startCluster(t)
+
+	thingID := keyOwnedBy(t, c, "n2")
+	node, local := c.Route(&actor.Message{To: "actor-1", ThingID: thingID})
+	if node != "n2" || local {
+		t.Fatalf("expected message for %s to route to n2, got %s (local %t)", thingID, node, local)
+	}
+
+	localThing := keyOwnedBy(t, c, "n1")
+	if node, local := c.Route(&actor.Message{To: "actor-1", ThingID: localThing}); node != "n1" || !local {
+		t.Fatalf("expected message for %s to stay local, got %s (local %t)", localThing, node, local)
+	}
+}
+
+func TestForwardRequest(t *testing.T) {
+	c, remote, actorManager := startCluster(t)
+	remote.reply(http.StatusOK, actor.Message{ID: "reply-1", Type: actor.StatusResponse, CorrelationID: "corr-1"})
+
+	msg := &actor.Message{ID: "msg-1", Type: actor.StatusQuery, From: "a", To: "b", ThingID: keyOwnedBy(t, c, "n2"), CorrelationID: "corr-1"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	response, err := actorManager.Router().Request(ctx, msg)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if response == nil || response.ID != "reply-1" || response.CorrelationID != "corr-1" {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	requests, bodies := remote.received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 forwarded request, got %d", len(requests))
+	}
+	if requests[0].URL.Path != MessagesPath || requests[0].URL.Query().Get("wait") != "true" {
+		t.Fatalf("unexpected forward target %s", requests[0].URL)
+	}
+	if from := requests[0].Header.Get(NodeHeader); from != "n1" {
+		t.Fatalf("expected %s header n1, got %q", NodeHeader, from)
+	}
+	var forwarded actor.Message
+	if err := json.Unmarshal(bodies[0], &forwarded); err != nil || forwarded.ID != "msg-1" || forwarded.To != "b" {
+		t.Fatalf("unexpected forwarded message %s: %v", bodies[0], err)
+	}
+}
+
+func TestForwardMapsRemoteErrors(t *testing.T) {
+	c, remote, _ := startCluster(t)
+	remote.reply(http.StatusTooManyRequests, "mailbox of b is full")
+
+	_, err := c.Forward(context.Background(), "n2", &actor.Message{ID: "msg-1", To: "b"}, false)
+	if !errors.Is(err, actor.ErrMailboxFull) {
+		t.Fatalf("expected ErrMailboxFull, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "mailbox of b is full") {
+		t.Fatalf("expected the remote message in %q", err)
+	}
+
+	remote.reply(http.StatusNotFound, "function not found: nope")
+	_, err = c.Invoke(context.Background(), "n2", &actor.InvocationRequest{ActorID: "b", Function: "nope"})
+	if !errors.Is(err, actor.ErrFunctionNotFound) {
+		t.Fatalf("expected ErrFunctionNotFound, got %v", err)
+	}
+	if err.Error() != "node n2: function not found: nope" {
+		t.Fatalf("unexpected error text %q", err)
+	}
+}
+
+func TestForwardFallsBackWhenNodeUnavailable(t *testing.T) {
+	c, remote, _ := startCluster(t)
+	thingID := keyOwnedBy(t, c, "n2")
+	remote.server.Close()
+
+	// n2 移出哈希环后消息按新的归属在本节点投递，本节点没有该 Actor
+	_, err := c.Forward(context.Background(), "n2", &actor.Message{ID: "msg-1", To: "b", ThingID: thingID}, false)
+	if errors.Is(err, ErrNodeUnavailable) {
+		t.Fatalf("expected local delivery after fallback, got %v", err)
+	}
+	if !errors.Is(err, actor.ErrActorNotFound) {
+		t.Fatalf("expected ErrActorNotFound from local delivery, got %v", err)
+	}
+	if owner := c.Owner(thingID); owner != "n1" {
+		t.Fatalf("expected n1 to own %s after n2 left, got %s", thingID, owner)
+	}
+}
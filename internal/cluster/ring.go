@@ -0,0 +1,63 @@
+package cluster
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// defaultVirtualNodes 每个节点在哈希环上的虚拟节点数
+const defaultVirtualNodes = 128
+
+// HashRing 一致性哈希环，节点增减时只有相邻区间的键改变归属
+type HashRing struct {
+	virtualNodes int
+	hashes       []uint32
+	owners       map[uint32]string
+}
+
+// NewHashRing 创建哈希环，virtualNodes 不大于 0 时使用默认值
+func NewHashRing(virtualNodes int) *HashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+	return &HashRing{
+		virtualNodes: virtualNodes,
+		owners:       make(map[uint32]string),
+	}
+}
+
+// Set 用给定的节点重建哈希环
+func (r *HashRing) Set(nodes []string) {
+	r.hashes = r.hashes[:0]
+	r.owners = make(map[uint32]string, len(nodes)*r.virtualNodes)
+
+	for _, node := range nodes {
+		for i := 0; i < r.virtualNodes; i++ {
+			hash := crc32.ChecksumIEEE([]byte(node + "#" + strconv.Itoa(i)))
+			if _, taken := r.owners[hash]; taken {
+				continue
+			}
+			r.owners[hash] = node
+			r.hashes = append(r.hashes, hash)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool {
+		return r.hashes[i] < r.hashes[j]
+	})
+}
+
+// Owner 返回键所属的节点，环为空时返回空字符串
+func (r *HashRing) Owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= hash
+	})
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
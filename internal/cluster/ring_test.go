@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHashRingEmpty(t *testing.T) {
+	ring := NewHashRing(0)
+	if owner := ring.Owner("thing-1"); owner != "" {
+		t.Fatalf("expected no owner on an empty ring, got %q", owner)
+	}
+}
+
+func TestHashRingDistribution(t *testing.T) {
+	ring := NewHashRing(0)
+	nodes := []string{"n1", "n2", "n3"}
+	ring.Set(nodes)
+
+	const keys = 30000
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		counts[ring.Owner(fmt.Sprintf("thing-%d", i))]++
+	}
+	for _, node := range nodes {
+		share := float64(counts[node]) / keys
+		if share < 0.2 || share > 0.47 {
+			t.Errorf("node %s owns %.2f of the keys", node, share)
+		}
+	}
+	if len(counts) != len(nodes) {
+		t.Fatalf("keys owned by unknown nodes: %v", counts)
+	}
+}
+
+func TestHashRingStability(t *testing.T) {
+	ring := NewHashRing(0)
+	ring.Set([]string{"n1", "n2", "n3"})
+
+	const keys = 10000
+	before := make([]string, keys)
+	for i := range before {
+		before[i] = ring.Owner(fmt.Sprintf("thing-%d", i))
+	}
+
+	// 加入节点时只有移到新节点的键改变归属
+	ring.Set([]string{"n1", "n2", "n3", "n4"})
+	moved := 0
+	for i, owner := range before {
+		after := ring.Owner(fmt.Sprintf("thing-%d", i))
+		if after == owner {
+			continue
+		}
+		if after != "n4" {
+			t.Fatalf("key %d moved from %s to %s instead of the new node", i, owner, after)
+		}
+		moved++
+	}
+	if moved == 0 || moved > keys/2 {
+		t.Fatalf("unexpected number of moved keys: %d", moved)
+	}
+
+	// 移除节点时只有该节点的键改变归属
+	ring.Set([]string{"n1", "n3"})
+	for i, owner := range before {
+		after := ring.Owner(fmt.Sprintf("thing-%d", i))
+		if owner != "n2" && after != owner {
+			t.Fatalf("key %d moved from %s to %s although its node stayed", i, owner, after)
+		}
+		if after == "n2" {
+			t.Fatalf("key %d still owned by the removed node", i)
+		}
+	}
+}
@@ -2,20 +2,56 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Behaviors BehaviorsConfig
+	Actors    ActorsConfig
+	Cluster   ClusterConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Host string
+	Port            string
+	Host            string
+	ShutdownTimeout time.Duration // 收到退出信号后等待请求、连接和 Actor 完成的最长时间
+	EventLogSize    int           // 保存的最近广播事件数，供断线重连的 WebSocket 客户端补发
 }
 
 type DatabaseConfig struct {
-	DSN string
+	DSN                 string
+	OutboxRetention     time.Duration // 已发布的变更事件在发件箱中的保留时长，0 表示不清理
+	InvocationRetention time.Duration // 已结束的函数调用记录的保留时长，0 表示不清理
+	HistoryRetention    time.Duration // 事件历史的保留时长，0 表示不清理
+}
+
+type BehaviorsConfig struct {
+	Path  string
+	Watch bool // 监听行为目录变化并自动重新加载
+}
+
+type ActorsConfig struct {
+	IdleTimeout   time.Duration // 空闲超过该时长的 Actor 被钝化，0 表示不钝化
+	SnapshotEvery int           // 每追加多少个事件保存一次 Actor 快照
+
+	MailboxCapacity     int           // Actor 邮箱的默认容量
+	MailboxOverflow     string        // 邮箱已满时的默认策略：block, drop_newest, drop_oldest, reject
+	MailboxBlockTimeout time.Duration // block 策略等待空位的最长时间
+	MaxInvocations      int           // 每个 Actor 同时执行的函数调用上限
+
+	HeartbeatInterval time.Duration // 向 Actor 发送心跳的间隔
+	HeartbeatTimeout  time.Duration // 空闲的 Actor 超过该时长没有回应心跳视为无响应
+	StuckThreshold    time.Duration // 单条消息处理超过该时长视为卡住
+	MaxRestarts       int           // 不健康的 Actor 自动重启的次数上限
+}
+
+type ClusterConfig struct {
+	NodeID       string        // 本节点ID，需出现在 Nodes 中
+	Nodes        string        // 静态节点列表，例如 n1=http://localhost:8081,n2=http://localhost:8082，为空时不开启集群模式
+	PingInterval time.Duration // 探测其他节点的间隔
 }
 
 func Load() *Config {
@@ -23,9 +59,38 @@ func Load() *Config {
 		Server: ServerConfig{
 			Port: getEnv("PORT", "8080"),
 			Host: getEnv("HOST", "localhost"),
+
+			ShutdownTimeout: getDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+			EventLogSize:    getInt("EVENT_LOG_SIZE", 1000),
 		},
 		Database: DatabaseConfig{
-			DSN: getEnv("DATABASE_DSN", "things.db"),
+			DSN:                 getEnv("DATABASE_DSN", "things.db"),
+			OutboxRetention:     getDuration("OUTBOX_RETENTION", 24*time.Hour),
+			InvocationRetention: getDuration("INVOCATION_RETENTION", 7*24*time.Hour),
+			HistoryRetention:    getDuration("HISTORY_RETENTION", 7*24*time.Hour),
+		},
+		Behaviors: BehaviorsConfig{
+			Path:  getEnv("BEHAVIORS_PATH", "./behaviors"),
+			Watch: getEnv("BEHAVIORS_WATCH", "false") == "true",
+		},
+		Actors: ActorsConfig{
+			IdleTimeout:   getDuration("ACTOR_IDLE_TIMEOUT", 10*time.Minute),
+			SnapshotEvery: getInt("ACTOR_SNAPSHOT_EVERY", 100),
+
+			MailboxCapacity:     getInt("ACTOR_MAILBOX_CAPACITY", 100),
+			MailboxOverflow:     getEnv("ACTOR_MAILBOX_OVERFLOW", "block"),
+			MailboxBlockTimeout: getDuration("ACTOR_MAILBOX_BLOCK_TIMEOUT", 5*time.Second),
+			MaxInvocations:      getInt("ACTOR_MAX_INVOCATIONS", 16),
+
+			HeartbeatInterval: getDuration("ACTOR_HEARTBEAT_INTERVAL", 30*time.Second),
+			HeartbeatTimeout:  getDuration("ACTOR_HEARTBEAT_TIMEOUT", 10*time.Second),
+			StuckThreshold:    getDuration("ACTOR_STUCK_THRESHOLD", 2*time.Minute),
+			MaxRestarts:       getInt("ACTOR_MAX_RESTARTS", 3),
+		},
+		Cluster: ClusterConfig{
+			NodeID:       getEnv("CLUSTER_NODE_ID", ""),
+			Nodes:        getEnv("CLUSTER_NODES", ""),
+			PingInterval: getDuration("CLUSTER_PING_INTERVAL", 2*time.Second),
 		},
 	}
 }
@@ -36,3 +101,21 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+func getInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
@@ -12,3 +12,12 @@ func InitDB(dsn string) (*gorm.DB, error) {
 	}
 	return db, nil
 }
+
+// Close 关闭数据库连接
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
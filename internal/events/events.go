@@ -0,0 +1,155 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Type 领域事件类型，同时用作推送给 WebSocket 和 SSE 客户端的消息类型
+type Type string
+
+// Thing 事件
+const (
+	ThingCreated       Type = "thing_created"
+	ThingUpdated       Type = "thing_updated"
+	ThingDeleted       Type = "thing_deleted"
+	ThingStatusUpdated Type = "status_updated"
+)
+
+// ThingType 事件
+const (
+	ThingTypeCreated Type = "thing_type_created"
+	ThingTypeUpdated Type = "thing_type_updated"
+	ThingTypeDeleted Type = "thing_type_deleted"
+)
+
+// Relationship 事件
+const (
+	RelationshipCreated Type = "relationship_created"
+	RelationshipUpdated Type = "relationship_updated"
+	RelationshipDeleted Type = "relationship_deleted"
+)
+
+// Behavior 事件
+const (
+	BehaviorCreated Type = "behavior_created"
+	BehaviorUpdated Type = "behavior_updated"
+	BehaviorDeleted Type = "behavior_deleted"
+)
+
+// Actor 事件，存活检查和函数调用事件沿用 actor 包中的类型名
+const (
+	ActorActivated    Type = "actor_activated"
+	ActorPassivated   Type = "actor_passivated"
+	ActorStopped      Type = "actor_stopped"
+	ActorStateChanged Type = "actor_state_changed"
+)
+
+// 事件所属的聚合
+const (
+	AggregateThing        = "thing"
+	AggregateThingType    = "thing_type"
+	AggregateRelationship = "relationship"
+	AggregateBehavior     = "behavior"
+	AggregateActor        = "actor"
+	AggregateInvocation   = "invocation"
+)
+
+// Event 领域事件
+type Event struct {
+	Type      Type        `json:"type"`
+	Aggregate string      `json:"aggregate"`
+	ID        string      `json:"id"`                // 聚合ID
+	ThingID   string      `json:"thingId,omitempty"` // 事件涉及的 Thing，关系事件为源 Thing
+	Data      interface{} `json:"data"`
+	Time      time.Time   `json:"time"`
+	Sequence  uint64      `json:"sequence,omitempty"` // 发件箱序号，按提交顺序递增；未经发件箱的事件为 0
+}
+
+// Handler 事件处理函数，在发布者的 goroutine 中同步调用
+// 处理函数不能阻塞，也不能回调发布事件的服务
+type Handler func(event Event)
+
+// subscriber 事件订阅
+type subscriber struct {
+	id      uint64
+	types   map[Type]bool // 为空时接收所有事件
+	handler Handler
+}
+
+// Bus 进程内的领域事件总线，服务在变更成功后发布事件，Hub、规则引擎等消费者订阅
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	nextID      uint64
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe 订阅事件，未指定类型时接收所有事件，返回取消订阅的函数
+func (b *Bus) Subscribe(handler Handler, types ...Type) func() {
+	sub := &subscriber{handler: handler}
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, typ := range types {
+			sub.types[typ] = true
+		}
+	}
+
+	b.mu.Lock()
+	b.nextID++
+	sub.id = b.nextID
+	// 写时复制，发布时无需持有锁调用处理函数
+	subscribers := make([]*subscriber, len(b.subscribers), len(b.subscribers)+1)
+	copy(subscribers, b.subscribers)
+	b.subscribers = append(subscribers, sub)
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subscribers := make([]*subscriber, 0, len(b.subscribers))
+		for _, existing := range b.subscribers {
+			if existing.id != sub.id {
+				subscribers = append(subscribers, existing)
+			}
+		}
+		b.subscribers = subscribers
+	}
+}
+
+// Publish 按订阅顺序把事件交给订阅者，总线为 nil 时不做任何事
+// 单个处理函数 panic 只记录日志，不影响其他订阅者和发布者
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, sub := range subscribers {
+		if sub.types != nil && !sub.types[event.Type] {
+			continue
+		}
+		deliver(sub, event)
+	}
+}
+
+// deliver 调用处理函数并恢复 panic
+func deliver(sub *subscriber, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Event handler panicked on %s: %v", event.Type, r)
+		}
+	}()
+	sub.handler(event)
+}
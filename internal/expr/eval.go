@@ -0,0 +1,321 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// node 语法树节点
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+type identNode struct {
+	name string
+}
+
+type memberNode struct {
+	object node
+	key    node
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+type callNode struct {
+	name string
+	fn   builtin
+	args []node
+}
+
+// builtin 内置函数
+type builtin struct {
+	arity int // -1 表示可变参数
+	call  func(args []interface{}) (interface{}, error)
+}
+
+// builtins 表达式中可用的内置函数
+var builtins = map[string]builtin{
+	"len": {arity: 1, call: func(args []interface{}) (interface{}, error) {
+		v := reflect.ValueOf(args[0])
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			return float64(v.Len()), nil
+		case reflect.Invalid:
+			return float64(0), nil
+		}
+		return nil, fmt.Errorf("len: unsupported type %T", args[0])
+	}},
+	"exists": {arity: 1, call: func(args []interface{}) (interface{}, error) {
+		return args[0] != nil, nil
+	}},
+	"abs": {arity: 1, call: func(args []interface{}) (interface{}, error) {
+		n, ok := ToNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("abs: %v is not a number", args[0])
+		}
+		return math.Abs(n), nil
+	}},
+	"contains": {arity: 2, call: func(args []interface{}) (interface{}, error) {
+		switch container := args[0].(type) {
+		case string:
+			s, _ := args[1].(string)
+			return strings.Contains(container, s), nil
+		case []interface{}:
+			for _, item := range container {
+				if equal(item, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]interface{}:
+			key, _ := args[1].(string)
+			_, ok := container[key]
+			return ok, nil
+		}
+		return false, nil
+	}},
+}
+
+func (n *literalNode) eval(env Env) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *identNode) eval(env Env) (interface{}, error) {
+	return env[n.name], nil
+}
+
+func (n *memberNode) eval(env Env) (interface{}, error) {
+	object, err := n.object.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.key.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return member(object, key), nil
+}
+
+func (n *unaryNode) eval(env Env) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		return !Truthy(v), nil
+	case "-":
+		num, ok := ToNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate %v", v)
+		}
+		return -num, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+func (n *binaryNode) eval(env Env) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// 逻辑运算短路求值
+	switch n.op {
+	case "&&":
+		if !Truthy(left) {
+			return false, nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		return Truthy(right), nil
+	case "||":
+		if Truthy(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		return Truthy(right), nil
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		return compare(n.op, left, right), nil
+	case "+":
+		if ls, ok := left.(string); ok {
+			return ls + fmt.Sprint(right), nil
+		}
+	}
+
+	l, lok := ToNumber(left)
+	r, rok := ToNumber(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s requires numbers, got %v and %v", n.op, left, right)
+	}
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+func (n *callNode) eval(env Env) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return n.fn.call(args)
+}
+
+// member 取对象的属性或数组元素，不存在时返回 nil
+func member(object, key interface{}) interface{} {
+	switch o := object.(type) {
+	case map[string]interface{}:
+		if k, ok := key.(string); ok {
+			return o[k]
+		}
+	case []interface{}:
+		if idx, ok := ToNumber(key); ok && idx >= 0 && int(idx) < len(o) {
+			return o[int(idx)]
+		}
+	}
+	return nil
+}
+
+// Truthy 判断值的真假，nil、false、0 和空字符串为假
+func Truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	}
+	if n, ok := ToNumber(v); ok {
+		return n != 0
+	}
+	return true
+}
+
+// ToNumber 把各种数值类型转换为 float64
+func ToNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// equal 比较两个值是否相等，数值按数值比较
+func equal(a, b interface{}) bool {
+	if an, ok := ToNumber(a); ok {
+		if bn, ok := ToNumber(b); ok {
+			return an == bn
+		}
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare 比较大小，类型不匹配或有 nil 时结果为 false
+func compare(op string, a, b interface{}) bool {
+	var c int
+	an, aok := ToNumber(a)
+	bn, bok := ToNumber(b)
+	switch {
+	case aok && bok:
+		switch {
+		case an < bn:
+			c = -1
+		case an > bn:
+			c = 1
+		}
+	default:
+		as, aok := a.(string)
+		bs, bok := b.(string)
+		if !aok || !bok {
+			return false
+		}
+		c = strings.Compare(as, bs)
+	}
+
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+// Package expr 实现行为条件、变量绑定和规则使用的简单表达式语言
+//
+// 支持数字、字符串、布尔和 null 字面量，点号和下标访问（a.b、a["b"]、a[0]），
+// 算术运算（+ - * / %）、比较运算（== != < <= > >=）、
+// 逻辑运算（&& || !，以及 and or not）和内置函数 len、exists、abs、contains。
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Env 表达式求值环境，标识符从中按名称查找
+type Env map[string]interface{}
+
+// UndefinedError 表达式引用了环境中不存在的变量
+type UndefinedError struct {
+	Name string
+}
+
+// Error 实现 error 接口
+func (e *UndefinedError) Error() string {
+	return fmt.Sprintf("undefined variable %q", e.Name)
+}
+
+// Expression 已解析的表达式
+type Expression struct {
+	source string
+	root   node
+}
+
+// Parse 解析表达式
+func Parse(source string) (*Expression, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, &SyntaxError{Pos: 0, Msg: "empty expression"}
+	}
+
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// MustParse 解析表达式，失败时 panic，仅用于常量表达式
+func MustParse(source string) *Expression {
+	e, err := Parse(source)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// String 返回表达式源码
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval 在给定环境中求值
+func (e *Expression) Eval(env Env) (interface{}, error) {
+	return e.root.eval(env)
+}
+
+// EvalBool 求值并转换为布尔值
+func (e *Expression) EvalBool(env Env) (bool, error) {
+	v, err := e.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	return Truthy(v), nil
+}
+
+// Identifiers 返回表达式引用的变量路径，例如 params.air_quality
+// 下标不是字面量时路径在该处截断
+func (e *Expression) Identifiers() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	collectIdentifiers(e.root, seen, &paths)
+	return paths
+}
+
+// CheckDefined 检查表达式引用的变量都在环境中定义，值为 nil 的变量视为已定义
+// 只检查变量路径的根，属性和下标不存在时求值仍为 nil
+func (e *Expression) CheckDefined(env Env) error {
+	for _, path := range e.Identifiers() {
+		root := strings.SplitN(path, ".", 2)[0]
+		if _, ok := env[root]; !ok {
+			return &UndefinedError{Name: root}
+		}
+	}
+	return nil
+}
+
+// Eval 解析并求值表达式
+func Eval(source string, env Env) (interface{}, error) {
+	e, err := Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(env)
+}
+
+// collectIdentifiers 遍历语法树收集变量路径
+func collectIdentifiers(n node, seen map[string]bool, paths *[]string) {
+	if path, ok := identifierPath(n); ok {
+		if !seen[path] {
+			seen[path] = true
+			*paths = append(*paths, path)
+		}
+		return
+	}
+
+	switch v := n.(type) {
+	case *memberNode:
+		collectIdentifiers(v.object, seen, paths)
+		collectIdentifiers(v.key, seen, paths)
+	case *unaryNode:
+		collectIdentifiers(v.operand, seen, paths)
+	case *binaryNode:
+		collectIdentifiers(v.left, seen, paths)
+		collectIdentifiers(v.right, seen, paths)
+	case *callNode:
+		for _, arg := range v.args {
+			collectIdentifiers(arg, seen, paths)
+		}
+	}
+}
+
+// identifierPath 把标识符和字面量属性访问链转换为点号路径
+func identifierPath(n node) (string, bool) {
+	switch v := n.(type) {
+	case *identNode:
+		return v.name, true
+	case *memberNode:
+		base, ok := identifierPath(v.object)
+		if !ok {
+			return "", false
+		}
+		if lit, ok := v.key.(*literalNode); ok {
+			switch key := lit.value.(type) {
+			case string:
+				return base + "." + key, true
+			case float64:
+				return fmt.Sprintf("%s.%d", base, int(key)), true
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
@@ -0,0 +1,296 @@
+package expr
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testEnv 成员访问和下标访问用例使用的环境
+func testEnv() Env {
+	return Env{
+		"params": map[string]interface{}{
+			"air_quality": 42.0,
+			"tags":        []interface{}{"a", "b"},
+			"nested": map[string]interface{}{
+				"x": map[string]interface{}{"y": "deep"},
+			},
+		},
+		"list":  []interface{}{1.0, map[string]interface{}{"name": "n"}},
+		"count": 3,
+		"empty": nil,
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   interface{}
+	}{
+		// 运算符优先级和结合性
+		{"mul before add", "1 + 2 * 3", 7.0},
+		{"parens", "(1 + 2) * 3", 9.0},
+		{"sub left assoc", "10 - 4 - 3", 3.0},
+		{"div left assoc", "24 / 4 / 2", 3.0},
+		{"mod same level as mul", "2 * 3 % 4", 2.0},
+		{"unary minus binds tighter", "-2 * 3", -6.0},
+		{"double negation", "--2", 2.0},
+		{"compare before equality", "1 < 2 == true", true},
+		{"and before or", "true || false && false", true},
+		{"and before or reversed", "false && false || true", true},
+		{"not binds tighter than and", "!false && false", false},
+		{"keyword operators", "not true or 1 + 2 > 2 and 3 < 4", true},
+		{"arithmetic before compare", "1 + 2 > 2", true},
+		{"float division", "10 / 4", 2.5},
+		{"modulo", "7 % 3", 1.0},
+
+		// 字面量、比较和字符串
+		{"string concat", "'a' + 1", "a1"},
+		{"double quoted escape", `"say \"hi\""`, `say "hi"`},
+		{"null literal", "null", nil},
+		{"nil literal", "nil", nil},
+		{"string compare", "'abc' < 'abd'", true},
+		{"mixed compare is false", "1 < 'a'", false},
+		{"nil compare is false", "null > 1", false},
+		{"number and string not equal", "1 == '1'", false},
+		{"int env equals float literal", "count == 3", true},
+		{"nil equals null", "empty == null", true},
+
+		// 成员访问和下标访问
+		{"member", "params.air_quality", 42.0},
+		{"string index", `params["air_quality"]`, 42.0},
+		{"nested member", "params.nested.x.y", "deep"},
+		{"array index", "params.tags[1]", "b"},
+		{"computed index", "params.tags[0 + 1]", "b"},
+		{"computed key", `params["nes" + "ted"].x.y`, "deep"},
+		{"index then member", "list[1].name", "n"},
+		{"index out of range", "params.tags[2]", nil},
+		{"negative index", "params.tags[-1]", nil},
+		{"string key on array", "params.tags['a']", nil},
+		{"number key on map", "params[0]", nil},
+		{"missing member", "params.missing", nil},
+		{"member of missing", "params.missing.deeper", nil},
+		{"member of scalar", "count.x", nil},
+		{"undefined variable", "missing", nil},
+
+		// 内置函数
+		{"len array", "len(params.tags)", 2.0},
+		{"len string", "len('abcd')", 4.0},
+		{"len map", "len(params.nested)", 1.0},
+		{"len nil", "len(missing)", 0.0},
+		{"exists", "exists(params.air_quality)", true},
+		{"exists missing", "exists(params.missing)", false},
+		{"abs", "abs(-3)", 3.0},
+		{"contains string", "contains('hello', 'ell')", true},
+		{"contains array", "contains(params.tags, 'b')", true},
+		{"contains map key", "contains(params, 'tags')", true},
+		{"contains scalar", "contains(count, 3)", false},
+
+		// 短路求值时不计算右侧
+		{"and short circuit", "false && (1 - 'a')", false},
+		{"or short circuit", "true || (1 - 'a')", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Eval(tt.source, testEnv())
+			if err != nil {
+				t.Fatalf("Eval(%q) failed: %v", tt.source, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Eval(%q) = %#v, want %#v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalBool(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"1", true},
+		{"0", false},
+		{"''", false},
+		{"'x'", true},
+		{"null", false},
+		{"params", true},
+		{"params.tags", true},
+		{"count - 3", false},
+	}
+
+	for _, tt := range tests {
+		got, err := MustParse(tt.source).EvalBool(testEnv())
+		if err != nil {
+			t.Fatalf("EvalBool(%q) failed: %v", tt.source, err)
+		}
+		if got != tt.want {
+			t.Errorf("EvalBool(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestEvalTypeErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"1 - 'a'", "operator - requires numbers"},
+		{"'a' * 2", "operator * requires numbers"},
+		{"true * 2", "operator * requires numbers"},
+		{"params + 1", "operator + requires numbers"},
+		{"missing % 2", "operator % requires numbers"},
+		{"-'a'", "cannot negate"},
+		{"-null", "cannot negate"},
+		{"1 / 0", "division by zero"},
+		{"5 % (2 - 2)", "division by zero"},
+		{"abs('x')", "abs: x is not a number"},
+		{"len(5)", "len: unsupported type float64"},
+		{"len(true)", "len: unsupported type bool"},
+		// 错误沿表达式向上传递
+		{"1 + (2 - 'a')", "operator - requires numbers"},
+		{"params.tags[1 - 'a']", "operator - requires numbers"},
+		{"abs(1 / 0)", "division by zero"},
+		{"true && -'a'", "cannot negate"},
+	}
+
+	for _, tt := range tests {
+		_, err := Eval(tt.source, testEnv())
+		if err == nil {
+			t.Errorf("Eval(%q) succeeded, want error containing %q", tt.source, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Eval(%q) error = %q, want it to contain %q", tt.source, err, tt.want)
+		}
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			t.Errorf("Eval(%q) returned syntax error for a type error: %v", tt.source, err)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		source string
+		pos    int
+		msg    string
+	}{
+		{"", 0, "empty expression"},
+		{"   ", 0, "empty expression"},
+		{"1 +", 3, "unexpected end of expression"},
+		{"!", 1, "unexpected end of expression"},
+		{"(1 + 2", 6, `expected ")"`},
+		{"a[1", 3, `expected "]"`},
+		{"a.", 2, "expected property name after '.'"},
+		{"a.1", 2, "expected property name after '.'"},
+		{"'abc", 0, "unterminated string"},
+		{`"abc\"`, 0, "unterminated string"},
+		{"1.2.3", 0, `invalid number "1.2.3"`},
+		{"1 # 2", 2, `unexpected character '#'`},
+		{"a = 1", 2, `unexpected character '='`},
+		{"a & b", 2, `unexpected character '&'`},
+		{"1 2", 2, `unexpected "2"`},
+		{"a b", 2, `unexpected "b"`},
+		{")", 0, `unexpected ")"`},
+		{"1 + * 2", 4, `unexpected "*"`},
+		{"()", 1, `unexpected ")"`},
+		{"[1]", 0, `unexpected "["`},
+		{"foo(1)", 0, `unknown function "foo"`},
+		{"len(1, 2)", 0, "function len expects 1 arguments, got 2"},
+		{"exists()", 0, "function exists expects 1 arguments, got 0"},
+		{"contains('a')", 0, "function contains expects 2 arguments, got 1"},
+		{"len(1,)", 6, `unexpected ")"`},
+		{"len(1", 5, `expected ")"`},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.source)
+		if err == nil {
+			t.Errorf("Parse(%q) = %q, want syntax error", tt.source, e)
+			continue
+		}
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) error %v is not a *SyntaxError", tt.source, err)
+			continue
+		}
+		if syntaxErr.Pos != tt.pos || syntaxErr.Msg != tt.msg {
+			t.Errorf("Parse(%q) error = (%d, %q), want (%d, %q)", tt.source, syntaxErr.Pos, syntaxErr.Msg, tt.pos, tt.msg)
+		}
+	}
+}
+
+func TestMustParsePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustParse did not panic on malformed input")
+		}
+	}()
+	MustParse("1 +")
+}
+
+func TestIdentifiers(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"1 + 2", nil},
+		{"a", []string{"a"}},
+		{"params.air_quality > threshold", []string{"params.air_quality", "threshold"}},
+		{`params["air_quality"] > 1`, []string{"params.air_quality"}},
+		{"params.list[0] == 'a'", []string{"params.list.0"}},
+		{"a.b + a.b + a.c", []string{"a.b", "a.c"}},
+		// 下标不是字面量时路径在该处截断，下标中的变量单独收集
+		{"state.items[index].name", []string{"state.items", "index"}},
+		{"len(items) > 0 && !exists(state.power)", []string{"items", "state.power"}},
+	}
+
+	for _, tt := range tests {
+		got := MustParse(tt.source).Identifiers()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Identifiers(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDefined(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		env       Env
+		undefined string
+	}{
+		{"literals only", "true && 1 < 2", Env{}, ""},
+		{"defined root", "state.power == 'on'", Env{"state": map[string]interface{}{}}, ""},
+		{"missing property is defined", "state.missing.deeper", Env{"state": map[string]interface{}{}}, ""},
+		{"nil value is defined", "params.x > 1", Env{"params": nil}, ""},
+		{"undefined root", "state.power == 'on'", Env{"params": nil}, "state"},
+		{"second operand undefined", "a + b", Env{"a": 1}, "b"},
+		{"function argument undefined", "len(items) > 0", Env{}, "items"},
+		{"computed index undefined", "x[y]", Env{"x": []interface{}{}}, "y"},
+		{"short circuit still checked", "false && missing", Env{}, "missing"},
+		{"first undefined reported", "a || b", Env{}, "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := MustParse(tt.source).CheckDefined(tt.env)
+			if tt.undefined == "" {
+				if err != nil {
+					t.Fatalf("CheckDefined(%q) = %v, want nil", tt.source, err)
+				}
+				return
+			}
+			var undefinedErr *UndefinedError
+			if !errors.As(err, &undefinedErr) {
+				t.Fatalf("CheckDefined(%q) = %v, want *UndefinedError", tt.source, err)
+			}
+			if undefinedErr.Name != tt.undefined {
+				t.Fatalf("CheckDefined(%q) reported %q, want %q", tt.source, undefinedErr.Name, tt.undefined)
+			}
+		})
+	}
+}
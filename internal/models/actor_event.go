@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ActorEvent Actor 事件日志中的一条事件，由函数步骤发出，按序号依次应用到 Actor 状态
+type ActorEvent struct {
+	ID           string                 `json:"id" gorm:"primaryKey"`
+	ActorID      string                 `json:"actorId" gorm:"not null;uniqueIndex:idx_actor_events_sequence"`
+	Sequence     int64                  `json:"sequence" gorm:"not null;uniqueIndex:idx_actor_events_sequence"`
+	Type         string                 `json:"type" gorm:"index"`
+	ThingID      string                 `json:"thingId,omitempty"`
+	InvocationID string                 `json:"invocationId,omitempty"`
+	Data         map[string]interface{} `json:"data" gorm:"-"`
+	DataJSON     string                 `json:"-" gorm:"column:data;type:text"`
+	CreatedAt    time.Time              `json:"createdAt"`
+}
+
+// BeforeSave GORM hook for serializing data before save
+func (e *ActorEvent) BeforeSave(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if e.Data == nil {
+		return nil
+	}
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	e.DataJSON = string(data)
+	return nil
+}
+
+// AfterFind GORM hook for deserializing data after retrieval
+func (e *ActorEvent) AfterFind(tx *gorm.DB) error {
+	if e.DataJSON == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(e.DataJSON), &e.Data)
+}
+
+// ErrEventSequenceTaken 同一 Actor 的序号已被写入，通常是另一个节点上的同一 Actor 先写入了事件
+var ErrEventSequenceTaken = errors.New("actor event sequence is taken")
+
+// ActorEventService 提供 Actor 事件日志的存储和查询
+type ActorEventService struct {
+	db *gorm.DB
+}
+
+// NewActorEventService 创建 Actor 事件服务
+func NewActorEventService(db *gorm.DB) *ActorEventService {
+	return &ActorEventService{db: db}
+}
+
+// AppendEvent 追加事件，同一 Actor 的序号重复时返回 ErrEventSequenceTaken
+func (s *ActorEventService) AppendEvent(event *ActorEvent) error {
+	err := s.db.Create(event).Error
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return fmt.Errorf("%w: %s #%d", ErrEventSequenceTaken, event.ActorID, event.Sequence)
+	}
+	return err
+}
+
+// EventsAfter 按序号顺序获取 Actor 在 sequence 之后的全部事件，用于恢复状态
+func (s *ActorEventService) EventsAfter(actorID string, sequence int64) ([]ActorEvent, error) {
+	var events []ActorEvent
+	if err := s.db.Where("actor_id = ? AND sequence > ?", actorID, sequence).Order("sequence").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ListEvents 按序号倒序查询 Actor 的事件，since 大于 0 时只返回之后的事件，返回事件和总数
+func (s *ActorEventService) ListEvents(actorID, eventType string, since int64, limit, offset int) ([]ActorEvent, int64, error) {
+	query := s.db.Model(&ActorEvent{}).Where("actor_id = ?", actorID)
+	if eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if since > 0 {
+		query = query.Where("sequence > ?", since)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []ActorEvent
+	if err := query.Order("sequence DESC").Limit(limit).Offset(offset).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
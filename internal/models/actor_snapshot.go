@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ActorSnapshot Actor 的状态快照，定期以及钝化或停止时保存，再次激活时恢复
+// State 为应用到 Sequence 号事件为止的状态，恢复时再重放之后的事件
+type ActorSnapshot struct {
+	ActorID           string                 `json:"actorId" gorm:"primaryKey"`
+	BehaviorID        string                 `json:"behaviorId" gorm:"index"`
+	Sequence          int64                  `json:"sequence"`
+	State             map[string]interface{} `json:"state" gorm:"-"`
+	StateJSON         string                 `json:"-" gorm:"column:state;type:text"`
+	Activations       int64                  `json:"activations"`       // 累计激活次数
+	MessagesProcessed int64                  `json:"messagesProcessed"` // 累计处理的消息数，不含心跳
+	LastActive        time.Time              `json:"lastActive"`
+	PassivatedAt      *time.Time             `json:"passivatedAt,omitempty"`
+	UpdatedAt         time.Time              `json:"updatedAt"`
+}
+
+// BeforeSave GORM hook for serializing data before save
+func (s *ActorSnapshot) BeforeSave(tx *gorm.DB) error {
+	if s.State == nil {
+		return nil
+	}
+	data, err := json.Marshal(s.State)
+	if err != nil {
+		return err
+	}
+	s.StateJSON = string(data)
+	return nil
+}
+
+// AfterFind GORM hook for deserializing data after retrieval
+func (s *ActorSnapshot) AfterFind(tx *gorm.DB) error {
+	if s.StateJSON == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s.StateJSON), &s.State)
+}
+
+// ActorSnapshotService 提供 Actor 快照的存储和查询
+type ActorSnapshotService struct {
+	db *gorm.DB
+}
+
+// NewActorSnapshotService 创建 Actor 快照服务
+func NewActorSnapshotService(db *gorm.DB) *ActorSnapshotService {
+	return &ActorSnapshotService{db: db}
+}
+
+// SaveSnapshot 保存快照，已存在时覆盖
+func (s *ActorSnapshotService) SaveSnapshot(snapshot *ActorSnapshot) error {
+	return s.db.Save(snapshot).Error
+}
+
+// GetSnapshot 获取 Actor 的快照，没有快照时返回 nil
+func (s *ActorSnapshotService) GetSnapshot(actorID string) (*ActorSnapshot, error) {
+	var snapshot ActorSnapshot
+	if err := s.db.First(&snapshot, "actor_id = ?", actorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// ListSnapshots 获取所有快照
+func (s *ActorSnapshotService) ListSnapshots() ([]ActorSnapshot, error) {
+	var snapshots []ActorSnapshot
+	if err := s.db.Order("actor_id").Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// DeleteSnapshot 删除 Actor 的快照
+func (s *ActorSnapshotService) DeleteSnapshot(actorID string) error {
+	return s.db.Delete(&ActorSnapshot{}, "actor_id = ?", actorID).Error
+}
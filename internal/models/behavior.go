@@ -1,9 +1,16 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
 	"time"
 
+	"uros-restron/internal/events"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -28,10 +35,41 @@ type Behavior struct {
 	Parameters     map[string]interface{} `json:"parameters" gorm:"-"` // 行为参数
 	ParametersJSON string                 `json:"-" gorm:"column:parameters;type:text"`
 
+	// 邮箱设置 - 未设置的字段使用全局默认值
+	Mailbox MailboxSettings `json:"mailbox,omitempty" gorm:"embedded;embeddedPrefix:mailbox_"`
+
+	// 版本与来源 - 由文件加载器维护
+	Version  int    `json:"version"`          // 每次内容变化时递增
+	Source   string `json:"source,omitempty"` // 定义文件路径，手动创建的行为为空
+	Checksum string `json:"-"`                // 内容摘要，用于判断是否需要升级版本
+
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// BehaviorLoadError 记录单个行为文件的加载错误
+type BehaviorLoadError struct {
+	File     string    `json:"file"`
+	Category string    `json:"category"`
+	Error    string    `json:"error"`
+	Time     time.Time `json:"time"`
+}
+
+// MailboxSettings 行为对应 Actor 的邮箱容量和溢出策略
+// Overflow 支持 block、drop_newest、drop_oldest 和 reject，BlockTimeoutMs 仅用于 block
+// MaxInvocations 限制同时执行的函数调用数，调用不经过邮箱
+type MailboxSettings struct {
+	Capacity       int    `json:"capacity,omitempty"`
+	Overflow       string `json:"overflow,omitempty"`
+	BlockTimeoutMs int    `json:"block_timeout_ms,omitempty"`
+	MaxInvocations int    `json:"max_invocations,omitempty"`
+}
+
+// IsZero 判断是否未设置任何字段
+func (s MailboxSettings) IsZero() bool {
+	return s == MailboxSettings{}
+}
+
 // Function 定义行为中的函数
 type Function struct {
 	Name        string                 `json:"name"`
@@ -39,35 +77,162 @@ type Function struct {
 	InputParams map[string]Parameter   `json:"input_params"`
 	OutputParams map[string]Parameter `json:"output_params"`
 	Implementation FunctionImplementation `json:"implementation"`
+
+	// Subscribe 触发该函数的主题，主题上发布的消息载荷作为函数参数
+	Subscribe []string `json:"subscribe,omitempty"`
 }
 
 // Parameter 定义函数参数
+// 类型支持 string、number、integer、boolean、object 和 array，
+// array 通过 Items 描述元素，object 通过 Properties 描述属性，均可嵌套
 type Parameter struct {
 	Type        string      `json:"type"`
 	Description string      `json:"description"`
 	Required    bool        `json:"required,omitempty"`
 	Default     interface{} `json:"default,omitempty"`
+	Nullable    bool        `json:"nullable,omitempty"`
 	Min         *float64    `json:"min,omitempty"`
 	Max         *float64    `json:"max,omitempty"`
 	Enum        []string    `json:"enum,omitempty"`
+
+	// 字符串约束
+	Pattern   string `json:"pattern,omitempty"` // 正则表达式
+	Format    string `json:"format,omitempty"`  // date-time（别名 ISO8601）, date, uuid, email, uri
+	MinLength *int   `json:"min_length,omitempty"`
+	MaxLength *int   `json:"max_length,omitempty"`
+
+	// 数组约束
+	Items    *Parameter `json:"items,omitempty"`
+	MinItems *int       `json:"min_items,omitempty"`
+	MaxItems *int       `json:"max_items,omitempty"`
+
+	// 对象约束
+	Properties           map[string]Parameter `json:"properties,omitempty"`
+	AdditionalProperties *bool                `json:"additional_properties,omitempty"` // 为 false 时不允许未声明的属性
+}
+
+// UnmarshalJSON 支持用类型名简写参数，例如 "items": "string"
+func (p *Parameter) UnmarshalJSON(data []byte) error {
+	var shorthand string
+	if err := json.Unmarshal(data, &shorthand); err == nil {
+		*p = Parameter{Type: shorthand}
+		return nil
+	}
+
+	type parameterAlias Parameter
+	var alias parameterAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	*p = Parameter(alias)
+	return nil
 }
 
 // FunctionImplementation 定义函数实现
 type FunctionImplementation struct {
 	Steps []ImplementationStep `json:"steps"`
+
+	// Outputs 输出参数名到表达式的映射，通常引用步骤输出的变量
+	// 设置后函数只返回这里声明的输出，否则合并所有未映射步骤的输出
+	Outputs map[string]string `json:"outputs,omitempty"`
 }
 
+// 实现步骤类型
+const (
+	StepTypeAction   = "action"   // 执行动作（默认）
+	StepTypeIf       = "if"       // 按 Condition 执行 Then 或 Else
+	StepTypeLoop     = "loop"     // 重复执行 Steps，直到 Until 为真或达到 MaxIterations
+	StepTypeParallel = "parallel" // 并发执行 Branches，全部完成后按分支顺序合并结果
+	StepTypeWait     = "wait"     // 等待 DurationMs，或轮询直到 Until 为真
+)
+
 // ImplementationStep 定义实现步骤
+// 除 if 外，Condition 为假时跳过该步骤；嵌套步骤的 Step 编号可以省略
 type ImplementationStep struct {
 	Step        int    `json:"step"`
-	Action      string `json:"action"`
+	Type        string `json:"type,omitempty"`
+	Action      string `json:"action,omitempty"`
 	Description string `json:"description"`
 	Condition   string `json:"condition,omitempty"`
+
+	// 动作的输入绑定和输出映射，值均为表达式
+	// Inputs 为动作参数名到表达式的映射，可以引用输入参数、变量和 thing 状态；未设置时使用函数的输入参数
+	// Outputs 为变量名到表达式的映射，表达式中可以直接引用动作的输出字段；设置后输出不再合并到函数结果
+	Inputs  map[string]string `json:"inputs,omitempty"`
+	Outputs map[string]string `json:"outputs,omitempty"`
+
+	// if
+	Then []ImplementationStep `json:"then,omitempty"`
+	Else []ImplementationStep `json:"else,omitempty"`
+
+	// loop
+	Steps         []ImplementationStep `json:"steps,omitempty"`
+	Until         string               `json:"until,omitempty"` // loop 和 wait 的结束条件
+	MaxIterations int                  `json:"max_iterations,omitempty"`
+	IntervalMs    int                  `json:"interval_ms,omitempty"` // loop 迭代间隔或 wait 轮询间隔
+
+	// parallel
+	Branches [][]ImplementationStep `json:"branches,omitempty"`
+
+	// wait
+	DurationMs int `json:"duration_ms,omitempty"`
+
+	// 消息动作 send_message、call_actor、publish，动作参数作为函数参数或消息载荷
+	Target   *MessageTarget `json:"target,omitempty"`   // send_message 和 call_actor 的接收方
+	Function string         `json:"function,omitempty"` // 在接收方上调用的函数
+	Topic    string         `json:"topic,omitempty"`    // publish 的主题
+
+	// emit_event：事件类型，动作参数作为事件数据合并到 Actor 状态
+	Event string `json:"event,omitempty"`
+
+	// 错误处理
+	TimeoutMs  int                  `json:"timeout_ms,omitempty"`
+	OnError    []ImplementationStep `json:"on_error,omitempty"`   // 步骤失败时执行，成功后视为错误已处理
+	Compensate []ImplementationStep `json:"compensate,omitempty"` // 后续步骤失败导致函数失败时按逆序执行
+}
+
+// 消息动作
+const (
+	ActionSendMessage = "send_message" // 调用接收方的函数，不等待结果
+	ActionCallActor   = "call_actor"   // 调用接收方的函数并等待结果
+	ActionPublish     = "publish"      // 向主题发布消息，由订阅该主题的函数处理
+)
+
+// ActionEmitEvent 向 Actor 的事件日志追加事件并更新 Actor 状态
+const ActionEmitEvent = "emit_event"
+
+// 关系方向
+const (
+	DirectionOutgoing = "outgoing" // 当前 Thing 为关系的源
+	DirectionIncoming = "incoming" // 当前 Thing 为关系的目标
+	DirectionBoth     = "both"
+)
+
+// MessageTarget 消息接收方，Actor、Thing 和关系三选一
+// 按 Thing 或关系解析时，消息发给 Thing 所关联行为的 Actor，并以该 Thing 作为执行对象
+type MessageTarget struct {
+	Actor        string `json:"actor,omitempty"`
+	Thing        string `json:"thing,omitempty"`
+	Relationship string `json:"relationship,omitempty"` // 当前 Thing 的关系类型，例如 contains
+	Direction    string `json:"direction,omitempty"`    // 默认 outgoing
+}
+
+// StepType 返回步骤类型，未指定时为 action
+func (s ImplementationStep) StepType() string {
+	if s.Type == "" {
+		return StepTypeAction
+	}
+	return s.Type
 }
 
 // BeforeCreate GORM hook for serializing data before creation
 func (b *Behavior) BeforeCreate(tx *gorm.DB) error {
-	b.ID = uuid.New().String()
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+	if b.Version == 0 {
+		b.Version = 1
+	}
 	b.CreatedAt = time.Now()
 	b.UpdatedAt = time.Now()
 	return b.serializeData()
@@ -118,19 +283,105 @@ func (b *Behavior) deserializeData() error {
 	return nil
 }
 
+// computeChecksum 计算行为内容摘要，版本、来源和时间戳不参与计算
+func (b *Behavior) computeChecksum() (string, error) {
+	// 未设置邮箱时不参与计算，保持已有行为的摘要不变
+	var mailbox *MailboxSettings
+	if !b.Mailbox.IsZero() {
+		mailbox = &b.Mailbox
+	}
+	data, err := json.Marshal(struct {
+		Name        string                 `json:"name"`
+		Type        BehaviorType           `json:"type"`
+		Description string                 `json:"description"`
+		Category    string                 `json:"category"`
+		Functions   map[string]Function    `json:"functions"`
+		Parameters  map[string]interface{} `json:"parameters"`
+		Mailbox     *MailboxSettings       `json:"mailbox,omitempty"`
+	}{b.Name, b.Type, b.Description, b.Category, b.Functions, b.Parameters, mailbox})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DefaultBehaviorsPath 预定义行为的默认目录
+const DefaultBehaviorsPath = "./behaviors"
+
 // BehaviorService 提供行为相关的业务逻辑
 type BehaviorService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	behaviorsPath string
+	validator     *BehaviorValidator
+	bus           *events.Bus // 变更事件总线，未设置时不发布事件
+
+	loadErrorsMu sync.RWMutex
+	loadErrors   map[string]BehaviorLoadError // 按文件路径记录最近一次加载错误
 }
 
 // NewBehaviorService 创建新的 BehaviorService
 func NewBehaviorService(db *gorm.DB) *BehaviorService {
-	return &BehaviorService{db: db}
+	return &BehaviorService{
+		db:            db,
+		behaviorsPath: DefaultBehaviorsPath,
+		loadErrors:    make(map[string]BehaviorLoadError),
+	}
+}
+
+// SetBehaviorsPath 设置预定义行为目录
+func (s *BehaviorService) SetBehaviorsPath(path string) {
+	s.behaviorsPath = path
+}
+
+// SetValidator 设置行为校验器
+func (s *BehaviorService) SetValidator(validator *BehaviorValidator) {
+	s.validator = validator
+}
+
+// SetEventBus 设置变更事件总线，创建、更新和删除成功后发布行为事件
+func (s *BehaviorService) SetEventBus(bus *events.Bus) {
+	s.bus = bus
+}
+
+// publishBehavior 发布行为事件
+func (s *BehaviorService) publishBehavior(eventType events.Type, id string, data interface{}) {
+	s.bus.Publish(events.Event{Type: eventType, Aggregate: events.AggregateBehavior, ID: id, Data: data})
+}
+
+// ValidateBehavior 校验行为定义，未设置校验器时只做基本检查
+func (s *BehaviorService) ValidateBehavior(behavior *Behavior) *ValidationResult {
+	validator := s.validator
+	if validator == nil {
+		validator = NewBehaviorValidator(nil)
+	}
+	return validator.Validate(behavior)
+}
+
+// LoadBehaviorFile 从文件加载并校验行为，校验错误作为加载错误返回
+func (s *BehaviorService) LoadBehaviorFile(file string) (Behavior, error) {
+	behavior, err := LoadBehaviorFile(file)
+	if err != nil {
+		return behavior, err
+	}
+	if result := s.ValidateBehavior(&behavior); !result.Valid {
+		return behavior, fmt.Errorf("validation failed: %s", result.Error())
+	}
+	return behavior, nil
+}
+
+// BehaviorsPath 返回预定义行为目录
+func (s *BehaviorService) BehaviorsPath() string {
+	return s.behaviorsPath
 }
 
 // CreateBehavior 创建新的行为
 func (s *BehaviorService) CreateBehavior(behavior *Behavior) error {
-	return s.db.Create(behavior).Error
+	if err := s.db.Create(behavior).Error; err != nil {
+		return err
+	}
+	s.publishBehavior(events.BehaviorCreated, behavior.ID, behavior)
+	return nil
 }
 
 // GetBehavior 根据ID获取行为
@@ -157,12 +408,49 @@ func (s *BehaviorService) ListBehaviors(behaviorType, category string, limit, of
 
 // UpdateBehavior 更新行为
 func (s *BehaviorService) UpdateBehavior(id string, updates map[string]interface{}) error {
-	return s.db.Model(&Behavior{}).Where("id = ?", id).Updates(updates).Error
+	result := s.db.Model(&Behavior{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 && s.bus != nil {
+		if behavior, err := s.GetBehavior(id); err == nil {
+			s.publishBehavior(events.BehaviorUpdated, id, behavior)
+		}
+	}
+	return nil
+}
+
+// ReplaceBehavior 用完整定义替换已有行为并升级版本
+func (s *BehaviorService) ReplaceBehavior(behavior *Behavior) error {
+	var existing Behavior
+	if err := s.db.First(&existing, "id = ?", behavior.ID).Error; err != nil {
+		return err
+	}
+
+	checksum, err := behavior.computeChecksum()
+	if err != nil {
+		return err
+	}
+	behavior.Checksum = checksum
+	behavior.Version = existing.Version + 1
+	behavior.CreatedAt = existing.CreatedAt
+	if err := s.db.Save(behavior).Error; err != nil {
+		return err
+	}
+	s.publishBehavior(events.BehaviorUpdated, behavior.ID, behavior)
+	return nil
 }
 
 // DeleteBehavior 删除行为
 func (s *BehaviorService) DeleteBehavior(id string) error {
-	return s.db.Delete(&Behavior{}, "id = ?", id).Error
+	result := s.db.Delete(&Behavior{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		s.publishBehavior(events.BehaviorDeleted, id, map[string]interface{}{"id": id})
+	}
+	return nil
 }
 
 // GetBehaviorsByCategory 根据分类获取行为
@@ -173,11 +461,11 @@ func (s *BehaviorService) GetBehaviorsByCategory(category string) ([]Behavior, e
 
 // GetPredefinedBehaviors 获取预定义行为
 func (s *BehaviorService) GetPredefinedBehaviors() []Behavior {
-	if behaviors, err := LoadBehaviorsFromPath("./behaviors"); err != nil {
+	behaviors, _, err := LoadBehaviorsFromPathWithErrors(s.behaviorsPath)
+	if err != nil {
 		return []Behavior{}
-	} else {
-		return behaviors
 	}
+	return behaviors
 }
 
 // GetAllBehaviors 获取所有行为
@@ -187,14 +475,123 @@ func (s *BehaviorService) GetAllBehaviors() ([]Behavior, error) {
 }
 
 // SeedPredefinedBehaviors 填充预定义行为到数据库
-func (s *BehaviorService) SeedPredefinedBehaviors() error {
-	for _, behavior := range s.GetPredefinedBehaviors() {
-		var existing Behavior
-		if err := s.db.Where("id = ?", behavior.ID).First(&existing).Error; err == gorm.ErrRecordNotFound {
-			if err := s.db.Create(&behavior).Error; err != nil {
-				return err
-			}
+// 已存在的行为在文件内容变化时会被更新并升级版本，单个文件的错误不会中断整体加载
+// 返回新增或内容发生变化的行为ID
+func (s *BehaviorService) SeedPredefinedBehaviors() ([]string, error) {
+	behaviors, loadErrors, err := LoadBehaviorsFromPathWithErrors(s.behaviorsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var changedIDs []string
+
+	for i := range behaviors {
+		if result := s.ValidateBehavior(&behaviors[i]); !result.Valid {
+			loadErrors = append(loadErrors, NewBehaviorLoadError(behaviors[i].Source, fmt.Errorf("validation failed: %s", result.Error())))
+			continue
+		}
+		changed, err := s.UpsertBehavior(&behaviors[i])
+		if err != nil {
+			return changedIDs, err
+		}
+		if changed {
+			changedIDs = append(changedIDs, behaviors[i].ID)
 		}
 	}
-	return nil
+	s.ResetLoadErrors(loadErrors)
+	return changedIDs, nil
+}
+
+// UpsertBehavior 插入或更新行为，内容未变化时不做任何修改
+// 返回值表示数据库中的行为是否发生了变化
+func (s *BehaviorService) UpsertBehavior(behavior *Behavior) (bool, error) {
+	checksum, err := behavior.computeChecksum()
+	if err != nil {
+		return false, err
+	}
+	behavior.Checksum = checksum
+
+	var existing Behavior
+	err = s.db.Where("id = ?", behavior.ID).First(&existing).Error
+	if err == gorm.ErrRecordNotFound {
+		behavior.Version = 1
+		if err := s.db.Create(behavior).Error; err != nil {
+			return false, err
+		}
+		s.publishBehavior(events.BehaviorCreated, behavior.ID, behavior)
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if existing.Checksum == checksum && existing.Source == behavior.Source {
+		*behavior = existing
+		return false, nil
+	}
+
+	behavior.Version = existing.Version + 1
+	behavior.CreatedAt = existing.CreatedAt
+	if err := s.db.Save(behavior).Error; err != nil {
+		return false, err
+	}
+	s.publishBehavior(events.BehaviorUpdated, behavior.ID, behavior)
+	return true, nil
+}
+
+// DeleteBehaviorsBySource 删除来自指定定义文件的行为，返回被删除的行为ID
+func (s *BehaviorService) DeleteBehaviorsBySource(source string) ([]string, error) {
+	var ids []string
+	if err := s.db.Model(&Behavior{}).Where("source = ?", source).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := s.db.Delete(&Behavior{}, "id IN ?", ids).Error; err != nil {
+		return ids, err
+	}
+	for _, id := range ids {
+		s.publishBehavior(events.BehaviorDeleted, id, map[string]interface{}{"id": id})
+	}
+	return ids, nil
+}
+
+// RecordLoadError 记录行为文件的加载错误
+func (s *BehaviorService) RecordLoadError(loadErr BehaviorLoadError) {
+	s.loadErrorsMu.Lock()
+	defer s.loadErrorsMu.Unlock()
+	s.loadErrors[loadErr.File] = loadErr
+}
+
+// ClearLoadError 清除行为文件的加载错误
+func (s *BehaviorService) ClearLoadError(file string) {
+	s.loadErrorsMu.Lock()
+	defer s.loadErrorsMu.Unlock()
+	delete(s.loadErrors, file)
+}
+
+// ResetLoadErrors 用一次完整扫描的结果替换所有加载错误
+func (s *BehaviorService) ResetLoadErrors(loadErrors []BehaviorLoadError) {
+	s.loadErrorsMu.Lock()
+	defer s.loadErrorsMu.Unlock()
+	s.loadErrors = make(map[string]BehaviorLoadError, len(loadErrors))
+	for _, loadErr := range loadErrors {
+		s.loadErrors[loadErr.File] = loadErr
+	}
+}
+
+// GetLoadErrors 获取当前所有行为文件的加载错误
+func (s *BehaviorService) GetLoadErrors() []BehaviorLoadError {
+	s.loadErrorsMu.RLock()
+	defer s.loadErrorsMu.RUnlock()
+
+	loadErrors := make([]BehaviorLoadError, 0, len(s.loadErrors))
+	for _, loadErr := range s.loadErrors {
+		loadErrors = append(loadErrors, loadErr)
+	}
+	sort.Slice(loadErrors, func(i, j int) bool {
+		return loadErrors[i].File < loadErrors[j].File
+	})
+	return loadErrors
 }
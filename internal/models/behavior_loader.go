@@ -6,28 +6,71 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // LoadBehaviorsFromPath 从指定路径加载所有行为定义
 func LoadBehaviorsFromPath(behaviorsPath string) ([]Behavior, error) {
+	behaviors, loadErrors, err := LoadBehaviorsFromPathWithErrors(behaviorsPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(loadErrors) > 0 {
+		return nil, fmt.Errorf("failed to load behavior from file %s: %s", loadErrors[0].File, loadErrors[0].Error)
+	}
+	return behaviors, nil
+}
+
+// LoadBehaviorsFromPathWithErrors 从指定路径加载所有行为定义
+// 单个文件加载失败时记录错误并继续加载其余文件，只有目录无法扫描时才返回 error
+func LoadBehaviorsFromPathWithErrors(behaviorsPath string) ([]Behavior, []BehaviorLoadError, error) {
 	var allBehaviors []Behavior
+	var loadErrors []BehaviorLoadError
 
 	// 扫描行为目录
 	categories, err := scanCategories(behaviorsPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan categories: %v", err)
+		return nil, nil, fmt.Errorf("failed to scan categories: %v", err)
 	}
 
 	// 加载每个分类的行为
 	for _, category := range categories {
-		behaviors, err := loadBehaviorsFromCategory(behaviorsPath, category)
+		files, err := listBehaviorFiles(filepath.Join(behaviorsPath, category))
 		if err != nil {
-			return nil, fmt.Errorf("failed to load behaviors from category %s: %v", category, err)
+			return nil, nil, fmt.Errorf("failed to load behaviors from category %s: %v", category, err)
+		}
+
+		for _, file := range files {
+			behavior, err := LoadBehaviorFile(file)
+			if err != nil {
+				loadErrors = append(loadErrors, NewBehaviorLoadError(file, err))
+				continue
+			}
+			allBehaviors = append(allBehaviors, behavior)
 		}
-		allBehaviors = append(allBehaviors, behaviors...)
 	}
 
-	return allBehaviors, nil
+	return allBehaviors, loadErrors, nil
+}
+
+// NewBehaviorLoadError 创建行为文件加载错误
+func NewBehaviorLoadError(file string, err error) BehaviorLoadError {
+	return BehaviorLoadError{
+		File:     file,
+		Category: filepath.Base(filepath.Dir(file)),
+		Error:    err.Error(),
+		Time:     time.Now(),
+	}
+}
+
+// IsBehaviorFile 检查文件是否为行为定义文件（JSON 或 YAML）
+func IsBehaviorFile(name string) bool {
+	return strings.HasSuffix(name, ".json") || isYAMLFile(name)
+}
+
+// isYAMLFile 检查文件是否为 YAML 文件
+func isYAMLFile(name string) bool {
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")
 }
 
 // scanCategories 扫描行为分类目录
@@ -48,27 +91,57 @@ func scanCategories(behaviorsPath string) ([]string, error) {
 	return categories, nil
 }
 
-// loadBehaviorsFromCategory 从指定分类加载行为
-func loadBehaviorsFromCategory(behaviorsPath, category string) ([]Behavior, error) {
-	var behaviors []Behavior
+// listBehaviorFiles 列出分类目录下的所有行为定义文件
+func listBehaviorFiles(categoryPath string) ([]string, error) {
+	var files []string
 
-	categoryPath := filepath.Join(behaviorsPath, category)
 	entries, err := ioutil.ReadDir(categoryPath)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
-			behavior, err := loadBehaviorFromFile(filepath.Join(categoryPath, entry.Name()))
-			if err != nil {
-				return nil, fmt.Errorf("failed to load behavior from file %s: %v", entry.Name(), err)
-			}
-			behaviors = append(behaviors, behavior)
+		if !entry.IsDir() && IsBehaviorFile(entry.Name()) {
+			files = append(files, filepath.Join(categoryPath, entry.Name()))
 		}
 	}
 
-	return behaviors, nil
+	return files, nil
+}
+
+// LoadBehaviorFile 从文件加载并校验单个行为
+// 分类为空时取所在目录名，Source 记录为文件路径
+func LoadBehaviorFile(filePath string) (Behavior, error) {
+	behavior, err := loadBehaviorFromFile(filePath)
+	if err != nil {
+		return behavior, err
+	}
+
+	category := filepath.Base(filepath.Dir(filePath))
+	if behavior.Category == "" {
+		behavior.Category = category
+	}
+	behavior.Source = filePath
+
+	if err := validateLoadedBehavior(&behavior, category); err != nil {
+		return behavior, err
+	}
+
+	return behavior, nil
+}
+
+// validateLoadedBehavior 校验从文件加载的行为
+func validateLoadedBehavior(behavior *Behavior, category string) error {
+	if behavior.ID == "" {
+		return fmt.Errorf("behavior id is required")
+	}
+	if behavior.Name == "" {
+		return fmt.Errorf("behavior name is required")
+	}
+	if behavior.Category != category {
+		return fmt.Errorf("behavior category %q does not match directory %q", behavior.Category, category)
+	}
+	return nil
 }
 
 // loadBehaviorFromFile 从文件加载单个行为
@@ -81,7 +154,14 @@ func loadBehaviorFromFile(filePath string) (Behavior, error) {
 		return behavior, err
 	}
 
-	// 解析 JSON
+	// 按扩展名解析 YAML 或 JSON
+	if isYAMLFile(filePath) {
+		if err := UnmarshalBehaviorYAML(data, &behavior); err != nil {
+			return behavior, err
+		}
+		return behavior, nil
+	}
+
 	if err := json.Unmarshal(data, &behavior); err != nil {
 		return behavior, err
 	}
@@ -0,0 +1,697 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"uros-restron/internal/expr"
+)
+
+// ValidationSeverity 校验问题级别
+type ValidationSeverity string
+
+const (
+	// SeverityError 错误，行为不能被保存
+	SeverityError ValidationSeverity = "error"
+	// SeverityWarning 警告，行为可以保存但可能无法按预期运行
+	SeverityWarning ValidationSeverity = "warning"
+)
+
+// ValidationIssue 单个校验问题
+type ValidationIssue struct {
+	Path     string             `json:"path"` // JSON 路径，例如 $.functions.purify_air.input_params.air_quality.min
+	Severity ValidationSeverity `json:"severity"`
+	Code     string             `json:"code"`
+	Message  string             `json:"message"`
+}
+
+// ValidationResult 行为校验结果
+type ValidationResult struct {
+	Valid    bool              `json:"valid"`
+	Errors   []ValidationIssue `json:"errors"`
+	Warnings []ValidationIssue `json:"warnings"`
+}
+
+// Error 汇总所有错误，便于作为 error 返回
+func (r *ValidationResult) Error() string {
+	messages := make([]string, len(r.Errors))
+	for i, issue := range r.Errors {
+		messages[i] = issue.Path + ": " + issue.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (r *ValidationResult) addError(path, code, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, ValidationIssue{Path: path, Severity: SeverityError, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *ValidationResult) addWarning(path, code, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, ValidationIssue{Path: path, Severity: SeverityWarning, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// 参数支持的类型
+var parameterTypes = map[string]bool{
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"object":  true,
+	"array":   true,
+}
+
+// 字符串参数支持的格式
+var parameterFormats = map[string]bool{
+	"date-time": true,
+	"ISO8601":   true, // date-time 的别名
+	"date":      true,
+	"uuid":      true,
+	"email":     true,
+	"uri":       true,
+}
+
+// 已知的行为分类
+var behaviorCategories = map[string]bool{
+	"device": true,
+	"person": true,
+	"object": true,
+}
+
+// 邮箱支持的溢出策略
+var mailboxOverflowPolicies = map[string]bool{
+	"block":       true,
+	"drop_newest": true,
+	"drop_oldest": true,
+	"reject":      true,
+}
+
+// BehaviorValidator 行为定义校验器
+type BehaviorValidator struct {
+	actions map[string][]string // 已知动作及其输出字段
+}
+
+// NewBehaviorValidator 创建行为校验器，actions 为执行器支持的动作及其输出字段
+func NewBehaviorValidator(actions map[string][]string) *BehaviorValidator {
+	return &BehaviorValidator{actions: actions}
+}
+
+// Validate 校验行为定义
+func (v *BehaviorValidator) Validate(behavior *Behavior) *ValidationResult {
+	result := &ValidationResult{Errors: []ValidationIssue{}, Warnings: []ValidationIssue{}}
+
+	if strings.TrimSpace(behavior.Name) == "" {
+		result.addError("$.name", "required", "behavior name is required")
+	}
+	if behavior.Type == "" {
+		result.addWarning("$.type", "missing_type", "behavior type is empty")
+	}
+	if behavior.Category != "" && !behaviorCategories[behavior.Category] {
+		result.addWarning("$.category", "unknown_category", "unknown category %q", behavior.Category)
+	}
+	if len(behavior.Functions) == 0 {
+		result.addWarning("$.functions", "no_functions", "behavior defines no functions")
+	}
+
+	v.validateMailbox("$.mailbox", behavior.Mailbox, result)
+
+	for _, name := range sortedFunctionNames(behavior.Functions) {
+		v.validateFunction(fmt.Sprintf("$.functions.%s", name), behavior.Functions[name], result)
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result
+}
+
+// validateMailbox 校验邮箱容量和溢出策略
+func (v *BehaviorValidator) validateMailbox(path string, mailbox MailboxSettings, result *ValidationResult) {
+	if mailbox.Capacity < 0 {
+		result.addError(path+".capacity", "invalid_capacity", "mailbox capacity must not be negative")
+	}
+	if mailbox.Overflow != "" && !mailboxOverflowPolicies[mailbox.Overflow] {
+		result.addError(path+".overflow", "unknown_overflow", "unknown overflow policy %q", mailbox.Overflow)
+	}
+	if mailbox.BlockTimeoutMs < 0 {
+		result.addError(path+".block_timeout_ms", "invalid_timeout", "block timeout must not be negative")
+	}
+	if mailbox.MaxInvocations < 0 {
+		result.addError(path+".max_invocations", "invalid_limit", "max_invocations must not be negative")
+	}
+	if mailbox.BlockTimeoutMs > 0 && mailbox.Overflow != "" && mailbox.Overflow != "block" {
+		result.addWarning(path+".block_timeout_ms", "timeout_ignored", "block_timeout_ms only applies to the block overflow policy")
+	}
+}
+
+// validateFunction 校验单个函数
+func (v *BehaviorValidator) validateFunction(path string, function Function, result *ValidationResult) {
+	if function.Name == "" {
+		result.addWarning(path+".name", "missing_name", "function name is empty")
+	}
+
+	for _, name := range sortedParameterNames(function.InputParams) {
+		v.validateParameter(path+".input_params."+name, function.InputParams[name], true, result)
+	}
+	for _, name := range sortedParameterNames(function.OutputParams) {
+		v.validateParameter(path+".output_params."+name, function.OutputParams[name], false, result)
+	}
+
+	for i, topic := range function.Subscribe {
+		if strings.TrimSpace(topic) == "" {
+			result.addError(fmt.Sprintf("%s.subscribe[%d]", path, i), "required", "subscribed topic must not be empty")
+		}
+	}
+
+	v.validateImplementation(path, function, result)
+}
+
+// validateParameter 校验参数定义
+func (v *BehaviorValidator) validateParameter(path string, param Parameter, input bool, result *ValidationResult) {
+	if param.Type == "" {
+		result.addError(path+".type", "missing_type", "parameter type is required")
+		return
+	}
+	if !parameterTypes[param.Type] {
+		result.addError(path+".type", "unknown_type", "unknown parameter type %q, expected one of %s", param.Type, strings.Join(sortedKeys(parameterTypes), ", "))
+		return
+	}
+
+	numeric := param.Type == "number" || param.Type == "integer"
+	if param.Min != nil || param.Max != nil {
+		if !numeric {
+			result.addWarning(path, "range_ignored", "min/max only apply to number and integer parameters")
+		}
+		if param.Min != nil && param.Max != nil && *param.Min > *param.Max {
+			result.addError(path+".min", "invalid_range", "min %v is greater than max %v", *param.Min, *param.Max)
+		}
+	}
+
+	v.validateStringConstraints(path, param, result)
+	v.validateArrayConstraints(path, param, input, result)
+	v.validateObjectConstraints(path, param, input, result)
+
+	if len(param.Enum) > 0 {
+		if param.Type != "string" {
+			result.addWarning(path+".enum", "enum_ignored", "enum only applies to string parameters")
+		}
+		seen := make(map[string]bool)
+		for i, value := range param.Enum {
+			if seen[value] {
+				result.addWarning(fmt.Sprintf("%s.enum[%d]", path, i), "duplicate_enum", "duplicate enum value %q", value)
+			}
+			seen[value] = true
+		}
+	}
+
+	if param.Default != nil {
+		if !input {
+			result.addWarning(path+".default", "default_ignored", "defaults are ignored on output parameters")
+		}
+		if param.Required {
+			result.addWarning(path+".default", "default_unused", "required parameter has a default that is never used")
+		}
+		v.validateDefault(path+".default", param, result)
+	}
+}
+
+// validateDefault 校验默认值与参数定义是否一致
+func (v *BehaviorValidator) validateDefault(path string, param Parameter, result *ValidationResult) {
+	if param.Default == nil && param.Nullable {
+		return
+	}
+
+	switch param.Type {
+	case "string":
+		s, ok := param.Default.(string)
+		if !ok {
+			result.addError(path, "default_type", "default must be a string")
+			return
+		}
+		if len(param.Enum) > 0 && !containsString(param.Enum, s) {
+			result.addError(path, "default_not_in_enum", "default %q is not one of %v", s, param.Enum)
+		}
+	case "number", "integer":
+		n, ok := expr.ToNumber(param.Default)
+		if !ok {
+			result.addError(path, "default_type", "default must be a %s", param.Type)
+			return
+		}
+		if param.Type == "integer" && n != math.Trunc(n) {
+			result.addError(path, "default_type", "default must be an integer")
+		}
+		if param.Min != nil && n < *param.Min {
+			result.addError(path, "default_out_of_range", "default %v is less than min %v", n, *param.Min)
+		}
+		if param.Max != nil && n > *param.Max {
+			result.addError(path, "default_out_of_range", "default %v is greater than max %v", n, *param.Max)
+		}
+	case "boolean":
+		if _, ok := param.Default.(bool); !ok {
+			result.addError(path, "default_type", "default must be a boolean")
+		}
+	case "object":
+		if _, ok := param.Default.(map[string]interface{}); !ok {
+			result.addError(path, "default_type", "default must be an object")
+		}
+	case "array":
+		if _, ok := param.Default.([]interface{}); !ok {
+			result.addError(path, "default_type", "default must be an array")
+		}
+	}
+}
+
+// validateStringConstraints 校验字符串约束
+func (v *BehaviorValidator) validateStringConstraints(path string, param Parameter, result *ValidationResult) {
+	if param.Pattern == "" && param.Format == "" && param.MinLength == nil && param.MaxLength == nil {
+		return
+	}
+	if param.Type != "string" {
+		result.addWarning(path, "string_constraints_ignored", "pattern, format and length only apply to string parameters")
+	}
+
+	if param.Pattern != "" {
+		if _, err := regexp.Compile(param.Pattern); err != nil {
+			result.addError(path+".pattern", "invalid_pattern", "invalid pattern: %v", err)
+		}
+	}
+	if param.Format != "" && !parameterFormats[param.Format] {
+		result.addWarning(path+".format", "unknown_format", "unknown format %q is not enforced, expected one of %s", param.Format, strings.Join(sortedKeys(parameterFormats), ", "))
+	}
+	if param.MinLength != nil && *param.MinLength < 0 {
+		result.addError(path+".min_length", "invalid_length", "min_length must not be negative")
+	}
+	if param.MinLength != nil && param.MaxLength != nil && *param.MinLength > *param.MaxLength {
+		result.addError(path+".min_length", "invalid_length", "min_length %d is greater than max_length %d", *param.MinLength, *param.MaxLength)
+	}
+}
+
+// validateArrayConstraints 校验数组约束及元素定义
+func (v *BehaviorValidator) validateArrayConstraints(path string, param Parameter, input bool, result *ValidationResult) {
+	if param.Items == nil && param.MinItems == nil && param.MaxItems == nil {
+		return
+	}
+	if param.Type != "array" {
+		result.addWarning(path, "array_constraints_ignored", "items, min_items and max_items only apply to array parameters")
+	}
+
+	if param.MinItems != nil && *param.MinItems < 0 {
+		result.addError(path+".min_items", "invalid_items", "min_items must not be negative")
+	}
+	if param.MinItems != nil && param.MaxItems != nil && *param.MinItems > *param.MaxItems {
+		result.addError(path+".min_items", "invalid_items", "min_items %d is greater than max_items %d", *param.MinItems, *param.MaxItems)
+	}
+	if param.Items != nil {
+		v.validateParameter(path+".items", *param.Items, input, result)
+	}
+}
+
+// validateObjectConstraints 校验对象属性定义
+func (v *BehaviorValidator) validateObjectConstraints(path string, param Parameter, input bool, result *ValidationResult) {
+	if len(param.Properties) == 0 && param.AdditionalProperties == nil {
+		return
+	}
+	if param.Type != "object" {
+		result.addWarning(path, "object_constraints_ignored", "properties and additional_properties only apply to object parameters")
+	}
+
+	for _, name := range sortedParameterNames(param.Properties) {
+		v.validateParameter(path+".properties."+name, param.Properties[name], input, result)
+	}
+}
+
+// 已知的步骤类型
+var stepTypes = map[string]bool{
+	StepTypeAction:   true,
+	StepTypeIf:       true,
+	StepTypeLoop:     true,
+	StepTypeParallel: true,
+	StepTypeWait:     true,
+}
+
+// stepScope 校验步骤时的变量作用域
+type stepScope struct {
+	available map[string]bool // 条件中可以引用的变量
+	produced  map[string]bool // 步骤可能产生的输出
+	allKnown  bool            // 是否所有动作都已知
+}
+
+// declare 在作用域中临时声明变量，返回的函数恢复原状态
+func (s *stepScope) declare(name string) func() {
+	existed := s.available[name]
+	s.available[name] = true
+	return func() {
+		if !existed {
+			delete(s.available, name)
+		}
+	}
+}
+
+// validateImplementation 校验函数实现步骤，path 为函数的路径
+func (v *BehaviorValidator) validateImplementation(path string, function Function, result *ValidationResult) {
+	steps := function.Implementation.Steps
+	if len(steps) == 0 {
+		result.addWarning(path+".implementation.steps", "no_steps", "function has no implementation steps")
+		return
+	}
+
+	// 条件中可以引用的变量：输入参数和前序步骤的输出
+	scope := &stepScope{available: make(map[string]bool), produced: make(map[string]bool), allKnown: true}
+	for name := range function.InputParams {
+		scope.available[name] = true
+	}
+
+	v.validateSteps(path+".implementation.steps", steps, true, scope, result)
+
+	// 显式声明输出映射时，输出参数只来自映射
+	if len(function.Implementation.Outputs) > 0 {
+		v.validateOutputMappings(path, function, scope, result)
+		return
+	}
+
+	// 只有在所有动作都已知时才能判断输出参数是否会被产生
+	if scope.allKnown {
+		for _, name := range sortedParameterNames(function.OutputParams) {
+			if !scope.produced[name] {
+				result.addWarning(path+".output_params."+name, "output_not_produced", "output parameter %s is not produced by any step", name)
+			}
+		}
+	}
+}
+
+// validateSteps 校验一组步骤，顶层步骤必须有唯一的正数编号，嵌套步骤的编号可以省略
+func (v *BehaviorValidator) validateSteps(path string, steps []ImplementationStep, topLevel bool, scope *stepScope, result *ValidationResult) {
+	seenSteps := make(map[int]int)
+	for i, step := range steps {
+		stepPath := fmt.Sprintf("%s[%d]", path, i)
+
+		if step.Step < 0 || (topLevel && step.Step == 0) {
+			result.addError(stepPath+".step", "invalid_step_number", "step number must be positive")
+		} else if prev, exists := seenSteps[step.Step]; exists {
+			result.addError(stepPath+".step", "duplicate_step", "step number %d is already used by steps[%d]", step.Step, prev)
+		} else if step.Step > 0 {
+			seenSteps[step.Step] = i
+			if i > 0 && step.Step < steps[i-1].Step {
+				result.addWarning(stepPath+".step", "step_order", "step %d is listed after step %d; steps run in listed order", step.Step, steps[i-1].Step)
+			}
+		}
+
+		v.validateStep(stepPath, step, scope, result)
+	}
+}
+
+// validateStep 按类型校验单个步骤
+func (v *BehaviorValidator) validateStep(path string, step ImplementationStep, scope *stepScope, result *ValidationResult) {
+	stepType := step.StepType()
+	if !stepTypes[stepType] {
+		result.addError(path+".type", "unknown_step_type", "unknown step type %q, expected one of %s", step.Type, strings.Join(sortedKeys(stepTypes), ", "))
+		return
+	}
+
+	if step.Condition != "" {
+		v.validateStepCondition(path+".condition", step.Condition, scope, result)
+	}
+	if step.TimeoutMs < 0 {
+		result.addError(path+".timeout_ms", "invalid_timeout", "timeout_ms must not be negative")
+	}
+	if stepType != StepTypeAction && step.Action != "" {
+		result.addWarning(path+".action", "action_ignored", "action is ignored on %s steps", stepType)
+	}
+	if stepType != StepTypeAction && (len(step.Inputs) > 0 || len(step.Outputs) > 0) {
+		result.addWarning(path, "bindings_ignored", "inputs and outputs are ignored on %s steps", stepType)
+	}
+
+	switch stepType {
+	case StepTypeAction:
+		v.validateAction(path, step, scope, result)
+
+	case StepTypeIf:
+		if step.Condition == "" {
+			result.addError(path+".condition", "required", "if step requires a condition")
+		}
+		if len(step.Then) == 0 && len(step.Else) == 0 {
+			result.addWarning(path, "empty_branch", "if step has neither then nor else steps")
+		}
+		v.validateSteps(path+".then", step.Then, false, scope, result)
+		v.validateSteps(path+".else", step.Else, false, scope, result)
+
+	case StepTypeLoop:
+		if len(step.Steps) == 0 {
+			result.addError(path+".steps", "required", "loop step requires steps")
+		}
+		if step.MaxIterations <= 0 {
+			result.addError(path+".max_iterations", "required", "loop step requires a positive max_iterations")
+		}
+		if step.IntervalMs < 0 {
+			result.addError(path+".interval_ms", "invalid_interval", "interval_ms must not be negative")
+		}
+		restore := scope.declare("iteration")
+		v.validateSteps(path+".steps", step.Steps, false, scope, result)
+		if step.Until != "" {
+			v.validateStepCondition(path+".until", step.Until, scope, result)
+		}
+		restore()
+
+	case StepTypeParallel:
+		if len(step.Branches) < 2 {
+			result.addWarning(path+".branches", "few_branches", "parallel step has fewer than two branches")
+		}
+		for i, branch := range step.Branches {
+			v.validateSteps(fmt.Sprintf("%s.branches[%d]", path, i), branch, false, scope, result)
+		}
+
+	case StepTypeWait:
+		if step.DurationMs <= 0 {
+			result.addError(path+".duration_ms", "required", "wait step requires a positive duration_ms")
+		}
+		if step.Until != "" {
+			v.validateStepCondition(path+".until", step.Until, scope, result)
+		}
+	}
+
+	if len(step.OnError) > 0 {
+		restore := scope.declare("error")
+		v.validateSteps(path+".on_error", step.OnError, false, scope, result)
+		restore()
+	}
+	v.validateSteps(path+".compensate", step.Compensate, false, scope, result)
+}
+
+// validateAction 校验动作步骤，并记录动作产生的输出或映射的变量
+func (v *BehaviorValidator) validateAction(path string, step ImplementationStep, scope *stepScope, result *ValidationResult) {
+	for _, name := range sortedBindingNames(step.Inputs) {
+		v.validateCondition(path+".inputs."+name, step.Inputs[name], scope.available, result)
+	}
+
+	if step.Action == "" {
+		result.addError(path+".action", "required", "step action is required")
+		return
+	}
+	outputs, known := v.actions[step.Action]
+	if !known {
+		result.addWarning(path+".action", "unknown_action", "unknown action %q will be simulated", step.Action)
+	}
+	v.validateMessaging(path, step, result)
+	if step.Action == ActionEmitEvent && step.Event == "" {
+		result.addError(path+".event", "required", "emit_event requires an event type")
+	} else if step.Action != ActionEmitEvent && step.Event != "" {
+		result.addWarning(path+".event", "event_ignored", "event only applies to %s actions", ActionEmitEvent)
+	}
+
+	// 映射到变量的输出不会合并到函数结果
+	if len(step.Outputs) > 0 {
+		actionOutputs := make(map[string]bool, len(outputs)+1)
+		for _, output := range outputs {
+			actionOutputs[output] = true
+		}
+		actionOutputs["output"] = true
+		for _, name := range sortedBindingNames(step.Outputs) {
+			bindingPath := path + ".outputs." + name
+			if known {
+				v.validateCondition(bindingPath, step.Outputs[name], actionOutputs, result)
+			} else if _, err := expr.Parse(step.Outputs[name]); err != nil {
+				result.addError(bindingPath, "invalid_condition", "%v", err)
+			}
+			if expressionScopes[name] {
+				result.addError(bindingPath, "reserved_variable", "variable name %q is reserved", name)
+			}
+			scope.available[name] = true
+			scope.produced[name] = true
+		}
+		return
+	}
+
+	if !known {
+		scope.allKnown = false
+		return
+	}
+	for _, output := range outputs {
+		scope.available[output] = true
+		scope.produced[output] = true
+	}
+}
+
+// validateMessaging 校验消息动作的接收方、函数和主题，其他动作设置这些字段时给出警告
+func (v *BehaviorValidator) validateMessaging(path string, step ImplementationStep, result *ValidationResult) {
+	switch step.Action {
+	case ActionSendMessage, ActionCallActor:
+		if step.Function == "" {
+			result.addError(path+".function", "required", "%s requires the function to call", step.Action)
+		}
+		if step.Target == nil {
+			result.addError(path+".target", "required", "%s requires a target", step.Action)
+			return
+		}
+		v.validateTarget(path+".target", *step.Target, result)
+
+	case ActionPublish:
+		if step.Topic == "" {
+			result.addError(path+".topic", "required", "publish requires a topic")
+		}
+
+	default:
+		if step.Target != nil || step.Function != "" || step.Topic != "" {
+			result.addWarning(path, "messaging_ignored", "target, function and topic only apply to %s, %s and %s actions", ActionSendMessage, ActionCallActor, ActionPublish)
+		}
+	}
+}
+
+// validateTarget 校验消息接收方，actor、thing 和 relationship 必须且只能设置一个
+func (v *BehaviorValidator) validateTarget(path string, target MessageTarget, result *ValidationResult) {
+	set := 0
+	for _, value := range []string{target.Actor, target.Thing, target.Relationship} {
+		if value != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		result.addError(path, "invalid_target", "target requires exactly one of actor, thing or relationship")
+	}
+
+	switch target.Direction {
+	case "", DirectionOutgoing, DirectionIncoming, DirectionBoth:
+		if target.Direction != "" && target.Relationship == "" {
+			result.addWarning(path+".direction", "direction_ignored", "direction only applies to relationship targets")
+		}
+	default:
+		result.addError(path+".direction", "invalid_direction", "unknown direction %q, expected one of %s, %s, %s", target.Direction, DirectionOutgoing, DirectionIncoming, DirectionBoth)
+	}
+}
+
+// validateOutputMappings 校验函数输出映射
+func (v *BehaviorValidator) validateOutputMappings(path string, function Function, scope *stepScope, result *ValidationResult) {
+	mappings := function.Implementation.Outputs
+	for _, name := range sortedBindingNames(mappings) {
+		mappingPath := path + ".implementation.outputs." + name
+		if _, declared := function.OutputParams[name]; !declared {
+			result.addWarning(mappingPath, "undeclared_output", "output %s is not declared in output_params", name)
+		}
+		v.validateCondition(mappingPath, mappings[name], scope.available, result)
+	}
+
+	for _, name := range sortedParameterNames(function.OutputParams) {
+		if _, mapped := mappings[name]; !mapped {
+			result.addWarning(path+".output_params."+name, "output_not_mapped", "output parameter %s has no mapping in implementation.outputs", name)
+		}
+	}
+}
+
+// expressionScopes 表达式中始终可用的命名空间
+var expressionScopes = map[string]bool{
+	"params": true,
+	"result": true,
+	"vars":   true,
+	"thing":  true,
+	"state":  true,
+}
+
+// validateCondition 校验表达式语法及其引用的变量
+func (v *BehaviorValidator) validateCondition(path, condition string, available map[string]bool, result *ValidationResult) {
+	parsed, err := expr.Parse(condition)
+	if err != nil {
+		result.addError(path, "invalid_condition", "%v", err)
+		return
+	}
+
+	for _, root := range unknownVariables(parsed, available) {
+		result.addWarning(path, "unknown_variable", "expression references %q which is neither an input parameter nor an earlier step output or variable", root)
+	}
+}
+
+// validateStepCondition 校验步骤、if、loop 和 wait 的条件
+// 条件引用未定义的变量时执行会失败，前序步骤都是已知动作时作为错误报告，
+// 否则变量可能由未知动作产生，只给出警告
+func (v *BehaviorValidator) validateStepCondition(path, condition string, scope *stepScope, result *ValidationResult) {
+	if !scope.allKnown {
+		v.validateCondition(path, condition, scope.available, result)
+		return
+	}
+
+	parsed, err := expr.Parse(condition)
+	if err != nil {
+		result.addError(path, "invalid_condition", "%v", err)
+		return
+	}
+	for _, root := range unknownVariables(parsed, scope.available) {
+		result.addError(path, "undefined_variable", "condition references %q which is neither an input parameter nor an earlier step output or variable", root)
+	}
+}
+
+// unknownVariables 返回表达式引用的、既不是命名空间也不在 available 中的变量
+func unknownVariables(parsed *expr.Expression, available map[string]bool) []string {
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, ident := range parsed.Identifiers() {
+		root := strings.SplitN(ident, ".", 2)[0]
+		if expressionScopes[root] || available[root] || seen[root] {
+			continue
+		}
+		seen[root] = true
+		unknown = append(unknown, root)
+	}
+	return unknown
+}
+
+func sortedFunctionNames(functions map[string]Function) []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedParameterNames(params map[string]Parameter) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedBindingNames(bindings map[string]string) []string {
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,293 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// BehaviorChangeType 行为文件变更类型
+type BehaviorChangeType string
+
+const (
+	// BehaviorLoaded 新的行为文件被加载
+	BehaviorLoaded BehaviorChangeType = "behavior_loaded"
+	// BehaviorReloaded 已有行为的内容发生变化
+	BehaviorReloaded BehaviorChangeType = "behavior_reloaded"
+	// BehaviorRemoved 行为文件被删除
+	BehaviorRemoved BehaviorChangeType = "behavior_removed"
+	// BehaviorLoadFailed 行为文件加载失败
+	BehaviorLoadFailed BehaviorChangeType = "behavior_load_error"
+)
+
+// BehaviorChangeEvent 行为文件变更事件
+type BehaviorChangeEvent struct {
+	Type       BehaviorChangeType `json:"type"`
+	BehaviorID string             `json:"behaviorId,omitempty"`
+	File       string             `json:"file"`
+	Version    int                `json:"version,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Timestamp  time.Time          `json:"timestamp"`
+}
+
+// BehaviorChangeListener 行为变更监听器
+type BehaviorChangeListener func(event BehaviorChangeEvent)
+
+// BehaviorWatcher 监听行为目录并把变更同步到数据库
+type BehaviorWatcher struct {
+	service  *BehaviorService
+	root     string
+	debounce time.Duration
+
+	watcher   *fsnotify.Watcher
+	mu        sync.Mutex
+	pending   map[string]*time.Timer
+	syncs     sync.WaitGroup // 已计划和正在执行的防抖同步
+	listeners []BehaviorChangeListener
+	done      chan struct{}
+}
+
+// NewBehaviorWatcher 创建行为目录监听器
+func NewBehaviorWatcher(service *BehaviorService) *BehaviorWatcher {
+	return &BehaviorWatcher{
+		service:  service,
+		root:     service.BehaviorsPath(),
+		debounce: 200 * time.Millisecond,
+		pending:  make(map[string]*time.Timer),
+		done:     make(chan struct{}),
+	}
+}
+
+// OnChange 注册行为变更监听器，需在 Start 之前调用
+func (w *BehaviorWatcher) OnChange(listener BehaviorChangeListener) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, listener)
+}
+
+// Start 执行一次完整同步并开始监听目录变化
+func (w *BehaviorWatcher) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create behavior watcher: %v", err)
+	}
+	if err := watcher.Add(w.root); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %v", w.root, err)
+	}
+
+	categories, err := scanCategories(w.root)
+	if err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to scan categories: %v", err)
+	}
+	// 启动失败时保持为 nil，Stop 不等待没有启动的事件循环
+	w.watcher = watcher
+	for _, category := range categories {
+		w.watchCategory(filepath.Join(w.root, category))
+	}
+
+	go w.run(ctx)
+
+	log.Printf("Watching behaviors in %s", w.root)
+	return nil
+}
+
+// Stop 停止监听，取消等待中的同步并等待事件循环和正在执行的同步完成，ctx 到期时不再等待
+func (w *BehaviorWatcher) Stop(ctx context.Context) error {
+	if w.watcher == nil {
+		return nil
+	}
+	err := w.watcher.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		<-w.done
+		// 事件循环退出后不再计划新的同步
+		w.mu.Lock()
+		for file, timer := range w.pending {
+			if timer.Stop() {
+				w.syncs.Done()
+			}
+			delete(w.pending, file)
+		}
+		w.mu.Unlock()
+		w.syncs.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// watchCategory 监听分类目录并同步其中已有的文件
+func (w *BehaviorWatcher) watchCategory(categoryPath string) {
+	if err := w.watcher.Add(categoryPath); err != nil {
+		log.Printf("Failed to watch behavior category %s: %v", categoryPath, err)
+		return
+	}
+
+	files, err := listBehaviorFiles(categoryPath)
+	if err != nil {
+		log.Printf("Failed to list behavior category %s: %v", categoryPath, err)
+		return
+	}
+	for _, file := range files {
+		w.syncFile(file)
+	}
+}
+
+// run 事件循环
+func (w *BehaviorWatcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Behavior watcher error: %v", err)
+
+		case <-ctx.Done():
+			w.watcher.Close()
+			return
+		}
+	}
+}
+
+// handleEvent 处理单个文件系统事件
+func (w *BehaviorWatcher) handleEvent(event fsnotify.Event) {
+	// 新建的分类目录
+	if filepath.Dir(event.Name) == filepath.Clean(w.root) {
+		if event.Has(fsnotify.Create) {
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				w.watchCategory(event.Name)
+			}
+		}
+		return
+	}
+
+	if !IsBehaviorFile(event.Name) {
+		return
+	}
+
+	// 编辑器保存文件时通常会产生多个事件，合并后再处理
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if timer, exists := w.pending[event.Name]; exists && timer.Stop() {
+		w.syncs.Done()
+	}
+	file := event.Name
+	w.syncs.Add(1)
+	w.pending[file] = time.AfterFunc(w.debounce, func() {
+		defer w.syncs.Done()
+		w.mu.Lock()
+		delete(w.pending, file)
+		w.mu.Unlock()
+		w.syncFile(file)
+	})
+}
+
+// syncFile 根据文件当前状态同步数据库
+func (w *BehaviorWatcher) syncFile(file string) {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		w.removeFile(file)
+		return
+	}
+
+	behavior, err := w.service.LoadBehaviorFile(file)
+	if err != nil {
+		w.reportError(file, err)
+		return
+	}
+
+	var existing Behavior
+	isNew := w.service.db.Select("id").First(&existing, "id = ?", behavior.ID).Error != nil
+
+	changed, err := w.service.UpsertBehavior(&behavior)
+	if err != nil {
+		w.reportError(file, err)
+		return
+	}
+	w.service.ClearLoadError(file)
+
+	if !changed {
+		return
+	}
+
+	changeType := BehaviorReloaded
+	if isNew {
+		changeType = BehaviorLoaded
+	}
+	log.Printf("Behavior %s loaded from %s (version %d)", behavior.ID, file, behavior.Version)
+	w.notify(BehaviorChangeEvent{
+		Type:       changeType,
+		BehaviorID: behavior.ID,
+		File:       file,
+		Version:    behavior.Version,
+	})
+}
+
+// removeFile 删除来自已移除文件的行为
+func (w *BehaviorWatcher) removeFile(file string) {
+	w.service.ClearLoadError(file)
+
+	ids, err := w.service.DeleteBehaviorsBySource(file)
+	if err != nil {
+		w.reportError(file, err)
+		return
+	}
+
+	for _, id := range ids {
+		log.Printf("Behavior %s removed with %s", id, file)
+		w.notify(BehaviorChangeEvent{
+			Type:       BehaviorRemoved,
+			BehaviorID: id,
+			File:       file,
+		})
+	}
+}
+
+// reportError 记录并广播加载错误
+func (w *BehaviorWatcher) reportError(file string, err error) {
+	loadErr := NewBehaviorLoadError(file, err)
+	w.service.RecordLoadError(loadErr)
+
+	log.Printf("Failed to load behavior from %s: %v", file, err)
+	w.notify(BehaviorChangeEvent{
+		Type:  BehaviorLoadFailed,
+		File:  file,
+		Error: loadErr.Error,
+	})
+}
+
+// notify 通知所有监听器
+func (w *BehaviorWatcher) notify(event BehaviorChangeEvent) {
+	event.Timestamp = time.Now()
+
+	w.mu.Lock()
+	listeners := make([]BehaviorChangeListener, len(w.listeners))
+	copy(listeners, w.listeners)
+	w.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnmarshalBehaviorYAML 从 YAML 解析行为定义
+// YAML 先转换为 JSON 再解码，因此字段名与 JSON 定义完全一致，锚点和合并键（<<）由 YAML 解析器展开
+func UnmarshalBehaviorYAML(data []byte, behavior *Behavior) error {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return err
+	}
+
+	normalized, err := normalizeYAML(document)
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := json.Marshal(normalized)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, behavior)
+}
+
+// MarshalBehaviorYAML 把行为定义输出为 YAML，字段顺序与 JSON 输出一致
+func MarshalBehaviorYAML(behavior *Behavior) ([]byte, error) {
+	jsonData, err := json.Marshal(behavior)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON 是合法的 YAML，解析为节点树可以保留字段顺序
+	var node yaml.Node
+	if err := yaml.Unmarshal(jsonData, &node); err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// normalizeYAML 把 YAML 解码得到的非字符串键映射转换为 JSON 可编码的结构
+func normalizeYAML(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			normalized, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = normalized
+		}
+		return v, nil
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			result[fmt.Sprint(key)] = normalized
+		}
+		return result, nil
+	case []interface{}:
+		for i, item := range v {
+			normalized, err := normalizeYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = normalized
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// resetYAMLStyle 清除从 JSON 继承的流式和引号风格，输出块风格的 YAML
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
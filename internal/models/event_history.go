@@ -0,0 +1,260 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"uros-restron/internal/events"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 事件历史记录参数
+const (
+	historyQueueSize     = 1024 // 等待写入的事件数，队列已满时丢弃事件
+	historyBatchSize     = 100
+	historyPruneInterval = 10 * time.Minute
+)
+
+// HistoryEvent 事件总线上发布过的领域事件，由 HistoryRecorder 写入
+type HistoryEvent struct {
+	ID          uint64          `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type        string          `json:"type" gorm:"not null;index"`
+	Aggregate   string          `json:"aggregate" gorm:"not null;index"`
+	AggregateID string          `json:"aggregateId" gorm:"index"`
+	ThingID     string          `json:"thingId,omitempty" gorm:"index"`
+	Sequence    *uint64         `json:"sequence,omitempty" gorm:"uniqueIndex"` // 发件箱序号，重复发布的事件只记录一次
+	Data        json.RawMessage `json:"data" gorm:"-"`
+	PayloadJSON string          `json:"-" gorm:"column:payload;type:text"`
+	Time        time.Time       `json:"time" gorm:"index"`
+}
+
+// AfterFind GORM hook for deserializing data after retrieval
+func (e *HistoryEvent) AfterFind(tx *gorm.DB) error {
+	if e.PayloadJSON != "" {
+		e.Data = json.RawMessage(e.PayloadJSON)
+	}
+	return nil
+}
+
+// HistoryFilter 事件历史查询条件
+type HistoryFilter struct {
+	Type        string
+	Aggregate   string
+	AggregateID string
+	ThingID     string
+	Since       *time.Time
+	Until       *time.Time
+	Limit       int
+	Offset      int
+}
+
+// HistoryService 提供事件历史的存储和查询
+type HistoryService struct {
+	db *gorm.DB
+}
+
+// NewHistoryService 创建事件历史服务
+func NewHistoryService(db *gorm.DB) *HistoryService {
+	return &HistoryService{db: db}
+}
+
+// AppendEvents 批量写入事件，发件箱序号已存在的事件被跳过
+func (s *HistoryService) AppendEvents(entries []HistoryEvent) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entries).Error
+}
+
+// ListEvents 按条件查询事件历史，按时间倒序，返回事件和总数
+func (s *HistoryService) ListEvents(filter HistoryFilter) ([]HistoryEvent, int64, error) {
+	query := s.db.Model(&HistoryEvent{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Aggregate != "" {
+		query = query.Where("aggregate = ?", filter.Aggregate)
+	}
+	if filter.AggregateID != "" {
+		query = query.Where("aggregate_id = ?", filter.AggregateID)
+	}
+	if filter.ThingID != "" {
+		query = query.Where("thing_id = ?", filter.ThingID)
+	}
+	if filter.Since != nil {
+		query = query.Where("time >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("time < ?", *filter.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []HistoryEvent
+	err := query.Order("time DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// PruneBefore 删除在 before 之前发生的事件，返回删除的数量
+func (s *HistoryService) PruneBefore(before time.Time) (int64, error) {
+	result := s.db.Where("time < ?", before).Delete(&HistoryEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// HistoryRecorder 订阅事件总线并把事件写入事件历史
+// 事件总线同步调用订阅者，HandleEvent 只在发布者的 goroutine 中编码事件并放入队列，
+// 由单个 goroutine 批量写入；队列已满时丢弃事件并计数，不阻塞发布者
+type HistoryRecorder struct {
+	service   *HistoryService
+	retention time.Duration // 事件的保留时长，不大于 0 时不清理
+	queue     chan HistoryEvent
+	dropped   int64 // 队列已满丢弃的事件数，原子访问
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewHistoryRecorder 创建事件历史记录器
+func NewHistoryRecorder(service *HistoryService, retention time.Duration) *HistoryRecorder {
+	return &HistoryRecorder{
+		service:   service,
+		retention: retention,
+		queue:     make(chan HistoryEvent, historyQueueSize),
+	}
+}
+
+// HandleEvent 把领域事件放入写入队列，可直接订阅事件总线
+// 数据在此时编码，之后发布者修改数据不影响记录
+func (r *HistoryRecorder) HandleEvent(event events.Event) {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		log.Printf("Failed to encode %s event for history: %v", event.Type, err)
+		return
+	}
+	entry := HistoryEvent{
+		Type:        string(event.Type),
+		Aggregate:   event.Aggregate,
+		AggregateID: event.ID,
+		ThingID:     event.ThingID,
+		PayloadJSON: string(payload),
+		Time:        event.Time,
+	}
+	if event.Sequence > 0 {
+		sequence := event.Sequence
+		entry.Sequence = &sequence
+	}
+
+	select {
+	case r.queue <- entry:
+	default:
+		if atomic.AddInt64(&r.dropped, 1)%historyQueueSize == 1 {
+			log.Printf("Event history queue is full, dropped %d events", atomic.LoadInt64(&r.dropped))
+		}
+	}
+}
+
+// Dropped 返回队列已满丢弃的事件数
+func (r *HistoryRecorder) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// Start 立即清理一次过期事件，之后写入队列中的事件并定期清理，直到 ctx 取消或调用 Stop
+func (r *HistoryRecorder) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go r.run(ctx)
+}
+
+// Stop 停止记录，写入队列中剩余的事件后返回，ctx 到期时不再等待
+// 应在最后一个发布者停止之后、关闭数据库之前调用
+func (r *HistoryRecorder) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run 写入循环
+func (r *HistoryRecorder) run(ctx context.Context) {
+	defer close(r.done)
+	prune := time.NewTicker(historyPruneInterval)
+	defer prune.Stop()
+
+	r.prune()
+	for {
+		select {
+		case entry := <-r.queue:
+			r.write(entry)
+		case <-prune.C:
+			r.prune()
+		case <-ctx.Done():
+			r.drain()
+			return
+		}
+	}
+}
+
+// write 写入事件以及队列中已有的事件，一次最多写入 historyBatchSize 个
+func (r *HistoryRecorder) write(first HistoryEvent) {
+	batch := []HistoryEvent{first}
+	for len(batch) < historyBatchSize {
+		select {
+		case entry := <-r.queue:
+			batch = append(batch, entry)
+		default:
+			r.flush(batch)
+			return
+		}
+	}
+	r.flush(batch)
+}
+
+// drain 写入停止时队列中剩余的事件
+func (r *HistoryRecorder) drain() {
+	for {
+		select {
+		case entry := <-r.queue:
+			r.write(entry)
+		default:
+			return
+		}
+	}
+}
+
+// flush 写入一批事件，失败只记录日志
+func (r *HistoryRecorder) flush(batch []HistoryEvent) {
+	if err := r.service.AppendEvents(batch); err != nil {
+		log.Printf("Failed to record %d events in history: %v", len(batch), err)
+	}
+}
+
+// prune 删除超过保留时长的事件
+func (r *HistoryRecorder) prune() {
+	if r.retention <= 0 {
+		return
+	}
+	removed, err := r.service.PruneBefore(time.Now().Add(-r.retention))
+	if err != nil {
+		log.Printf("Failed to prune event history: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Pruned %d events from history", removed)
+	}
+}
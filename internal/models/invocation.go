@@ -0,0 +1,264 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InvocationStatus 函数调用状态
+type InvocationStatus string
+
+const (
+	InvocationRunning   InvocationStatus = "running"
+	InvocationSucceeded InvocationStatus = "succeeded"
+	InvocationFailed    InvocationStatus = "failed"
+	InvocationCancelled InvocationStatus = "cancelled"
+)
+
+// invocationPruneInterval 清理已结束调用记录的间隔
+const invocationPruneInterval = 10 * time.Minute
+
+// Invocation 一次行为函数调用的记录
+type Invocation struct {
+	ID       string           `json:"id" gorm:"primaryKey"`
+	ActorID  string           `json:"actorId" gorm:"index"`
+	ThingID  string           `json:"thingId,omitempty" gorm:"index"`
+	Function string           `json:"function" gorm:"index"`
+	Caller   string           `json:"caller" gorm:"index"`
+	Status   InvocationStatus `json:"status" gorm:"index"`
+	Error    string           `json:"error,omitempty"`
+	Async    bool             `json:"async"`
+
+	Progress        *float64 `json:"progress,omitempty"` // 0 到 1，由步骤报告
+	ProgressMessage string   `json:"progressMessage,omitempty"`
+
+	Params     map[string]interface{} `json:"params" gorm:"-"`
+	ParamsJSON string                 `json:"-" gorm:"column:params;type:text"`
+	Result     map[string]interface{} `json:"result,omitempty" gorm:"-"`
+	ResultJSON string                 `json:"-" gorm:"column:result;type:text"`
+	Trace      json.RawMessage        `json:"trace,omitempty" gorm:"-"` // 每个步骤的耗时和输出
+	TraceJSON  string                 `json:"-" gorm:"column:trace;type:text"`
+
+	StartedAt  time.Time  `json:"startedAt" gorm:"index"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	DurationMs int64      `json:"durationMs"`
+}
+
+// InvocationFilter 调用记录查询条件
+type InvocationFilter struct {
+	ActorID  string
+	ThingID  string
+	Function string
+	Caller   string
+	Status   InvocationStatus
+	Since    *time.Time
+	Until    *time.Time
+	Limit    int
+	Offset   int
+}
+
+// BeforeSave GORM hook for serializing data before save
+func (inv *Invocation) BeforeSave(tx *gorm.DB) error {
+	if inv.ID == "" {
+		inv.ID = uuid.New().String()
+	}
+	return inv.serializeData()
+}
+
+// AfterFind GORM hook for deserializing data after retrieval
+func (inv *Invocation) AfterFind(tx *gorm.DB) error {
+	return inv.deserializeData()
+}
+
+// serializeData 序列化 JSON 字段
+func (inv *Invocation) serializeData() error {
+	if inv.Params != nil {
+		data, err := json.Marshal(inv.Params)
+		if err != nil {
+			return err
+		}
+		inv.ParamsJSON = string(data)
+	}
+	if inv.Result != nil {
+		data, err := json.Marshal(inv.Result)
+		if err != nil {
+			return err
+		}
+		inv.ResultJSON = string(data)
+	}
+	if inv.Trace != nil {
+		inv.TraceJSON = string(inv.Trace)
+	}
+	return nil
+}
+
+// deserializeData 反序列化 JSON 字段
+func (inv *Invocation) deserializeData() error {
+	if inv.ParamsJSON != "" {
+		if err := json.Unmarshal([]byte(inv.ParamsJSON), &inv.Params); err != nil {
+			return err
+		}
+	}
+	if inv.ResultJSON != "" {
+		if err := json.Unmarshal([]byte(inv.ResultJSON), &inv.Result); err != nil {
+			return err
+		}
+	}
+	if inv.TraceJSON != "" {
+		inv.Trace = json.RawMessage(inv.TraceJSON)
+	}
+	return nil
+}
+
+// InvocationService 提供调用记录的存储和查询
+type InvocationService struct {
+	db *gorm.DB
+}
+
+// NewInvocationService 创建调用记录服务
+func NewInvocationService(db *gorm.DB) *InvocationService {
+	return &InvocationService{db: db}
+}
+
+// SaveInvocation 创建或更新调用记录
+func (s *InvocationService) SaveInvocation(inv *Invocation) error {
+	return s.db.Save(inv).Error
+}
+
+// GetInvocation 根据ID获取调用记录
+func (s *InvocationService) GetInvocation(id string) (*Invocation, error) {
+	var inv Invocation
+	if err := s.db.First(&inv, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// ListInvocations 按条件查询调用记录，按开始时间倒序，返回记录和总数
+func (s *InvocationService) ListInvocations(filter InvocationFilter) ([]Invocation, int64, error) {
+	query := s.db.Model(&Invocation{})
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.ThingID != "" {
+		query = query.Where("thing_id = ?", filter.ThingID)
+	}
+	if filter.Function != "" {
+		query = query.Where("function = ?", filter.Function)
+	}
+	if filter.Caller != "" {
+		query = query.Where("caller = ?", filter.Caller)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Since != nil {
+		query = query.Where("started_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("started_at < ?", *filter.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var invocations []Invocation
+	err := query.Order("started_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&invocations).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return invocations, total, nil
+}
+
+// FailInterrupted 把仍为 running 的调用记为 failed，返回更新的数量
+// 在启动时、开始执行调用之前调用：上次进程崩溃或被杀死时未结束的调用不会再完成
+func (s *InvocationService) FailInterrupted(reason string) (int64, error) {
+	// 不触发 BeforeSave，否则空模型会被分配新ID，更新条件变为按该ID匹配
+	now := time.Now()
+	result := s.db.Model(&Invocation{}).Where("status = ?", InvocationRunning).UpdateColumns(map[string]interface{}{
+		"status":      InvocationFailed,
+		"error":       reason,
+		"finished_at": now,
+	})
+	return result.RowsAffected, result.Error
+}
+
+// PruneFinished 删除在 before 之前结束的调用记录，返回删除的数量
+func (s *InvocationService) PruneFinished(before time.Time) (int64, error) {
+	result := s.db.Where("status <> ? AND finished_at < ?", InvocationRunning, before).Delete(&Invocation{})
+	return result.RowsAffected, result.Error
+}
+
+// InvocationPruner 定期删除超过保留时长的已结束调用记录
+type InvocationPruner struct {
+	service   *InvocationService
+	retention time.Duration
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewInvocationPruner 创建调用记录清理器，retention 不大于 0 时不清理
+func NewInvocationPruner(service *InvocationService, retention time.Duration) *InvocationPruner {
+	return &InvocationPruner{service: service, retention: retention}
+}
+
+// Start 立即清理一次，之后定期清理，直到 ctx 取消或调用 Stop
+func (p *InvocationPruner) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.done = make(chan struct{})
+	go p.run(ctx)
+}
+
+// Stop 停止清理并等待正在进行的清理完成，ctx 到期时不再等待
+func (p *InvocationPruner) Stop(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run 清理循环
+func (p *InvocationPruner) run(ctx context.Context) {
+	defer close(p.done)
+	if p.retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(invocationPruneInterval)
+	defer ticker.Stop()
+
+	p.prune()
+	for {
+		select {
+		case <-ticker.C:
+			p.prune()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// prune 删除超过保留时长的已结束调用记录
+func (p *InvocationPruner) prune() {
+	removed, err := p.service.PruneFinished(time.Now().Add(-p.retention))
+	if err != nil {
+		log.Printf("Failed to prune invocations: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Pruned %d finished invocations", removed)
+	}
+}
@@ -0,0 +1,222 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"uros-restron/internal/events"
+
+	"gorm.io/gorm"
+)
+
+// 发件箱分发参数
+const (
+	outboxBatchSize     = 100
+	outboxRetryInterval = 5 * time.Second // 读取或标记失败后重试，也用于发布其他进程写入的事件
+	outboxPruneInterval = 10 * time.Minute
+)
+
+// OutboxEvent 发件箱中的变更事件，与 Thing 或关系的变更在同一事务中写入
+// 自增ID即提交顺序：SQLite 串行化写事务，先写入发件箱的事务先提交
+type OutboxEvent struct {
+	ID          uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type        string     `json:"type" gorm:"not null"`
+	Aggregate   string     `json:"aggregate" gorm:"not null"`
+	AggregateID string     `json:"aggregateId" gorm:"not null;index"`
+	ThingID     string     `json:"thingId,omitempty" gorm:"index"`
+	PayloadJSON string     `json:"-" gorm:"column:payload;type:text"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty" gorm:"index"`
+}
+
+// Event 转换为领域事件，数据为写入时的 JSON
+func (e *OutboxEvent) Event() events.Event {
+	return events.Event{
+		Type:      events.Type(e.Type),
+		Aggregate: e.Aggregate,
+		ID:        e.AggregateID,
+		ThingID:   e.ThingID,
+		Data:      json.RawMessage(e.PayloadJSON),
+		Time:      e.CreatedAt,
+		Sequence:  e.ID,
+	}
+}
+
+// OutboxService 提供事务发件箱的写入和读取
+type OutboxService struct {
+	db     *gorm.DB
+	notify chan struct{} // 有事务提交时唤醒分发器
+}
+
+// NewOutboxService 创建发件箱服务
+func NewOutboxService(db *gorm.DB) *OutboxService {
+	return &OutboxService{db: db, notify: make(chan struct{}, 1)}
+}
+
+// Transaction 在事务中执行变更，变更返回的事件写入发件箱，提交后唤醒分发器
+// 变更没有影响任何记录时返回 nil 事件；任何一步失败都会回滚变更和事件
+// 发件箱为 nil 时只执行变更
+func (s *OutboxService) Transaction(db *gorm.DB, change func(tx *gorm.DB) (*events.Event, error)) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		event, err := change(tx)
+		if err != nil || event == nil || s == nil {
+			return err
+		}
+		return s.append(tx, *event)
+	})
+	if err != nil || s == nil {
+		return err
+	}
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// append 在事务中写入事件
+func (s *OutboxService) append(tx *gorm.DB, event events.Event) error {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %v", event.Type, err)
+	}
+	entry := &OutboxEvent{
+		Type:        string(event.Type),
+		Aggregate:   event.Aggregate,
+		AggregateID: event.ID,
+		ThingID:     event.ThingID,
+		PayloadJSON: string(payload),
+		CreatedAt:   time.Now(),
+	}
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to write %s event to outbox: %v", event.Type, err)
+	}
+	return nil
+}
+
+// Pending 按提交顺序返回序号大于 after 的未发布事件
+func (s *OutboxService) Pending(after uint64, limit int) ([]OutboxEvent, error) {
+	var pending []OutboxEvent
+	err := s.db.Where("published_at IS NULL AND id > ?", after).Order("id").Limit(limit).Find(&pending).Error
+	return pending, err
+}
+
+// MarkPublished 把序号不大于 upto 的未发布事件标记为已发布
+func (s *OutboxService) MarkPublished(upto uint64, publishedAt time.Time) error {
+	return s.db.Model(&OutboxEvent{}).Where("published_at IS NULL AND id <= ?", upto).Update("published_at", publishedAt).Error
+}
+
+// PrunePublished 删除在 before 之前发布的事件，返回删除的数量
+func (s *OutboxService) PrunePublished(before time.Time) (int64, error) {
+	result := s.db.Where("published_at IS NOT NULL AND published_at < ?", before).Delete(&OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// OutboxDispatcher 在单个 goroutine 中按提交顺序把发件箱中的事件发布到事件总线
+// 事件发布后才标记为已发布，进程在两者之间退出时，重启后会再次发布这些事件（至少一次），
+// 订阅者可以用事件的 Sequence 去重
+type OutboxDispatcher struct {
+	outbox    *OutboxService
+	bus       *events.Bus
+	retention time.Duration // 已发布事件的保留时长，不大于 0 时不清理
+	last      uint64        // 最后发布的序号，只在分发 goroutine 中访问
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewOutboxDispatcher 创建发件箱分发器
+func NewOutboxDispatcher(outbox *OutboxService, bus *events.Bus, retention time.Duration) *OutboxDispatcher {
+	return &OutboxDispatcher{outbox: outbox, bus: bus, retention: retention}
+}
+
+// Start 先发布上次退出前未发布的事件，之后在事务提交时发布，ctx 取消后停止
+// 需要在事件总线的订阅者就绪之后调用
+func (d *OutboxDispatcher) Start(ctx context.Context) {
+	ctx, d.cancel = context.WithCancel(ctx)
+	d.done = make(chan struct{})
+	go d.run(ctx)
+}
+
+// Stop 停止分发并等待正在进行的发布完成，ctx 到期时不再等待
+// 订阅者（例如 WebSocket Hub）应在之后停止；未发布的事件在下次启动时发布
+func (d *OutboxDispatcher) Stop(ctx context.Context) error {
+	if d.cancel == nil {
+		return nil
+	}
+	d.cancel()
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run 分发循环
+func (d *OutboxDispatcher) run(ctx context.Context) {
+	defer close(d.done)
+	retry := time.NewTicker(outboxRetryInterval)
+	defer retry.Stop()
+	prune := time.NewTicker(outboxPruneInterval)
+	defer prune.Stop()
+
+	d.dispatch()
+	d.prune()
+	for {
+		select {
+		case <-d.outbox.notify:
+			d.dispatch()
+		case <-retry.C:
+			d.dispatch()
+		case <-prune.C:
+			d.prune()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dispatch 发布所有未发布的事件，读取失败时等待下次重试
+// 标记失败不影响发布顺序，之后的标记会覆盖这些事件
+func (d *OutboxDispatcher) dispatch() {
+	for {
+		pending, err := d.outbox.Pending(d.last, outboxBatchSize)
+		if err != nil {
+			log.Printf("Failed to read outbox: %v", err)
+			return
+		}
+		if len(pending) == 0 {
+			return
+		}
+
+		for i := range pending {
+			d.bus.Publish(pending[i].Event())
+			d.last = pending[i].ID
+		}
+		if err := d.outbox.MarkPublished(d.last, time.Now()); err != nil {
+			log.Printf("Failed to mark outbox events up to %d as published: %v", d.last, err)
+		}
+		if len(pending) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// prune 删除超过保留时长的已发布事件
+func (d *OutboxDispatcher) prune() {
+	if d.retention <= 0 {
+		return
+	}
+	removed, err := d.outbox.PrunePublished(time.Now().Add(-d.retention))
+	if err != nil {
+		log.Printf("Failed to prune outbox: %v", err)
+		return
+	}
+	if removed > 0 {
+		log.Printf("Pruned %d published outbox events", removed)
+	}
+}
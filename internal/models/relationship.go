@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"time"
 
+	"uros-restron/internal/events"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -44,7 +46,8 @@ type Relationship struct {
 
 // RelationshipService 关系服务
 type RelationshipService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	outbox *OutboxService // 变更事件发件箱，未设置时不记录事件
 }
 
 // NewRelationshipService 创建关系服务
@@ -52,6 +55,22 @@ func NewRelationshipService(db *gorm.DB) *RelationshipService {
 	return &RelationshipService{db: db}
 }
 
+// SetOutbox 设置变更事件发件箱，关系事件与变更在同一事务中写入
+func (s *RelationshipService) SetOutbox(outbox *OutboxService) {
+	s.outbox = outbox
+}
+
+// relationshipEvent 生成关系事件，事件的 Thing 为关系的源 Thing
+func relationshipEvent(eventType events.Type, relationship *Relationship) *events.Event {
+	return &events.Event{
+		Type:      eventType,
+		Aggregate: events.AggregateRelationship,
+		ID:        relationship.ID,
+		ThingID:   relationship.SourceID,
+		Data:      relationship,
+	}
+}
+
 // CreateRelationship 创建关系
 func (s *RelationshipService) CreateRelationship(relationship *Relationship) error {
 	if relationship.ID == "" {
@@ -69,13 +88,23 @@ func (s *RelationshipService) CreateRelationship(relationship *Relationship) err
 		relationship.PropertiesJSON = string(data)
 	}
 
-	return s.db.Create(relationship).Error
+	return s.outbox.Transaction(s.db, func(tx *gorm.DB) (*events.Event, error) {
+		if err := tx.Create(relationship).Error; err != nil {
+			return nil, err
+		}
+		return relationshipEvent(events.RelationshipCreated, relationship), nil
+	})
 }
 
 // GetRelationship 获取单个关系
 func (s *RelationshipService) GetRelationship(id string) (*Relationship, error) {
+	return s.getRelationship(s.db, id)
+}
+
+// getRelationship 使用指定的连接或事务读取关系
+func (s *RelationshipService) getRelationship(db *gorm.DB, id string) (*Relationship, error) {
 	var relationship Relationship
-	err := s.db.Preload("Source").Preload("Target").First(&relationship, "id = ?", id).Error
+	err := db.Preload("Source").Preload("Target").First(&relationship, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -139,12 +168,40 @@ func (s *RelationshipService) UpdateRelationship(id string, updates map[string]i
 		}
 	}
 
-	return s.db.Model(&Relationship{}).Where("id = ?", id).Updates(updates).Error
+	return s.outbox.Transaction(s.db, func(tx *gorm.DB) (*events.Event, error) {
+		result := tx.Model(&Relationship{}).Where("id = ?", id).Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return nil, result.Error
+		}
+		relationship, err := s.getRelationship(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		return relationshipEvent(events.RelationshipUpdated, relationship), nil
+	})
 }
 
-// DeleteRelationship 删除关系
+// DeleteRelationship 删除关系，事件携带删除前的关系
+// 关系在事务之前读取，使事务以写操作开始，避免 SQLite 读锁升级时与其他写事务死锁
 func (s *RelationshipService) DeleteRelationship(id string) error {
-	return s.db.Delete(&Relationship{}, "id = ?", id).Error
+	var relationship Relationship
+	if err := s.db.First(&relationship, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+	if relationship.PropertiesJSON != "" {
+		json.Unmarshal([]byte(relationship.PropertiesJSON), &relationship.Properties)
+	}
+
+	return s.outbox.Transaction(s.db, func(tx *gorm.DB) (*events.Event, error) {
+		result := tx.Delete(&Relationship{}, "id = ?", id)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return nil, result.Error
+		}
+		return relationshipEvent(events.RelationshipDeleted, &relationship), nil
+	})
 }
 
 // GetThingRelationships 获取事物的所有关系
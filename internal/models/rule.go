@@ -0,0 +1,247 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 规则动作类型
+const (
+	RuleActionInvoke      = "invoke"       // 异步调用 Actor 函数
+	RuleActionUpdateState = "update_state" // 更新 Thing 属性
+	RuleActionNotify      = "notify"       // 广播通知
+)
+
+// 规则评估结果
+const (
+	RuleOutcomeNotMatched = "not_matched" // 条件不成立
+	RuleOutcomePending    = "pending"     // 条件成立，等待防抖时间
+	RuleOutcomeFired      = "fired"       // 触发并执行动作
+	RuleOutcomeActive     = "active"      // 已触发且尚未解除，不重复执行
+	RuleOutcomeCleared    = "cleared"     // 解除条件成立，规则可以再次触发
+	RuleOutcomeError      = "error"       // 条件求值或状态加载失败
+)
+
+// Rule 事件-条件-动作规则
+// 订阅 Thing 变更事件，条件由不成立变为成立时触发动作，之后直到解除条件成立才会再次触发
+type Rule struct {
+	ID          string `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     *bool  `json:"enabled" gorm:"default:true"`
+
+	Events     []string `json:"events,omitempty" gorm:"-"` // 订阅的事件类型，为空时订阅 Thing 创建、更新和状态变更
+	EventsJSON string   `json:"-" gorm:"column:events;type:text"`
+	ThingID    string   `json:"thingId,omitempty" gorm:"index"` // 只处理指定 Thing 的事件
+	ThingType  string   `json:"thingType,omitempty"`            // 只处理指定类型 Thing 的事件
+
+	Condition      string `json:"condition"`                // 触发条件，可引用 thing、related 和 event
+	ClearCondition string `json:"clearCondition,omitempty"` // 解除条件，为空时条件不成立即解除，用于设置回差
+	DebounceMs     int    `json:"debounceMs,omitempty"`     // 条件需要持续成立的时间
+
+	Actions     []RuleAction `json:"actions" gorm:"-"`
+	ActionsJSON string       `json:"-" gorm:"column:actions;type:text"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// RuleAction 规则触发时执行的动作
+type RuleAction struct {
+	Type string `json:"type"`
+
+	// invoke：调用 Actor 函数，inputs 中的表达式求值后覆盖 params
+	ActorID  string                 `json:"actorId,omitempty"`
+	Function string                 `json:"function,omitempty"`
+	Params   map[string]interface{} `json:"params,omitempty"`
+	Inputs   map[string]string      `json:"inputs,omitempty"`
+
+	// invoke、update_state：目标 Thing，为空时为触发事件的 Thing
+	ThingID string `json:"thingId,omitempty"`
+
+	// update_state：属性名到表达式的映射
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	// notify：通知内容
+	Message string `json:"message,omitempty"`
+	Level   string `json:"level,omitempty"`
+}
+
+// IsEnabled 规则是否启用，未设置时默认启用
+func (r *Rule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// BeforeSave GORM hook for serializing data before save
+func (r *Rule) BeforeSave(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	if r.Events != nil {
+		data, err := json.Marshal(r.Events)
+		if err != nil {
+			return err
+		}
+		r.EventsJSON = string(data)
+	}
+	if r.Actions != nil {
+		data, err := json.Marshal(r.Actions)
+		if err != nil {
+			return err
+		}
+		r.ActionsJSON = string(data)
+	}
+	return nil
+}
+
+// AfterFind GORM hook for deserializing data after retrieval
+func (r *Rule) AfterFind(tx *gorm.DB) error {
+	if r.EventsJSON != "" {
+		if err := json.Unmarshal([]byte(r.EventsJSON), &r.Events); err != nil {
+			return err
+		}
+	}
+	if r.ActionsJSON != "" {
+		if err := json.Unmarshal([]byte(r.ActionsJSON), &r.Actions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RuleActionResult 规则动作的执行结果
+type RuleActionResult struct {
+	Type         string `json:"type"`
+	ThingID      string `json:"thingId,omitempty"`
+	InvocationID string `json:"invocationId,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// RuleEvaluation 一次规则评估的记录
+type RuleEvaluation struct {
+	ID          string             `json:"id" gorm:"primaryKey"`
+	RuleID      string             `json:"ruleId" gorm:"index"`
+	ThingID     string             `json:"thingId" gorm:"index"`
+	Event       string             `json:"event"`
+	Outcome     string             `json:"outcome" gorm:"index"`
+	Error       string             `json:"error,omitempty"`
+	Actions     []RuleActionResult `json:"actions,omitempty" gorm:"-"`
+	ActionsJSON string             `json:"-" gorm:"column:actions;type:text"`
+	CreatedAt   time.Time          `json:"createdAt" gorm:"index"`
+}
+
+// BeforeSave GORM hook for serializing data before save
+func (e *RuleEvaluation) BeforeSave(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	if e.Actions == nil {
+		return nil
+	}
+	data, err := json.Marshal(e.Actions)
+	if err != nil {
+		return err
+	}
+	e.ActionsJSON = string(data)
+	return nil
+}
+
+// AfterFind GORM hook for deserializing data after retrieval
+func (e *RuleEvaluation) AfterFind(tx *gorm.DB) error {
+	if e.ActionsJSON == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(e.ActionsJSON), &e.Actions)
+}
+
+// RuleService 提供规则和评估记录的存储和查询
+type RuleService struct {
+	db *gorm.DB
+}
+
+// NewRuleService 创建规则服务
+func NewRuleService(db *gorm.DB) *RuleService {
+	return &RuleService{db: db}
+}
+
+// CreateRule 创建规则
+func (s *RuleService) CreateRule(rule *Rule) error {
+	return s.db.Create(rule).Error
+}
+
+// GetRule 根据ID获取规则
+func (s *RuleService) GetRule(id string) (*Rule, error) {
+	var rule Rule
+	if err := s.db.First(&rule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListRules 获取规则列表，thingID 为空时不过滤
+func (s *RuleService) ListRules(thingID string) ([]Rule, error) {
+	query := s.db.Model(&Rule{})
+	if thingID != "" {
+		query = query.Where("thing_id = ?", thingID)
+	}
+
+	var rules []Rule
+	if err := query.Order("created_at").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ListEnabledRules 获取所有启用的规则
+func (s *RuleService) ListEnabledRules() ([]Rule, error) {
+	var rules []Rule
+	if err := s.db.Where("enabled IS NULL OR enabled = ?", true).Order("created_at").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// UpdateRule 保存规则
+func (s *RuleService) UpdateRule(rule *Rule) error {
+	return s.db.Save(rule).Error
+}
+
+// DeleteRule 删除规则及其评估记录
+func (s *RuleService) DeleteRule(id string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&RuleEvaluation{}, "rule_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Rule{}, "id = ?", id).Error
+	})
+}
+
+// SaveEvaluation 保存评估记录
+func (s *RuleService) SaveEvaluation(evaluation *RuleEvaluation) error {
+	return s.db.Create(evaluation).Error
+}
+
+// ListEvaluations 按时间倒序查询规则的评估记录，返回记录和总数
+func (s *RuleService) ListEvaluations(ruleID, thingID, outcome string, limit, offset int) ([]RuleEvaluation, int64, error) {
+	query := s.db.Model(&RuleEvaluation{}).Where("rule_id = ?", ruleID)
+	if thingID != "" {
+		query = query.Where("thing_id = ?", thingID)
+	}
+	if outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var evaluations []RuleEvaluation
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&evaluations).Error; err != nil {
+		return nil, 0, err
+	}
+	return evaluations, total, nil
+}
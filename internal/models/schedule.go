@@ -0,0 +1,163 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MissedRunPolicy 服务停止期间错过执行时间的处理策略
+type MissedRunPolicy string
+
+const (
+	MissedRunSkip    MissedRunPolicy = "skip"     // 忽略错过的执行
+	MissedRunOnce    MissedRunPolicy = "run_once" // 启动后补执行一次
+	MissedRunAll     MissedRunPolicy = "run_all"  // 补执行每一次错过的执行
+	DefaultMissedRun                 = MissedRunOnce
+)
+
+// Schedule 定时调用 Actor 函数的计划
+// Cron 和 IntervalSeconds 二选一，Cron 支持标准 5 段、可选秒的 6 段和 @daily、@every 1h 等写法
+type Schedule struct {
+	ID              string                 `json:"id" gorm:"primaryKey"`
+	Name            string                 `json:"name"`
+	Description     string                 `json:"description"`
+	ActorID         string                 `json:"actorId" gorm:"not null;index"`
+	ThingID         string                 `json:"thingId,omitempty" gorm:"index"` // 函数执行针对的 Thing，可选
+	Function        string                 `json:"function" gorm:"not null"`
+	Params          map[string]interface{} `json:"params" gorm:"-"`
+	ParamsJSON      string                 `json:"-" gorm:"column:params;type:text"`
+	Cron            string                 `json:"cron,omitempty"`
+	IntervalSeconds int                    `json:"intervalSeconds,omitempty"`
+	JitterSeconds   int                    `json:"jitterSeconds,omitempty"` // 每次执行随机延后 0 到 JitterSeconds 秒
+	MissedRunPolicy MissedRunPolicy        `json:"missedRunPolicy"`
+	Enabled         *bool                  `json:"enabled" gorm:"default:true"`
+
+	NextRunAt        *time.Time       `json:"nextRunAt,omitempty"`
+	LastRunAt        *time.Time       `json:"lastRunAt,omitempty"`
+	LastStatus       InvocationStatus `json:"lastStatus,omitempty"`
+	LastError        string           `json:"lastError,omitempty"`
+	LastInvocationID string           `json:"lastInvocationId,omitempty"`
+	RunCount         int64            `json:"runCount"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// IsEnabled 计划是否启用，未设置时默认启用
+func (s *Schedule) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// BeforeSave GORM hook for serializing data before save
+func (s *Schedule) BeforeSave(tx *gorm.DB) error {
+	if s.Params == nil {
+		return nil
+	}
+	data, err := json.Marshal(s.Params)
+	if err != nil {
+		return err
+	}
+	s.ParamsJSON = string(data)
+	return nil
+}
+
+// AfterFind GORM hook for deserializing data after retrieval
+func (s *Schedule) AfterFind(tx *gorm.DB) error {
+	if s.ParamsJSON == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s.ParamsJSON), &s.Params)
+}
+
+// ScheduleRun 一次计划执行的结果
+type ScheduleRun struct {
+	RunAt        time.Time
+	Status       InvocationStatus
+	Error        string
+	InvocationID string
+}
+
+// ScheduleService 提供计划的存储和查询
+type ScheduleService struct {
+	db *gorm.DB
+}
+
+// NewScheduleService 创建计划服务
+func NewScheduleService(db *gorm.DB) *ScheduleService {
+	return &ScheduleService{db: db}
+}
+
+// CreateSchedule 创建计划
+func (s *ScheduleService) CreateSchedule(schedule *Schedule) error {
+	if schedule.ID == "" {
+		schedule.ID = uuid.New().String()
+	}
+	return s.db.Create(schedule).Error
+}
+
+// GetSchedule 根据ID获取计划
+func (s *ScheduleService) GetSchedule(id string) (*Schedule, error) {
+	var schedule Schedule
+	if err := s.db.First(&schedule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListSchedules 获取计划列表，actorID 和 thingID 为空时不过滤
+func (s *ScheduleService) ListSchedules(actorID, thingID string) ([]Schedule, error) {
+	query := s.db.Model(&Schedule{})
+	if actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if thingID != "" {
+		query = query.Where("thing_id = ?", thingID)
+	}
+
+	var schedules []Schedule
+	if err := query.Order("created_at").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// ListEnabledSchedules 获取所有启用的计划
+func (s *ScheduleService) ListEnabledSchedules() ([]Schedule, error) {
+	var schedules []Schedule
+	if err := s.db.Where("enabled IS NULL OR enabled = ?", true).Order("created_at").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// UpdateSchedule 保存计划配置，执行状态字段保持不变
+func (s *ScheduleService) UpdateSchedule(schedule *Schedule) error {
+	return s.db.Model(schedule).
+		Select("name", "description", "actor_id", "thing_id", "function", "params", "cron",
+			"interval_seconds", "jitter_seconds", "missed_run_policy", "enabled", "next_run_at", "updated_at").
+		Updates(schedule).Error
+}
+
+// SetNextRun 记录下一次执行时间
+func (s *ScheduleService) SetNextRun(id string, nextRunAt *time.Time) error {
+	return s.db.Model(&Schedule{}).Where("id = ?", id).Update("next_run_at", nextRunAt).Error
+}
+
+// RecordRun 记录一次执行的结果
+func (s *ScheduleService) RecordRun(id string, run ScheduleRun) error {
+	return s.db.Model(&Schedule{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_run_at":        run.RunAt,
+		"last_status":        run.Status,
+		"last_error":         run.Error,
+		"last_invocation_id": run.InvocationID,
+		"run_count":          gorm.Expr("run_count + 1"),
+	}).Error
+}
+
+// DeleteSchedule 删除计划
+func (s *ScheduleService) DeleteSchedule(id string) error {
+	return s.db.Delete(&Schedule{}, "id = ?", id).Error
+}
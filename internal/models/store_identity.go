@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StoreIdentity 数据库的标识，第一次启动时生成
+// 集群节点在探测时比较标识，确认彼此使用同一个数据库
+type StoreIdentity struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	StoreID   string    `json:"storeId" gorm:"not null"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// EnsureStoreID 返回数据库的标识，不存在时生成；多个进程同时启动时得到相同的标识
+func EnsureStoreID(db *gorm.DB) (string, error) {
+	candidate := StoreIdentity{ID: 1, StoreID: uuid.New().String(), CreatedAt: time.Now()}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&candidate).Error; err != nil {
+		return "", err
+	}
+
+	var identity StoreIdentity
+	if err := db.First(&identity, 1).Error; err != nil {
+		return "", err
+	}
+	return identity.StoreID, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"uros-restron/internal/events"
 	"uros-restron/internal/utils"
 
 	"github.com/google/uuid"
@@ -32,6 +33,7 @@ type Thing struct {
 type ThingService struct {
 	db        *gorm.DB
 	jsonUtils *utils.JSONUtils
+	outbox    *OutboxService // 变更事件发件箱，未设置时不记录事件
 }
 
 // NewThingService 创建新的 ThingService
@@ -42,6 +44,20 @@ func NewThingService(db *gorm.DB) *ThingService {
 	}
 }
 
+// SetOutbox 设置变更事件发件箱，Thing 事件与变更在同一事务中写入
+func (s *ThingService) SetOutbox(outbox *OutboxService) {
+	s.outbox = outbox
+}
+
+// thingEvent 在事务中重新读取 Thing 并生成事件，读取失败时只携带ID
+func (s *ThingService) thingEvent(tx *gorm.DB, eventType events.Type, id string) *events.Event {
+	var data interface{} = map[string]interface{}{"id": id}
+	if thing, err := s.getThing(tx, id); err == nil {
+		data = thing
+	}
+	return &events.Event{Type: eventType, Aggregate: events.AggregateThing, ID: id, ThingID: id, Data: data}
+}
+
 // CreateThing 创建新的数字孪生
 func (s *ThingService) CreateThing(thing *Thing) error {
 	if thing.ID == "" {
@@ -68,14 +84,24 @@ func (s *ThingService) CreateThing(thing *Thing) error {
 	}
 	thing.FeaturesJSON = featuresJSON
 
-	return s.db.Create(thing).Error
+	return s.outbox.Transaction(s.db, func(tx *gorm.DB) (*events.Event, error) {
+		if err := tx.Create(thing).Error; err != nil {
+			return nil, err
+		}
+		return &events.Event{Type: events.ThingCreated, Aggregate: events.AggregateThing, ID: thing.ID, ThingID: thing.ID, Data: thing}, nil
+	})
 }
 
 
 // GetThing 根据ID获取数字孪生
 func (s *ThingService) GetThing(id string) (*Thing, error) {
+	return s.getThing(s.db, id)
+}
+
+// getThing 使用指定的连接或事务读取数字孪生
+func (s *ThingService) getThing(db *gorm.DB, id string) (*Thing, error) {
 	var thing Thing
-	err := s.db.First(&thing, "id = ?", id).Error
+	err := db.First(&thing, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -97,6 +123,22 @@ func (s *ThingService) GetThing(id string) (*Thing, error) {
 	return &thing, nil
 }
 
+// GetThingState 获取数字孪生的当前状态，供行为函数执行时引用
+func (s *ThingService) GetThingState(id string) (map[string]interface{}, error) {
+	thing, err := s.GetThing(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"id":         thing.ID,
+		"name":       thing.Name,
+		"type":       thing.Type,
+		"attributes": thing.Attributes,
+		"features":   thing.Features,
+	}, nil
+}
+
 // ListThings 获取所有数字孪生
 func (s *ThingService) ListThings(thingType string, limit, offset int) ([]Thing, error) {
 	var things []Thing
@@ -155,12 +197,29 @@ func (s *ThingService) UpdateThing(id string, updates map[string]interface{}) er
 		}
 	}
 
-	return s.db.Model(&Thing{}).Where("id = ?", id).Updates(updates).Error
+	return s.outbox.Transaction(s.db, func(tx *gorm.DB) (*events.Event, error) {
+		result := tx.Model(&Thing{}).Where("id = ?", id).Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return nil, result.Error
+		}
+		return s.thingEvent(tx, events.ThingUpdated, id), nil
+	})
 }
 
 // DeleteThing 删除数字孪生
 func (s *ThingService) DeleteThing(id string) error {
-	return s.db.Delete(&Thing{}, "id = ?", id).Error
+	return s.outbox.Transaction(s.db, func(tx *gorm.DB) (*events.Event, error) {
+		// 删除后无法再查询类型，写入事件供按类型订阅的客户端过滤
+		var thingType string
+		if err := tx.Model(&Thing{}).Select("type").Where("id = ?", id).Scan(&thingType).Error; err != nil {
+			return nil, err
+		}
+		result := tx.Delete(&Thing{}, "id = ?", id)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return nil, result.Error
+		}
+		return &events.Event{Type: events.ThingDeleted, Aggregate: events.AggregateThing, ID: id, ThingID: id, Data: map[string]interface{}{"id": id, "type": thingType}}, nil
+	})
 }
 
 
@@ -171,20 +230,41 @@ func (s *ThingService) UpdateStatus(thingID string, status map[string]interface{
 		return err
 	}
 
-	return s.db.Model(&Thing{}).Where("id = ?", thingID).Updates(map[string]interface{}{
-		"status":     string(statusJSON),
-		"updated_at": time.Now(),
-	}).Error
+	return s.outbox.Transaction(s.db, func(tx *gorm.DB) (*events.Event, error) {
+		result := tx.Model(&Thing{}).Where("id = ?", thingID).Updates(map[string]interface{}{
+			"status":     string(statusJSON),
+			"updated_at": time.Now(),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return nil, result.Error
+		}
+		data := map[string]interface{}{"thingId": thingID, "status": status}
+		if thing, err := s.getThing(tx, thingID); err == nil {
+			data["thing"] = thing
+		}
+		return &events.Event{Type: events.ThingStatusUpdated, Aggregate: events.AggregateThing, ID: thingID, ThingID: thingID, Data: data}, nil
+	})
 }
 
 // SetBehavior 为事物设置行为
 func (s *ThingService) SetBehavior(thingID, behaviorID string) error {
-	return s.db.Model(&Thing{}).Where("id = ?", thingID).Update("behavior_id", behaviorID).Error
+	return s.updateBehavior(thingID, behaviorID)
 }
 
 // RemoveBehavior 从事物中移除行为
 func (s *ThingService) RemoveBehavior(thingID string) error {
-	return s.db.Model(&Thing{}).Where("id = ?", thingID).Update("behavior_id", nil).Error
+	return s.updateBehavior(thingID, nil)
+}
+
+// updateBehavior 更新事物的行为并记录 thing_updated
+func (s *ThingService) updateBehavior(thingID string, behaviorID interface{}) error {
+	return s.outbox.Transaction(s.db, func(tx *gorm.DB) (*events.Event, error) {
+		result := tx.Model(&Thing{}).Where("id = ?", thingID).Update("behavior_id", behaviorID)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return nil, result.Error
+		}
+		return s.thingEvent(tx, events.ThingUpdated, thingID), nil
+	})
 }
 
 // GetThingBehavior 获取事物的行为
@@ -223,6 +303,6 @@ func (s *ThingService) AssignBehaviorByType(thingID, thingType string) error {
 	}
 
 	// 分配行为
-	return s.db.Model(&thing).Update("behavior_id", behavior.ID).Error
+	return s.updateBehavior(thing.ID, behavior.ID)
 }
 
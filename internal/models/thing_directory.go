@@ -0,0 +1,56 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ThingDirectory 按 Thing 和关系查找消息接收方
+type ThingDirectory struct {
+	db *gorm.DB
+}
+
+// NewThingDirectory 创建 Thing 目录
+func NewThingDirectory(db *gorm.DB) *ThingDirectory {
+	return &ThingDirectory{db: db}
+}
+
+// ThingBehaviorID 返回 Thing 关联的行为ID，未关联行为时为空
+func (d *ThingDirectory) ThingBehaviorID(thingID string) (string, error) {
+	var thing Thing
+	if err := d.db.Select("id", "behavior_id").First(&thing, "id = ?", thingID).Error; err != nil {
+		return "", err
+	}
+	return thing.BehaviorID, nil
+}
+
+// RelatedThingIDs 返回与 Thing 有指定类型关系的 Thing ID
+// direction 为 outgoing 时查找以该 Thing 为源的关系，incoming 查找以其为目标的关系，both 两者都查
+func (d *ThingDirectory) RelatedThingIDs(thingID, relationshipType, direction string) ([]string, error) {
+	var relationships []Relationship
+	query := d.db.Where("type = ?", relationshipType)
+	switch direction {
+	case DirectionIncoming:
+		query = query.Where("target_id = ?", thingID)
+	case DirectionBoth:
+		query = query.Where("source_id = ? OR target_id = ?", thingID, thingID)
+	default:
+		query = query.Where("source_id = ?", thingID)
+	}
+	if err := query.Order("created_at").Find(&relationships).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(relationships))
+	seen := make(map[string]bool, len(relationships))
+	for _, relationship := range relationships {
+		id := relationship.TargetID
+		if id == thingID {
+			id = relationship.SourceID
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
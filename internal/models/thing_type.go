@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"time"
 
+	"uros-restron/internal/events"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -81,7 +83,8 @@ func (t *ThingType) deserializeData() error {
 
 // ThingTypeService 提供事物类型相关的业务逻辑
 type ThingTypeService struct {
-	db *gorm.DB
+	db  *gorm.DB
+	bus *events.Bus // 变更事件总线，未设置时不发布事件
 }
 
 // NewThingTypeService 创建新的 ThingTypeService
@@ -89,6 +92,23 @@ func NewThingTypeService(db *gorm.DB) *ThingTypeService {
 	return &ThingTypeService{db: db}
 }
 
+// SetEventBus 设置变更事件总线，创建、更新和删除成功后发布 ThingType 事件
+func (s *ThingTypeService) SetEventBus(bus *events.Bus) {
+	s.bus = bus
+}
+
+// publishThingType 重新读取事物类型并发布事件，读取失败时只携带ID
+func (s *ThingTypeService) publishThingType(eventType events.Type, id string) {
+	if s.bus == nil {
+		return
+	}
+	var data interface{} = map[string]interface{}{"id": id}
+	if thingType, err := s.GetThingType(id); err == nil {
+		data = thingType
+	}
+	s.bus.Publish(events.Event{Type: eventType, Aggregate: events.AggregateThingType, ID: id, Data: data})
+}
+
 // CreateThingType 创建新的事物类型
 func (s *ThingTypeService) CreateThingType(thingType *ThingType) error {
 	if thingType.ID == "" {
@@ -114,7 +134,11 @@ func (s *ThingTypeService) CreateThingType(thingType *ThingType) error {
 		thingType.FeaturesJSON = string(data)
 	}
 
-	return s.db.Create(thingType).Error
+	if err := s.db.Create(thingType).Error; err != nil {
+		return err
+	}
+	s.bus.Publish(events.Event{Type: events.ThingTypeCreated, Aggregate: events.AggregateThingType, ID: thingType.ID, Data: thingType})
+	return nil
 }
 
 // GetThingType 根据ID获取事物类型
@@ -179,12 +203,31 @@ func (s *ThingTypeService) ListThingTypes(category string, limit, offset int) ([
 // UpdateThingType 更新事物类型
 func (s *ThingTypeService) UpdateThingType(id string, updates map[string]interface{}) error {
 	updates["updated_at"] = time.Now()
-	return s.db.Model(&ThingType{}).Where("id = ?", id).Updates(updates).Error
+	return s.update(id, updates)
+}
+
+// update 更新事物类型并发布 thing_type_updated
+func (s *ThingTypeService) update(id string, updates map[string]interface{}) error {
+	result := s.db.Model(&ThingType{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		s.publishThingType(events.ThingTypeUpdated, id)
+	}
+	return nil
 }
 
 // DeleteThingType 删除事物类型
 func (s *ThingTypeService) DeleteThingType(id string) error {
-	return s.db.Delete(&ThingType{}, "id = ?", id).Error
+	result := s.db.Delete(&ThingType{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		s.bus.Publish(events.Event{Type: events.ThingTypeDeleted, Aggregate: events.AggregateThingType, ID: id, Data: map[string]interface{}{"id": id}})
+	}
+	return nil
 }
 
 // CreateThingFromType 根据类型创建事物实例
@@ -256,17 +299,17 @@ func (s *ThingTypeService) AssignDefaultBehavior(thingTypeID string) error {
 	}
 
 	// 分配行为
-	return s.db.Model(&thingType).Update("behavior_id", behavior.ID).Error
+	return s.update(thingType.ID, map[string]interface{}{"behavior_id": behavior.ID})
 }
 
 // SetBehaviorToType 为 ThingType 设置行为
 func (s *ThingTypeService) SetBehaviorToType(thingTypeID, behaviorID string) error {
-	return s.db.Model(&ThingType{}).Where("id = ?", thingTypeID).Update("behavior_id", behaviorID).Error
+	return s.update(thingTypeID, map[string]interface{}{"behavior_id": behaviorID})
 }
 
 // RemoveBehaviorFromType 从 ThingType 移除行为
 func (s *ThingTypeService) RemoveBehaviorFromType(thingTypeID string) error {
-	return s.db.Model(&ThingType{}).Where("id = ?", thingTypeID).Update("behavior_id", nil).Error
+	return s.update(thingTypeID, map[string]interface{}{"behavior_id": nil})
 }
 
 // GetTypeBehavior 获取 ThingType 的行为
@@ -0,0 +1,590 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"uros-restron/internal/actor"
+	"uros-restron/internal/events"
+	"uros-restron/internal/expr"
+	"uros-restron/internal/models"
+)
+
+// eventQueueSize 待评估事件队列长度，队列满时丢弃事件
+const eventQueueSize = 1024
+
+// ErrInvalidRule 规则配置无效
+var ErrInvalidRule = errors.New("invalid rule")
+
+// defaultEvents 规则未指定事件时订阅的 Thing 变更事件
+var defaultEvents = []string{"thing_created", "thing_updated", "status_updated"}
+
+// Broadcaster 推送规则产生的通知，Thing 变更由 ThingService 发布到事件总线
+type Broadcaster interface {
+	Broadcast(messageType string, data interface{})
+}
+
+// event 待评估的事件
+type event struct {
+	Type    string
+	Data    interface{}
+	ThingID string
+	RuleID  string // 防抖到期时只重新评估该规则
+}
+
+// ruleState 规则针对某个 Thing 的触发状态
+type ruleState struct {
+	active  bool
+	pending *time.Timer
+}
+
+// stopPending 取消等待中的防抖
+func (st *ruleState) stopPending() {
+	if st.pending != nil {
+		st.pending.Stop()
+		st.pending = nil
+	}
+}
+
+// Engine 规则引擎，订阅事件总线上的领域事件并在单个 goroutine 中按顺序评估
+// 触发状态只保存在内存中，服务重启后所有规则回到未触发状态
+type Engine struct {
+	ruleService         *models.RuleService
+	thingService        *models.ThingService
+	relationshipService *models.RelationshipService
+	actorManager        *actor.ActorManager
+	broadcaster         Broadcaster
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+	events     chan event
+	states     map[string]*ruleState
+	mu         sync.Mutex
+	conditions sync.Map // 表达式源码到 *expr.Expression 的缓存
+
+	// 启用的规则订阅的事件类型，HandleEvent 据此在入队前丢弃其他事件；为 nil 时表示尚未加载，不丢弃
+	subscribed   map[string]bool
+	subscribedMu sync.RWMutex
+	refreshMu    sync.Mutex // 串行化重新加载，后一次规则变更的结果不会被之前的加载覆盖
+}
+
+// NewEngine 创建规则引擎
+func NewEngine(ruleService *models.RuleService, thingService *models.ThingService, relationshipService *models.RelationshipService, actorManager *actor.ActorManager, broadcaster Broadcaster) *Engine {
+	return &Engine{
+		ruleService:         ruleService,
+		thingService:        thingService,
+		relationshipService: relationshipService,
+		actorManager:        actorManager,
+		broadcaster:         broadcaster,
+		events:              make(chan event, eventQueueSize),
+		states:              make(map[string]*ruleState),
+	}
+}
+
+// Start 加载规则订阅的事件类型并启动事件评估，ctx 取消或调用 Stop 后停止
+func (e *Engine) Start(ctx context.Context) {
+	e.refreshSubscriptions()
+	e.ctx = ctx
+	ctx, e.cancel = context.WithCancel(ctx)
+	e.done = make(chan struct{})
+	go e.run(ctx)
+}
+
+// Stop 停止事件评估并等待正在评估的事件完成，ctx 到期时不再等待
+// 队列中尚未评估的事件被丢弃，规则触发状态只保存在内存中
+func (e *Engine) Stop(ctx context.Context) error {
+	if e.cancel == nil {
+		return nil
+	}
+	e.cancel()
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HandleEvent 接收领域事件，可直接订阅事件总线
+// 不涉及 Thing 的事件和没有启用的规则订阅的事件（例如调用进度和 Actor 生命周期）不入队
+func (e *Engine) HandleEvent(domainEvent events.Event) {
+	if domainEvent.ThingID == "" || !e.subscribes(string(domainEvent.Type)) {
+		return
+	}
+	e.enqueue(event{Type: string(domainEvent.Type), Data: domainEvent.Data, ThingID: domainEvent.ThingID})
+}
+
+// CreateRule 校验并保存规则
+func (e *Engine) CreateRule(rule *models.Rule) error {
+	if err := e.Validate(rule); err != nil {
+		return err
+	}
+	if err := e.ruleService.CreateRule(rule); err != nil {
+		return fmt.Errorf("failed to create rule: %v", err)
+	}
+	e.refreshSubscriptions()
+	return nil
+}
+
+// UpdateRule 校验并保存规则，规则的触发状态被重置
+func (e *Engine) UpdateRule(rule *models.Rule) error {
+	if err := e.Validate(rule); err != nil {
+		return err
+	}
+	rule.UpdatedAt = time.Now()
+	if err := e.ruleService.UpdateRule(rule); err != nil {
+		return fmt.Errorf("failed to update rule: %v", err)
+	}
+	e.resetRule(rule.ID)
+	e.refreshSubscriptions()
+	return nil
+}
+
+// DeleteRule 删除规则及其评估记录
+func (e *Engine) DeleteRule(id string) error {
+	e.resetRule(id)
+	if err := e.ruleService.DeleteRule(id); err != nil {
+		return err
+	}
+	e.refreshSubscriptions()
+	return nil
+}
+
+// refreshSubscriptions 重新加载启用的规则订阅的事件类型，加载失败时保留之前的集合
+func (e *Engine) refreshSubscriptions() {
+	e.refreshMu.Lock()
+	defer e.refreshMu.Unlock()
+
+	rules, err := e.ruleService.ListEnabledRules()
+	if err != nil {
+		log.Printf("Failed to load rule subscriptions: %v", err)
+		return
+	}
+	subscribed := make(map[string]bool)
+	for i := range rules {
+		for _, eventType := range ruleEvents(&rules[i]) {
+			subscribed[eventType] = true
+		}
+	}
+
+	e.subscribedMu.Lock()
+	e.subscribed = subscribed
+	e.subscribedMu.Unlock()
+}
+
+// subscribes 判断是否有启用的规则订阅该事件，尚未加载时返回 true
+func (e *Engine) subscribes(eventType string) bool {
+	e.subscribedMu.RLock()
+	defer e.subscribedMu.RUnlock()
+	return e.subscribed == nil || e.subscribed[eventType]
+}
+
+// Validate 检查规则的条件表达式和动作
+func (e *Engine) Validate(rule *models.Rule) error {
+	if rule.Condition == "" {
+		return fmt.Errorf("%w: condition is required", ErrInvalidRule)
+	}
+	if _, err := e.parse(rule.Condition); err != nil {
+		return fmt.Errorf("%w: condition: %v", ErrInvalidRule, err)
+	}
+	if rule.ClearCondition != "" {
+		if _, err := e.parse(rule.ClearCondition); err != nil {
+			return fmt.Errorf("%w: clearCondition: %v", ErrInvalidRule, err)
+		}
+	}
+	if rule.DebounceMs < 0 {
+		return fmt.Errorf("%w: debounceMs must not be negative", ErrInvalidRule)
+	}
+	if len(rule.Actions) == 0 {
+		return fmt.Errorf("%w: at least one action is required", ErrInvalidRule)
+	}
+
+	for i, action := range rule.Actions {
+		if err := e.validateAction(action); err != nil {
+			return fmt.Errorf("%w: actions[%d]: %v", ErrInvalidRule, i, err)
+		}
+	}
+	return nil
+}
+
+// validateAction 检查单个动作
+func (e *Engine) validateAction(action models.RuleAction) error {
+	switch action.Type {
+	case models.RuleActionInvoke:
+		if action.ActorID == "" || action.Function == "" {
+			return fmt.Errorf("actorId and function are required")
+		}
+		target, err := e.actorManager.GetActor(action.ActorID)
+		if err != nil {
+			return err
+		}
+		behaviorActor, ok := target.(*actor.BehaviorActor)
+		if !ok {
+			return fmt.Errorf("actor %s is not a BehaviorActor", action.ActorID)
+		}
+		if _, err := behaviorActor.GetFunctionInfo(action.Function); err != nil {
+			return err
+		}
+		return e.validateExpressions("inputs", action.Inputs)
+	case models.RuleActionUpdateState:
+		if len(action.Attributes) == 0 {
+			return fmt.Errorf("attributes are required")
+		}
+		return e.validateExpressions("attributes", action.Attributes)
+	case models.RuleActionNotify:
+		if action.Message == "" {
+			return fmt.Errorf("message is required")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown action type %q", action.Type)
+	}
+}
+
+// validateExpressions 检查表达式映射
+func (e *Engine) validateExpressions(field string, expressions map[string]string) error {
+	for name, source := range expressions {
+		if _, err := e.parse(source); err != nil {
+			return fmt.Errorf("%s.%s: %v", field, name, err)
+		}
+	}
+	return nil
+}
+
+// enqueue 把事件放入评估队列，队列满时丢弃
+func (e *Engine) enqueue(ev event) {
+	select {
+	case e.events <- ev:
+	default:
+		log.Printf("Rule engine queue is full, dropping %s event", ev.Type)
+	}
+}
+
+// run 按顺序评估队列中的事件
+func (e *Engine) run(ctx context.Context) {
+	defer close(e.done)
+	for {
+		select {
+		case <-ctx.Done():
+			e.mu.Lock()
+			for _, st := range e.states {
+				st.stopPending()
+			}
+			e.mu.Unlock()
+			return
+		case ev := <-e.events:
+			e.process(ev)
+		}
+	}
+}
+
+// process 评估订阅了该事件的所有规则
+func (e *Engine) process(ev event) {
+	rules, err := e.ruleService.ListEnabledRules()
+	if err != nil {
+		log.Printf("Failed to load rules: %v", err)
+		return
+	}
+
+	thingID := ev.ThingID
+	var env expr.Env
+	for i := range rules {
+		rule := &rules[i]
+		if (ev.RuleID != "" && rule.ID != ev.RuleID) || !subscribes(rule, ev.Type) {
+			continue
+		}
+		if rule.ThingID != "" && rule.ThingID != thingID {
+			continue
+		}
+
+		if env == nil {
+			env = e.buildEnv(ev.Type, thingID)
+		}
+		if rule.ThingType != "" && thingType(env) != rule.ThingType {
+			continue
+		}
+
+		evaluation := e.evaluate(rule, ev, thingID, env)
+		if err := e.ruleService.SaveEvaluation(evaluation); err != nil {
+			log.Printf("Failed to record evaluation of rule %s: %v", rule.ID, err)
+		}
+	}
+}
+
+// evaluate 评估规则并在条件由不成立变为成立时执行动作
+func (e *Engine) evaluate(rule *models.Rule, ev event, thingID string, env expr.Env) *models.RuleEvaluation {
+	evaluation := &models.RuleEvaluation{
+		RuleID:    rule.ID,
+		ThingID:   thingID,
+		Event:     ev.Type,
+		CreatedAt: time.Now(),
+	}
+
+	matched, err := e.evalBool(rule.Condition, env)
+	if err != nil {
+		evaluation.Outcome = models.RuleOutcomeError
+		evaluation.Error = fmt.Sprintf("condition: %v", err)
+		return evaluation
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := e.state(rule.ID, thingID)
+
+	if st.active {
+		cleared := !matched
+		if rule.ClearCondition != "" {
+			if cleared, err = e.evalBool(rule.ClearCondition, env); err != nil {
+				evaluation.Outcome = models.RuleOutcomeError
+				evaluation.Error = fmt.Sprintf("clearCondition: %v", err)
+				return evaluation
+			}
+		}
+		if cleared {
+			st.active = false
+			evaluation.Outcome = models.RuleOutcomeCleared
+		} else {
+			evaluation.Outcome = models.RuleOutcomeActive
+		}
+		return evaluation
+	}
+
+	switch {
+	case !matched:
+		st.stopPending()
+		evaluation.Outcome = models.RuleOutcomeNotMatched
+	case rule.DebounceMs > 0 && ev.RuleID == "":
+		// 条件需要持续成立，到期后用最新状态重新评估；期间条件不成立会取消等待
+		if st.pending == nil {
+			retry := event{Type: ev.Type, ThingID: thingID, RuleID: rule.ID}
+			st.pending = time.AfterFunc(time.Duration(rule.DebounceMs)*time.Millisecond, func() {
+				e.enqueue(retry)
+			})
+		}
+		evaluation.Outcome = models.RuleOutcomePending
+	default:
+		st.pending = nil
+		st.active = true
+		evaluation.Outcome = models.RuleOutcomeFired
+		evaluation.Actions = e.runActions(rule, thingID, env)
+	}
+	return evaluation
+}
+
+// runActions 依次执行规则动作，单个动作失败不影响其余动作
+func (e *Engine) runActions(rule *models.Rule, thingID string, env expr.Env) []models.RuleActionResult {
+	results := make([]models.RuleActionResult, len(rule.Actions))
+	for i, action := range rule.Actions {
+		target := action.ThingID
+		if target == "" {
+			target = thingID
+		}
+		results[i] = models.RuleActionResult{Type: action.Type, ThingID: target}
+
+		var err error
+		switch action.Type {
+		case models.RuleActionInvoke:
+			results[i].InvocationID, err = e.invoke(rule, action, target, env)
+		case models.RuleActionUpdateState:
+			err = e.updateState(action, target, env)
+		case models.RuleActionNotify:
+			e.notify(rule, action, target, env)
+		default:
+			err = fmt.Errorf("unknown action type %q", action.Type)
+		}
+		if err != nil {
+			results[i].Error = err.Error()
+			log.Printf("Rule %s action %d (%s) failed: %v", rule.ID, i, action.Type, err)
+		}
+	}
+	return results
+}
+
+// invoke 异步调用 Actor 函数，返回调用ID
+func (e *Engine) invoke(rule *models.Rule, action models.RuleAction, thingID string, env expr.Env) (string, error) {
+	params := make(map[string]interface{}, len(action.Params)+len(action.Inputs))
+	for name, value := range action.Params {
+		params[name] = value
+	}
+	for name, source := range action.Inputs {
+		value, err := e.eval(source, env)
+		if err != nil {
+			return "", fmt.Errorf("inputs.%s: %v", name, err)
+		}
+		params[name] = value
+	}
+
+	ctx := actor.WithThingID(e.context(), thingID)
+	ctx = actor.WithCaller(ctx, "rule:"+rule.ID)
+	invocation, err := e.actorManager.InvokeFunctionAsync(ctx, action.ActorID, action.Function, params)
+	if err != nil {
+		return "", err
+	}
+	return invocation.ID, nil
+}
+
+// updateState 更新 Thing 属性，由 ThingService 发布 thing_updated
+func (e *Engine) updateState(action models.RuleAction, thingID string, env expr.Env) error {
+	thing, err := e.thingService.GetThing(thingID)
+	if err != nil {
+		return fmt.Errorf("thing %s not found: %v", thingID, err)
+	}
+
+	attributes := make(map[string]interface{}, len(thing.Attributes)+len(action.Attributes))
+	for name, value := range thing.Attributes {
+		attributes[name] = value
+	}
+	for name, source := range action.Attributes {
+		value, err := e.eval(source, env)
+		if err != nil {
+			return fmt.Errorf("attributes.%s: %v", name, err)
+		}
+		attributes[name] = value
+	}
+
+	if err := e.thingService.UpdateThing(thingID, map[string]interface{}{"attributes": attributes}); err != nil {
+		return fmt.Errorf("failed to update thing %s: %v", thingID, err)
+	}
+	return nil
+}
+
+// notify 广播规则通知
+func (e *Engine) notify(rule *models.Rule, action models.RuleAction, thingID string, env expr.Env) {
+	level := action.Level
+	if level == "" {
+		level = "info"
+	}
+	e.broadcaster.Broadcast("rule_notification", map[string]interface{}{
+		"ruleId":    rule.ID,
+		"ruleName":  rule.Name,
+		"thingId":   thingID,
+		"level":     level,
+		"message":   action.Message,
+		"thing":     env["thing"],
+		"timestamp": time.Now(),
+	})
+}
+
+// buildEnv 构建条件求值环境：thing 为触发事件的 Thing，related 按关系类型列出关联的 Thing
+func (e *Engine) buildEnv(eventType, thingID string) expr.Env {
+	thing, err := e.thingService.GetThingState(thingID)
+	if err != nil {
+		thing = map[string]interface{}{"id": thingID}
+	}
+
+	related := make(map[string]interface{})
+	if relationships, err := e.relationshipService.GetThingRelationships(thingID); err == nil {
+		for _, relationship := range relationships {
+			otherID := relationship.TargetID
+			if otherID == thingID {
+				otherID = relationship.SourceID
+			}
+			state, err := e.thingService.GetThingState(otherID)
+			if err != nil {
+				continue
+			}
+			key := string(relationship.Type)
+			items, _ := related[key].([]interface{})
+			related[key] = append(items, state)
+		}
+	}
+
+	return expr.Env{
+		"thing":   thing,
+		"related": related,
+		"event":   map[string]interface{}{"type": eventType},
+	}
+}
+
+// state 返回规则针对 Thing 的触发状态，调用方需持有锁
+func (e *Engine) state(ruleID, thingID string) *ruleState {
+	key := ruleID + "/" + thingID
+	st, ok := e.states[key]
+	if !ok {
+		st = &ruleState{}
+		e.states[key] = st
+	}
+	return st
+}
+
+// resetRule 清除规则的触发状态
+func (e *Engine) resetRule(ruleID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	prefix := ruleID + "/"
+	for key, st := range e.states {
+		if len(key) > len(prefix) && key[:len(prefix)] == prefix {
+			st.stopPending()
+			delete(e.states, key)
+		}
+	}
+}
+
+// context 返回动作使用的上下文，引擎未启动时使用 Background
+func (e *Engine) context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// parse 解析并缓存表达式
+func (e *Engine) parse(source string) (*expr.Expression, error) {
+	if cached, ok := e.conditions.Load(source); ok {
+		return cached.(*expr.Expression), nil
+	}
+	parsed, err := expr.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	e.conditions.Store(source, parsed)
+	return parsed, nil
+}
+
+// eval 求值表达式
+func (e *Engine) eval(source string, env expr.Env) (interface{}, error) {
+	parsed, err := e.parse(source)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.Eval(env)
+}
+
+// evalBool 求值条件表达式
+func (e *Engine) evalBool(source string, env expr.Env) (bool, error) {
+	parsed, err := e.parse(source)
+	if err != nil {
+		return false, err
+	}
+	return parsed.EvalBool(env)
+}
+
+// subscribes 规则是否订阅该事件
+func subscribes(rule *models.Rule, eventType string) bool {
+	for _, subscribed := range ruleEvents(rule) {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleEvents 返回规则订阅的事件，未指定时为默认的 Thing 变更事件
+func ruleEvents(rule *models.Rule) []string {
+	if len(rule.Events) == 0 {
+		return defaultEvents
+	}
+	return rule.Events
+}
+
+// thingType 返回环境中 Thing 的类型
+func thingType(env expr.Env) string {
+	thing, _ := env["thing"].(map[string]interface{})
+	t, _ := thing["type"].(string)
+	return t
+}
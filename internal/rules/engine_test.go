@@ -0,0 +1,100 @@
+package rules
+
+import (
+	"path/filepath"
+	"testing"
+
+	"uros-restron/internal/database"
+	"uros-restron/internal/events"
+	"uros-restron/internal/models"
+)
+
+// newTestEngine 创建使用临时数据库保存规则的引擎，不启动评估
+func newTestEngine(t *testing.T) *Engine {
+	t.Helper()
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "rules.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		database.Close(db)
+	})
+	if err := db.AutoMigrate(&models.Rule{}, &models.RuleEvaluation{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return NewEngine(models.NewRuleService(db), nil, nil, nil, nil)
+}
+
+// queued 返回队列中的事件类型并清空队列
+func queued(e *Engine) []string {
+	var types []string
+	for {
+		select {
+		case ev := <-e.events:
+			types = append(types, ev.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestHandleEventDropsUnsubscribedEvents(t *testing.T) {
+	e := newTestEngine(t)
+	e.refreshSubscriptions()
+
+	publish := func() {
+		for _, eventType := range []events.Type{events.ThingUpdated, "invocation_progress", events.ActorActivated, events.ThingStatusUpdated} {
+			e.HandleEvent(events.Event{Type: eventType, ThingID: "lamp-1"})
+		}
+		// 不涉及 Thing 的事件从不入队
+		e.HandleEvent(events.Event{Type: events.ThingUpdated})
+	}
+
+	publish()
+	if types := queued(e); len(types) != 0 {
+		t.Fatalf("expected no events without rules, got %v", types)
+	}
+
+	rule := &models.Rule{
+		ID:        "hot",
+		Name:      "hot",
+		Events:    []string{string(events.ThingUpdated)},
+		Condition: "thing.attributes.temperature > 30",
+		Actions:   []models.RuleAction{{Type: models.RuleActionNotify, Message: "hot"}},
+	}
+	if err := e.CreateRule(rule); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	publish()
+	if types := queued(e); len(types) != 1 || types[0] != string(events.ThingUpdated) {
+		t.Fatalf("expected only thing_updated to be queued, got %v", types)
+	}
+
+	// 禁用后不再订阅，未指定事件的规则订阅默认的 Thing 变更事件
+	disabled := false
+	rule.Enabled = &disabled
+	if err := e.UpdateRule(rule); err != nil {
+		t.Fatalf("failed to update rule: %v", err)
+	}
+	defaults := &models.Rule{
+		ID:        "status",
+		Name:      "status",
+		Condition: "true",
+		Actions:   []models.RuleAction{{Type: models.RuleActionNotify, Message: "changed"}},
+	}
+	if err := e.CreateRule(defaults); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+	publish()
+	if types := queued(e); len(types) != 2 || types[0] != string(events.ThingUpdated) || types[1] != string(events.ThingStatusUpdated) {
+		t.Fatalf("expected the default thing events to be queued, got %v", types)
+	}
+
+	if err := e.DeleteRule(defaults.ID); err != nil {
+		t.Fatalf("failed to delete rule: %v", err)
+	}
+	publish()
+	if types := queued(e); len(types) != 0 {
+		t.Fatalf("expected no events after deleting the rules, got %v", types)
+	}
+}
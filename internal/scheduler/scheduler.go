@@ -0,0 +1,321 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"uros-restron/internal/actor"
+	"uros-restron/internal/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maxCatchUpRuns run_all 策略下最多补执行的次数
+const maxCatchUpRuns = 100
+
+// ErrInvalidSchedule 计划配置无效
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// cronParser 支持标准 5 段、可选秒的 6 段和 @daily、@every 1h 等写法
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Scheduler 按 Cron 表达式或固定间隔调用 Actor 函数
+// 每个启用的计划由一个 goroutine 驱动，Start 传入的上下文取消后全部退出
+type Scheduler struct {
+	service      *models.ScheduleService
+	actorManager *actor.ActorManager
+
+	ctx     context.Context // Stop 之后为 nil，不再调度新的计划
+	cancel  context.CancelFunc
+	entries map[string]context.CancelFunc
+	mu      sync.Mutex
+	wg      sync.WaitGroup // 各计划的调度 goroutine
+}
+
+// NewScheduler 创建调度器
+func NewScheduler(service *models.ScheduleService, actorManager *actor.ActorManager) *Scheduler {
+	return &Scheduler{
+		service:      service,
+		actorManager: actorManager,
+		entries:      make(map[string]context.CancelFunc),
+	}
+}
+
+// Start 加载所有启用的计划并开始调度，错过的执行按各计划的策略处理
+func (s *Scheduler) Start(ctx context.Context) error {
+	schedules, err := s.service.ListEnabledSchedules()
+	if err != nil {
+		return fmt.Errorf("failed to load schedules: %v", err)
+	}
+
+	s.mu.Lock()
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.mu.Unlock()
+
+	for i := range schedules {
+		s.arm(schedules[i], true)
+	}
+	log.Printf("Scheduler started with %d schedules", len(schedules))
+	return nil
+}
+
+// CreateSchedule 校验并保存计划，启用时立即开始调度
+func (s *Scheduler) CreateSchedule(schedule *models.Schedule) error {
+	if err := s.Validate(schedule); err != nil {
+		return err
+	}
+
+	next := nextRun(schedule, time.Now())
+	schedule.NextRunAt = &next
+	if err := s.service.CreateSchedule(schedule); err != nil {
+		return fmt.Errorf("failed to create schedule: %v", err)
+	}
+
+	s.arm(*schedule, false)
+	return nil
+}
+
+// UpdateSchedule 校验并更新计划配置，重新计算下一次执行时间
+func (s *Scheduler) UpdateSchedule(schedule *models.Schedule) error {
+	if err := s.Validate(schedule); err != nil {
+		return err
+	}
+
+	next := nextRun(schedule, time.Now())
+	schedule.NextRunAt = &next
+	schedule.UpdatedAt = time.Now()
+	if err := s.service.UpdateSchedule(schedule); err != nil {
+		return fmt.Errorf("failed to update schedule: %v", err)
+	}
+
+	s.disarm(schedule.ID)
+	s.arm(*schedule, false)
+	return nil
+}
+
+// DeleteSchedule 停止并删除计划
+func (s *Scheduler) DeleteSchedule(id string) error {
+	s.disarm(id)
+	return s.service.DeleteSchedule(id)
+}
+
+// Validate 检查计划的执行时间、策略和目标函数
+func (s *Scheduler) Validate(schedule *models.Schedule) error {
+	if schedule.ActorID == "" || schedule.Function == "" {
+		return fmt.Errorf("%w: actorId and function are required", ErrInvalidSchedule)
+	}
+
+	switch {
+	case schedule.Cron != "" && schedule.IntervalSeconds != 0:
+		return fmt.Errorf("%w: cron and intervalSeconds are mutually exclusive", ErrInvalidSchedule)
+	case schedule.Cron != "":
+		if _, err := cronParser.Parse(schedule.Cron); err != nil {
+			return fmt.Errorf("%w: invalid cron expression: %v", ErrInvalidSchedule, err)
+		}
+	case schedule.IntervalSeconds <= 0:
+		return fmt.Errorf("%w: either cron or a positive intervalSeconds is required", ErrInvalidSchedule)
+	}
+
+	if schedule.JitterSeconds < 0 {
+		return fmt.Errorf("%w: jitterSeconds must not be negative", ErrInvalidSchedule)
+	}
+
+	switch schedule.MissedRunPolicy {
+	case "":
+		schedule.MissedRunPolicy = models.DefaultMissedRun
+	case models.MissedRunSkip, models.MissedRunOnce, models.MissedRunAll:
+	default:
+		return fmt.Errorf("%w: unknown missedRunPolicy %q", ErrInvalidSchedule, schedule.MissedRunPolicy)
+	}
+
+	target, err := s.actorManager.GetActor(schedule.ActorID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	behaviorActor, ok := target.(*actor.BehaviorActor)
+	if !ok {
+		return fmt.Errorf("%w: actor %s is not a BehaviorActor", ErrInvalidSchedule, schedule.ActorID)
+	}
+	if _, err := behaviorActor.GetFunctionInfo(schedule.Function); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	return nil
+}
+
+// arm 为启用的计划启动调度 goroutine，调度器未启动时忽略
+func (s *Scheduler) arm(schedule models.Schedule, catchUp bool) {
+	if !schedule.IsEnabled() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.entries[schedule.ID] = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(ctx, schedule, catchUp)
+	}()
+}
+
+// Stop 停止调度，之后不再执行新的调用；正在执行的调用继续运行，通过 Wait 等待其记录完成
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.ctx = nil
+	s.entries = make(map[string]context.CancelFunc)
+}
+
+// Wait 等待 Stop 之后所有调度 goroutine 退出，包括正在执行的调用写入执行记录，ctx 到期时不再等待
+// 正在执行的调用由 Actor 管理器在关闭截止时间到达后取消，应在其关闭之后调用
+func (s *Scheduler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// disarm 停止计划的调度 goroutine
+func (s *Scheduler) disarm(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.entries[id]; ok {
+		cancel()
+		delete(s.entries, id)
+	}
+}
+
+// run 按计划循环执行，catchUp 为 true 时先处理停止期间错过的执行
+func (s *Scheduler) run(ctx context.Context, schedule models.Schedule, catchUp bool) {
+	now := time.Now()
+	next := nextRun(&schedule, now)
+	if schedule.NextRunAt != nil && (catchUp || schedule.NextRunAt.After(now)) {
+		next = *schedule.NextRunAt
+	}
+
+	if catchUp && next.Before(now) {
+		missed := missedRuns(&schedule, next, now)
+		log.Printf("Schedule %s missed %d runs, policy %s", schedule.ID, missed, schedule.MissedRunPolicy)
+		if schedule.MissedRunPolicy == models.MissedRunOnce && missed > 0 {
+			missed = 1
+		}
+		if schedule.MissedRunPolicy != models.MissedRunSkip {
+			for i := 0; i < missed && ctx.Err() == nil; i++ {
+				s.fire(ctx, &schedule)
+			}
+		}
+		next = nextRun(&schedule, time.Now())
+		s.saveNextRun(schedule.ID, next)
+	}
+
+	for {
+		timer := time.NewTimer(time.Until(next) + jitter(&schedule))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.fire(ctx, &schedule)
+
+		// 固定间隔按计划时间推进，执行耗时超过间隔时跳过已经过去的时间点
+		now := time.Now()
+		if schedule.Cron != "" {
+			next = nextRun(&schedule, now)
+		} else {
+			for !next.After(now) {
+				next = nextRun(&schedule, next)
+			}
+		}
+		s.saveNextRun(schedule.ID, next)
+	}
+}
+
+// fire 执行一次计划的函数调用并记录结果
+// 调度停止时已开始的调用继续执行，由 Actor 管理器在关闭截止时间到达后取消
+func (s *Scheduler) fire(ctx context.Context, schedule *models.Schedule) {
+	runCtx := actor.WithThingID(context.WithoutCancel(ctx), schedule.ThingID)
+	runCtx = actor.WithCaller(runCtx, "schedule:"+schedule.ID)
+
+	run := models.ScheduleRun{RunAt: time.Now(), Status: models.InvocationSucceeded}
+	result, err := s.actorManager.InvokeFunction(runCtx, schedule.ActorID, schedule.Function, copyParams(schedule.Params))
+	if result != nil {
+		run.InvocationID = result.InvocationID
+	}
+	if err != nil {
+		run.Status = models.InvocationFailed
+		run.Error = err.Error()
+		log.Printf("Schedule %s failed to invoke %s.%s: %v", schedule.ID, schedule.ActorID, schedule.Function, err)
+	}
+
+	if err := s.service.RecordRun(schedule.ID, run); err != nil {
+		log.Printf("Failed to record run of schedule %s: %v", schedule.ID, err)
+	}
+}
+
+// saveNextRun 保存下一次执行时间，保存失败只记录日志
+func (s *Scheduler) saveNextRun(id string, next time.Time) {
+	if err := s.service.SetNextRun(id, &next); err != nil {
+		log.Printf("Failed to save next run of schedule %s: %v", id, err)
+	}
+}
+
+// nextRun 计算 after 之后的下一次执行时间
+func nextRun(schedule *models.Schedule, after time.Time) time.Time {
+	if schedule.Cron != "" {
+		spec, err := cronParser.Parse(schedule.Cron)
+		if err != nil {
+			// 校验过的表达式不会出错，保险起见按一天后执行
+			return after.Add(24 * time.Hour)
+		}
+		return spec.Next(after)
+	}
+	return after.Add(time.Duration(schedule.IntervalSeconds) * time.Second)
+}
+
+// missedRuns 统计 from 到 until 之间错过的执行次数，最多 maxCatchUpRuns 次
+func missedRuns(schedule *models.Schedule, from, until time.Time) int {
+	missed := 0
+	for t := from; !t.After(until) && missed < maxCatchUpRuns; t = nextRun(schedule, t) {
+		missed++
+	}
+	return missed
+}
+
+// jitter 返回 0 到 JitterSeconds 秒之间的随机延迟
+func jitter(schedule *models.Schedule) time.Duration {
+	if schedule.JitterSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(schedule.JitterSeconds) * int64(time.Second)))
+}
+
+// copyParams 复制计划参数，避免函数执行修改计划
+func copyParams(params map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		copied[key] = value
+	}
+	return copied
+}
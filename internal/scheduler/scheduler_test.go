@@ -0,0 +1,251 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uros-restron/internal/actor"
+	"uros-restron/internal/database"
+	"uros-restron/internal/models"
+)
+
+// newTestScheduler 创建使用临时数据库保存计划的调度器，Actor 管理器中有 clock 行为及其 tick 函数
+func newTestScheduler(t *testing.T) (*Scheduler, *models.ScheduleService) {
+	t.Helper()
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "schedules.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		database.Close(db)
+	})
+	if err := db.AutoMigrate(&models.Schedule{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	am := actor.NewActorManager(nil)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		am.Shutdown(ctx)
+	})
+	if _, err := am.CreateActorFromBehaviorData(&models.Behavior{
+		ID:        "clock",
+		Name:      "clock",
+		Functions: map[string]models.Function{"tick": {Name: "tick"}},
+	}); err != nil {
+		t.Fatalf("failed to create actor: %v", err)
+	}
+
+	service := models.NewScheduleService(db)
+	return NewScheduler(service, am), service
+}
+
+func TestNextRun(t *testing.T) {
+	// 2024-03-08 是星期五
+	base := time.Date(2024, 3, 8, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule models.Schedule
+		after    time.Time
+		want     time.Time
+	}{
+		{"every 15 minutes", models.Schedule{Cron: "*/15 * * * *"}, base, time.Date(2024, 3, 8, 10, 15, 0, 0, time.UTC)},
+		{"strictly after a matching time", models.Schedule{Cron: "0 * * * *"}, time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC), time.Date(2024, 3, 8, 11, 0, 0, 0, time.UTC)},
+		{"weekdays skip the weekend", models.Schedule{Cron: "0 9 * * 1-5"}, base, time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)},
+		{"named weekday", models.Schedule{Cron: "30 6 * * SUN"}, base, time.Date(2024, 3, 10, 6, 30, 0, 0, time.UTC)},
+		{"optional seconds field", models.Schedule{Cron: "45 7 10 * * *"}, base, time.Date(2024, 3, 8, 10, 7, 45, 0, time.UTC)},
+		{"seconds field rolls over", models.Schedule{Cron: "*/20 * * * * *"}, base, time.Date(2024, 3, 8, 10, 7, 40, 0, time.UTC)},
+		{"daily descriptor", models.Schedule{Cron: "@daily"}, base, time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"hourly descriptor", models.Schedule{Cron: "@hourly"}, base, time.Date(2024, 3, 8, 11, 0, 0, 0, time.UTC)},
+		{"every descriptor", models.Schedule{Cron: "@every 1h30m"}, base, base.Add(90 * time.Minute)},
+		{"day missing from next month", models.Schedule{Cron: "0 0 31 * *"}, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)},
+		{"leap day", models.Schedule{Cron: "0 0 29 2 *"}, base, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"year rollover", models.Schedule{Cron: "0 0 1 1 *"}, base, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"interval", models.Schedule{IntervalSeconds: 30}, base, base.Add(30 * time.Second)},
+		{"invalid cron falls back to a day", models.Schedule{Cron: "not a cron"}, base, base.Add(24 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextRun(&tt.schedule, tt.after)
+			if !got.Equal(tt.want) {
+				t.Fatalf("nextRun(%q) after %s = %s, want %s", tt.schedule.Cron, tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextRunKeepsLocation(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	schedule := &models.Schedule{Cron: "0 9 * * *"}
+
+	// 按 after 所在时区计算，上海 9 点是 UTC 1 点
+	got := nextRun(schedule, time.Date(2024, 3, 8, 0, 30, 0, 0, time.UTC).In(shanghai))
+	want := time.Date(2024, 3, 8, 1, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("nextRun = %s, want %s", got, want)
+	}
+}
+
+func TestMissedRuns(t *testing.T) {
+	from := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule models.Schedule
+		until    time.Time
+		want     int
+	}{
+		{"until before from", models.Schedule{IntervalSeconds: 60}, from.Add(-time.Second), 0},
+		{"only the missed time itself", models.Schedule{IntervalSeconds: 60}, from, 1},
+		{"interval inclusive of both ends", models.Schedule{IntervalSeconds: 60}, from.Add(5 * time.Minute), 6},
+		{"interval partial period", models.Schedule{IntervalSeconds: 60}, from.Add(5*time.Minute + 59*time.Second), 6},
+		{"cron hourly", models.Schedule{Cron: "0 * * * *"}, from.Add(3*time.Hour + 30*time.Minute), 4},
+		{"cron weekdays over a weekend", models.Schedule{Cron: "0 10 * * 1-5"}, from.Add(4 * 24 * time.Hour), 3},
+		{"capped", models.Schedule{IntervalSeconds: 1}, from.Add(time.Hour), maxCatchUpRuns},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missedRuns(&tt.schedule, from, tt.until); got != tt.want {
+				t.Fatalf("missedRuns = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, _ := newTestScheduler(t)
+
+	valid := []models.Schedule{
+		{Cron: "*/5 * * * *"},
+		{Cron: "0 9 * * MON-FRI"},
+		{Cron: "*/10 * * * * *"},
+		{Cron: "@daily"},
+		{Cron: "@every 1h30m"},
+		{IntervalSeconds: 60, JitterSeconds: 10},
+		{IntervalSeconds: 60, MissedRunPolicy: models.MissedRunAll},
+	}
+	for _, schedule := range valid {
+		schedule.ActorID, schedule.Function = "clock", "tick"
+		if err := s.Validate(&schedule); err != nil {
+			t.Errorf("Validate(cron %q, interval %d) = %v, want nil", schedule.Cron, schedule.IntervalSeconds, err)
+		}
+	}
+
+	schedule := models.Schedule{ActorID: "clock", Function: "tick", IntervalSeconds: 60}
+	if err := s.Validate(&schedule); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if schedule.MissedRunPolicy != models.DefaultMissedRun {
+		t.Fatalf("expected default policy %s, got %q", models.DefaultMissedRun, schedule.MissedRunPolicy)
+	}
+
+	invalid := []struct {
+		name     string
+		schedule models.Schedule
+	}{
+		{"missing actor", models.Schedule{Function: "tick", IntervalSeconds: 60}},
+		{"missing function", models.Schedule{ActorID: "clock", IntervalSeconds: 60}},
+		{"cron and interval", models.Schedule{ActorID: "clock", Function: "tick", Cron: "@daily", IntervalSeconds: 60}},
+		{"neither cron nor interval", models.Schedule{ActorID: "clock", Function: "tick"}},
+		{"negative interval", models.Schedule{ActorID: "clock", Function: "tick", IntervalSeconds: -1}},
+		{"too few cron fields", models.Schedule{ActorID: "clock", Function: "tick", Cron: "* * *"}},
+		{"too many cron fields", models.Schedule{ActorID: "clock", Function: "tick", Cron: "0 0 0 * * * *"}},
+		{"minute out of range", models.Schedule{ActorID: "clock", Function: "tick", Cron: "60 * * * *"}},
+		{"month out of range", models.Schedule{ActorID: "clock", Function: "tick", Cron: "0 0 1 13 *"}},
+		{"unknown descriptor", models.Schedule{ActorID: "clock", Function: "tick", Cron: "@fortnightly"}},
+		{"invalid every duration", models.Schedule{ActorID: "clock", Function: "tick", Cron: "@every soon"}},
+		{"negative jitter", models.Schedule{ActorID: "clock", Function: "tick", IntervalSeconds: 60, JitterSeconds: -1}},
+		{"unknown policy", models.Schedule{ActorID: "clock", Function: "tick", IntervalSeconds: 60, MissedRunPolicy: "later"}},
+		{"unknown actor", models.Schedule{ActorID: "missing", Function: "tick", IntervalSeconds: 60}},
+		{"unknown function", models.Schedule{ActorID: "clock", Function: "missing", IntervalSeconds: 60}},
+	}
+	for _, tt := range invalid {
+		if err := s.Validate(&tt.schedule); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("%s: Validate = %v, want ErrInvalidSchedule", tt.name, err)
+		}
+	}
+}
+
+func TestCreateScheduleSetsNextRun(t *testing.T) {
+	s, service := newTestScheduler(t)
+
+	schedule := &models.Schedule{ActorID: "clock", Function: "tick", Cron: "0 0 1 1 *"}
+	if err := s.CreateSchedule(schedule); err != nil {
+		t.Fatalf("failed to create schedule: %v", err)
+	}
+
+	saved, err := service.GetSchedule(schedule.ID)
+	if err != nil {
+		t.Fatalf("failed to get schedule: %v", err)
+	}
+	want := time.Date(time.Now().Year()+1, 1, 1, 0, 0, 0, 0, time.Local)
+	if saved.NextRunAt == nil || !saved.NextRunAt.Equal(want) {
+		t.Fatalf("expected next run at %s, got %v", want, saved.NextRunAt)
+	}
+}
+
+func TestStartCatchesUpMissedRuns(t *testing.T) {
+	s, service := newTestScheduler(t)
+
+	// 每小时执行，停止期间错过了 5 小时 30 分钟，共 6 次
+	missedSince := time.Now().Add(-5*time.Hour - 30*time.Minute)
+	want := map[models.MissedRunPolicy]int64{
+		models.MissedRunSkip: 0,
+		models.MissedRunOnce: 1,
+		models.MissedRunAll:  6,
+	}
+	for policy := range want {
+		next := missedSince
+		if err := service.CreateSchedule(&models.Schedule{
+			ID:              string(policy),
+			ActorID:         "clock",
+			Function:        "tick",
+			IntervalSeconds: 3600,
+			MissedRunPolicy: policy,
+			NextRunAt:       &next,
+		}); err != nil {
+			t.Fatalf("failed to create schedule: %v", err)
+		}
+	}
+
+	started := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("failed to start scheduler: %v", err)
+	}
+	defer s.Stop()
+
+	for policy, runs := range want {
+		// 补执行结束后才保存新的下一次执行时间
+		var saved *models.Schedule
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			var err error
+			if saved, err = service.GetSchedule(string(policy)); err != nil {
+				t.Fatalf("failed to get schedule: %v", err)
+			}
+			if saved.NextRunAt.After(started) || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if saved.RunCount != runs {
+			t.Errorf("policy %s: expected %d runs, got %d", policy, runs, saved.RunCount)
+		}
+		if earliest := started.Add(time.Hour); saved.NextRunAt.Before(earliest) || saved.NextRunAt.After(time.Now().Add(time.Hour)) {
+			t.Errorf("policy %s: expected next run an hour after start, got %s", policy, saved.NextRunAt)
+		}
+		if runs > 0 && saved.LastStatus != models.InvocationSucceeded {
+			t.Errorf("policy %s: expected last run to succeed, got %q (%s)", policy, saved.LastStatus, saved.LastError)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// YAML 相关的 MIME 类型
+var yamlMediaTypes = []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml"}
+
+// IsYAMLRequest 检查请求体是否为 YAML
+func IsYAMLRequest(c *gin.Context) bool {
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return isYAMLMediaType(mediaType)
+}
+
+// WantsYAML 检查客户端是否要求 YAML 响应，支持 Accept 头和 ?format=yaml
+func WantsYAML(c *gin.Context) bool {
+	if format := c.Query("format"); format != "" {
+		return format == "yaml" || format == "yml"
+	}
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && isYAMLMediaType(mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// RespondWithYAML 响应 YAML 文档
+func RespondWithYAML(c *gin.Context, statusCode int, data []byte) {
+	c.Data(statusCode, "application/yaml; charset=utf-8", data)
+}
+
+// RespondWithYAMLData 响应 YAML 文档（默认状态码200）
+func RespondWithYAMLData(c *gin.Context, data []byte) {
+	RespondWithYAML(c, http.StatusOK, data)
+}
+
+func isYAMLMediaType(mediaType string) bool {
+	for _, t := range yamlMediaTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
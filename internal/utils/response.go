@@ -73,6 +73,15 @@ func ValidationErrorResponse(c *gin.Context, message string) {
 	ErrorResponse(c, http.StatusBadRequest, message)
 }
 
+// ValidationFailedResponse 带校验详情的验证错误响应
+func ValidationFailedResponse(c *gin.Context, message string, details interface{}) {
+	c.JSON(http.StatusBadRequest, Response{
+		Success: false,
+		Error:   message,
+		Data:    details,
+	})
+}
+
 // NotFoundResponse 未找到响应
 func NotFoundResponse(c *gin.Context, resource string) {
 	ErrorResponse(c, http.StatusNotFound, resource+" not found")
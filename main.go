@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"uros-restron/internal/actor"
 	"uros-restron/internal/api"
+	"uros-restron/internal/cluster"
 	"uros-restron/internal/config"
 	"uros-restron/internal/database"
+	"uros-restron/internal/events"
 	"uros-restron/internal/models"
+	"uros-restron/internal/rules"
+	"uros-restron/internal/scheduler"
 	"uros-restron/internal/utils"
 )
 
@@ -22,7 +30,7 @@ func main() {
 
 	// 运行数据库迁移
 	migrationUtils := utils.NewMigrationUtils(db)
-	if err := migrationUtils.RunMigrations(&models.Thing{}, &models.ThingType{}, &models.Relationship{}, &models.Behavior{}); err != nil {
+	if err := migrationUtils.RunMigrations(&models.Thing{}, &models.ThingType{}, &models.Relationship{}, &models.Behavior{}, &models.Invocation{}, &models.Schedule{}, &models.Rule{}, &models.RuleEvaluation{}, &models.ActorSnapshot{}, &models.ActorEvent{}, &models.OutboxEvent{}, &models.HistoryEvent{}, &models.StoreIdentity{}); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
@@ -41,30 +49,208 @@ func main() {
 	thingTypeService := models.NewThingTypeService(db)
 	relationshipService := models.NewRelationshipService(db)
 	behaviorService := models.NewBehaviorService(db)
+	invocationService := models.NewInvocationService(db)
+	historyService := models.NewHistoryService(db)
+	scheduleService := models.NewScheduleService(db)
+	ruleService := models.NewRuleService(db)
+	actorSnapshotService := models.NewActorSnapshotService(db)
+	actorEventService := models.NewActorEventService(db)
+
+	// 上次退出前未结束的调用不会再完成，在开始执行调用之前记为失败
+	if failed, err := invocationService.FailInterrupted("interrupted: the server stopped before the invocation finished"); err != nil {
+		log.Printf("Warning: Failed to reconcile interrupted invocations: %v", err)
+	} else if failed > 0 {
+		log.Printf("Marked %d interrupted invocations as failed", failed)
+	}
+
+	outboxService := models.NewOutboxService(db)
+
+	// 领域事件总线：服务和 Actor 管理器在变更成功后发布事件
+	// Thing 和关系的事件与变更在同一事务中写入发件箱，由分发器按提交顺序发布
+	bus := events.NewBus()
+	thingService.SetOutbox(outboxService)
+	relationshipService.SetOutbox(outboxService)
+	thingTypeService.SetEventBus(bus)
+	behaviorService.SetEventBus(bus)
+
+	// 记录事件总线上的所有事件，由 Stop 停止，以便记录关闭过程中发布的事件
+	historyRecorder := models.NewHistoryRecorder(historyService, cfg.Database.HistoryRetention)
+	bus.Subscribe(historyRecorder.HandleEvent)
+	historyRecorder.Start(context.Background())
+
+	behaviorService.SetBehaviorsPath(cfg.Behaviors.Path)
+	behaviorService.SetValidator(models.NewBehaviorValidator(actor.ActionCatalog()))
 	actorManager := actor.NewActorManager(behaviorService)
-	hub := api.NewHub()
+	actorManager.SetEventBus(bus)
+	actorManager.SetThingStateProvider(thingService)
+	actorManager.SetInvocationService(invocationService)
+	actorManager.SetThingDirectory(models.NewThingDirectory(db))
+	actorManager.SetPassivation(actorSnapshotService, cfg.Actors.IdleTimeout)
+	actorManager.SetEventJournal(actorEventService, cfg.Actors.SnapshotEvery)
+	actorManager.SetMailboxDefaults(actor.MailboxConfig{
+		Capacity:       cfg.Actors.MailboxCapacity,
+		Overflow:       actor.OverflowPolicy(cfg.Actors.MailboxOverflow),
+		BlockTimeout:   cfg.Actors.MailboxBlockTimeout,
+		MaxInvocations: cfg.Actors.MaxInvocations,
+	})
+	actorManager.SetLiveness(actor.LivenessConfig{
+		HeartbeatInterval: cfg.Actors.HeartbeatInterval,
+		HeartbeatTimeout:  cfg.Actors.HeartbeatTimeout,
+		StuckThreshold:    cfg.Actors.StuckThreshold,
+		MaxRestarts:       cfg.Actors.MaxRestarts,
+	})
+
+	// 把领域事件推送给 WebSocket 和 SSE 客户端
+	hub := api.NewHub(cfg.Server.EventLogSize)
+	bus.Subscribe(hub.HandleEvent)
 
 	// 启动 Actor 管理器
 	actorManager.Start()
 
+	// 填充预定义行为，开启监听时由监听器完成首次同步
+	var behaviorWatcher *models.BehaviorWatcher
+	if cfg.Behaviors.Watch {
+		behaviorWatcher = models.NewBehaviorWatcher(behaviorService)
+		// 行为服务在同步到数据库时已发布 behavior_created、behavior_updated 和 behavior_deleted，
+		// 这里只重建 Actor，并广播没有写入数据库的加载错误
+		behaviorWatcher.OnChange(func(event models.BehaviorChangeEvent) {
+			if event.Type == models.BehaviorLoadFailed {
+				bus.Publish(events.Event{Type: events.Type(event.Type), Aggregate: events.AggregateBehavior, Data: event})
+				return
+			}
+			if err := actorManager.ReloadActor(event.BehaviorID); err != nil {
+				log.Printf("Warning: Failed to reload actor %s: %v", event.BehaviorID, err)
+			}
+		})
+		if err := behaviorWatcher.Start(actorManager.Context()); err != nil {
+			log.Printf("Warning: Failed to watch behaviors: %v", err)
+		}
+	} else if _, err := behaviorService.SeedPredefinedBehaviors(); err != nil {
+		log.Printf("Warning: Failed to seed behaviors: %v", err)
+	}
+
 	// 注册行为到 Actor 系统
 	if err := actorManager.RegisterBehaviorsFromService(behaviorService); err != nil {
 		log.Printf("Warning: Failed to register behaviors: %v", err)
 	}
 
-	// 填充预定义行为
-	if err := behaviorService.SeedPredefinedBehaviors(); err != nil {
-		log.Printf("Warning: Failed to seed behaviors: %v", err)
+	// 启动定时调用，随 Actor 管理器关闭而停止
+	taskScheduler := scheduler.NewScheduler(scheduleService, actorManager)
+	if err := taskScheduler.Start(actorManager.Context()); err != nil {
+		log.Printf("Warning: Failed to start scheduler: %v", err)
+	}
+
+	// 启动规则引擎，订阅领域事件，规则通知通过 Hub 推送
+	ruleEngine := rules.NewEngine(ruleService, thingService, relationshipService, actorManager, hub)
+	bus.Subscribe(ruleEngine.HandleEvent)
+	ruleEngine.Start(actorManager.Context())
+
+	// 定期清理已结束的调用记录
+	invocationPruner := models.NewInvocationPruner(invocationService, cfg.Database.InvocationRetention)
+	invocationPruner.Start(actorManager.Context())
+
+	// 订阅者就绪后开始发布发件箱中的事件，包括上次退出前未发布的事件
+	outboxDispatcher := models.NewOutboxDispatcher(outboxService, bus, cfg.Database.OutboxRetention)
+	outboxDispatcher.Start(actorManager.Context())
+
+	// 集群模式：按一致性哈希把消息路由到其他节点
+	var clusterNode *cluster.Cluster
+	if cfg.Cluster.Nodes != "" {
+		nodes, err := cluster.ParseNodes(cfg.Cluster.Nodes)
+		if err != nil {
+			log.Fatal("Failed to parse cluster nodes:", err)
+		}
+		// 节点共享 Thing、事件日志和快照，只有使用同一数据库的节点才能加入哈希环
+		storeID, err := models.EnsureStoreID(db)
+		if err != nil {
+			log.Fatal("Failed to read database identity:", err)
+		}
+		clusterNode, err = cluster.New(cluster.Config{
+			NodeID:       cfg.Cluster.NodeID,
+			Nodes:        nodes,
+			PingInterval: cfg.Cluster.PingInterval,
+			StoreID:      storeID,
+		}, actorManager)
+		if err != nil {
+			log.Fatal("Failed to create cluster node:", err)
+		}
+		actorManager.Router().SetRemote(clusterNode)
+		clusterNode.Start(actorManager.Context())
 	}
 
 	// 启动 WebSocket 服务
 	go hub.Run()
 
 	// 启动 HTTP 服务器
-	server := api.NewServer(cfg, thingService, thingTypeService, relationshipService, behaviorService, actorManager, hub)
+	server := api.NewServer(cfg, thingService, thingTypeService, relationshipService, behaviorService, invocationService, historyService, scheduleService, taskScheduler, ruleService, ruleEngine, actorManager, clusterNode, hub)
+
+	go func() {
+		log.Printf("Starting server on port %s", cfg.Server.Port)
+		if err := server.Start(); err != nil {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// 等待退出信号
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	log.Printf("Received %s, shutting down (timeout %v)", sig, cfg.Server.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// 停止接受新请求，等待处理中的 HTTP 请求完成
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Warning: Failed to drain HTTP requests: %v", err)
+	}
+
+	// 按依赖顺序停止产生调用和事件的后台任务：行为监听、定时调度、规则引擎、集群探测和调用记录清理
+	if behaviorWatcher != nil {
+		if err := behaviorWatcher.Stop(ctx); err != nil {
+			log.Printf("Warning: Failed to stop behavior watcher: %v", err)
+		}
+	}
+	taskScheduler.Stop()
+	if err := ruleEngine.Stop(ctx); err != nil {
+		log.Printf("Warning: Failed to stop rule engine: %v", err)
+	}
+	if clusterNode != nil {
+		if err := clusterNode.Stop(ctx); err != nil {
+			log.Printf("Warning: Failed to stop cluster probe: %v", err)
+		}
+	}
+	if err := invocationPruner.Stop(ctx); err != nil {
+		log.Printf("Warning: Failed to stop invocation pruner: %v", err)
+	}
+
+	// 等待 Actor 处理完消息和执行中的调用并保存快照
+	if err := actorManager.Shutdown(ctx); err != nil {
+		log.Printf("Warning: Failed to stop actors: %v", err)
+	}
+
+	// 定时调用结束后写入执行记录
+	if err := taskScheduler.Wait(ctx); err != nil {
+		log.Printf("Warning: Failed to wait for scheduled invocations: %v", err)
+	}
+
+	// 先停止发件箱分发，Hub 停止后不再有事件发布给它；未发布的事件在下次启动时发布
+	if err := outboxDispatcher.Stop(ctx); err != nil {
+		log.Printf("Warning: Failed to stop outbox dispatcher: %v", err)
+	}
+
+	// 向 WebSocket 客户端发送关闭帧
+	if err := hub.Stop(ctx); err != nil {
+		log.Printf("Warning: Failed to close WebSocket connections: %v", err)
+	}
+
+	// 写入关闭过程中发布的事件
+	if err := historyRecorder.Stop(ctx); err != nil {
+		log.Printf("Warning: Failed to flush event history: %v", err)
+	}
 
-	log.Printf("Starting server on port %s", cfg.Server.Port)
-	if err := server.Start(); err != nil {
-		log.Fatal("Failed to start server:", err)
+	if err := database.Close(db); err != nil {
+		log.Printf("Warning: Failed to close database: %v", err)
 	}
+	log.Printf("Server stopped")
 }